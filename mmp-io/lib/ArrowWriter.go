@@ -0,0 +1,382 @@
+package lib
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+// The subset of the Arrow IPC format (columnar format version 1.0, metadata V5)
+// needed to write the merged table. Flatbuffers are encoded by hand so that the
+// WASM build needs no Arrow dependency.
+
+const (
+	arrowMetadataV5         = 4
+	arrowHeaderSchema       = 1
+	arrowHeaderRecordBatch  = 3
+	arrowTypeInt            = 2
+	arrowTypeFloatingPoint  = 3
+	arrowTypeUtf8           = 5
+	arrowPrecisionDouble    = 2
+	arrowContinuationMarker = 0xFFFFFFFF
+)
+
+var arrowMagic = []byte("ARROW1")
+
+// fbField is one slot of a flatbuffer table: a scalar of size bytes, or, when
+// child is set, an offset to an object written by child after the table.
+type fbField struct {
+	size  int
+	value uint64
+	child func(b *fbBuilder) int
+}
+
+func fbScalar(size int, value uint64) *fbField { return &fbField{size: size, value: value} }
+func fbChild(child func(b *fbBuilder) int) *fbField {
+	return &fbField{size: 4, child: child}
+}
+
+// fbBuilder writes flatbuffers front to back: a table is written before the
+// strings, vectors and tables it references and their offsets are patched once
+// they are placed. Alignment is relative to the start of the buffer.
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) patch(at int, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+func putUint(buf []byte, size int, value uint64) {
+	switch size {
+	case 1:
+		buf[0] = byte(value)
+	case 2:
+		binary.LittleEndian.PutUint16(buf, uint16(value))
+	case 4:
+		binary.LittleEndian.PutUint32(buf, uint32(value))
+	case 8:
+		binary.LittleEndian.PutUint64(buf, value)
+	}
+}
+
+func (b *fbBuilder) appendUint(size int, value uint64) {
+	at := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	putUint(b.buf[at:], size, value)
+}
+
+// table writes a table with one slot per field, nil fields are absent, and returns its position.
+func (b *fbBuilder) table(fields []*fbField) int {
+	// Lay fields out largest first so every field is naturally aligned
+	order := make([]int, 0, len(fields))
+	for i, field := range fields {
+		if field != nil {
+			order = append(order, i)
+		}
+	}
+	slices.SortStableFunc(order, func(i, j int) int { return fields[j].size - fields[i].size })
+	offsets := make([]int, len(fields))
+	size := 4
+	for _, i := range order {
+		for size%fields[i].size != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += fields[i].size
+	}
+
+	b.pad(2)
+	vtable := len(b.buf)
+	b.appendUint(2, uint64(4+2*len(fields)))
+	b.appendUint(2, uint64(size))
+	for _, offset := range offsets {
+		b.appendUint(2, uint64(offset))
+	}
+	b.pad(8)
+	table := len(b.buf)
+	b.appendUint(4, uint64(table-vtable))
+	b.buf = append(b.buf, make([]byte, size-4)...)
+	for _, i := range order {
+		if fields[i].child == nil {
+			putUint(b.buf[table+offsets[i]:], fields[i].size, fields[i].value)
+		}
+	}
+	for _, i := range order {
+		if fields[i].child != nil {
+			b.patch(table+offsets[i], fields[i].child(b))
+		}
+	}
+	return table
+}
+
+func (b *fbBuilder) string(s string) int {
+	b.pad(4)
+	at := len(b.buf)
+	b.appendUint(4, uint64(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return at
+}
+
+// structVector writes a vector of count structs of size bytes, aligned to 8.
+func (b *fbBuilder) structVector(count int, size int, write func(i int, element []byte)) int {
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	at := len(b.buf)
+	b.appendUint(4, uint64(count))
+	start := len(b.buf)
+	b.buf = append(b.buf, make([]byte, count*size)...)
+	for i := 0; i < count; i++ {
+		write(i, b.buf[start+i*size:start+(i+1)*size])
+	}
+	return at
+}
+
+func (b *fbBuilder) tableVector(count int, table func(b *fbBuilder, i int) int) int {
+	b.pad(4)
+	at := len(b.buf)
+	b.appendUint(4, uint64(count))
+	start := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4*count)...)
+	for i := 0; i < count; i++ {
+		b.patch(start+4*i, table(b, i))
+	}
+	return at
+}
+
+// finish writes the root table and returns the flatbuffer padded to 8 bytes.
+func fbFinish(root []*fbField) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.patch(0, b.table(root))
+	b.pad(8)
+	return b.buf
+}
+
+// arrowColumn is one field of the Arrow schema.
+type arrowColumn struct {
+	name     string
+	nullable bool
+	typeType uint64
+	typ      []*fbField
+}
+
+func arrowSchemaColumns(names []string) []arrowColumn {
+	integer := func(bitWidth uint64) []*fbField {
+		return []*fbField{fbScalar(4, bitWidth), fbScalar(1, 0)}
+	}
+	columns := []arrowColumn{
+		{cpraHeader[0], false, arrowTypeInt, integer(32)},
+		{cpraHeader[1], false, arrowTypeInt, integer(64)},
+		{cpraHeader[2], false, arrowTypeUtf8, []*fbField{}},
+		{cpraHeader[3], false, arrowTypeUtf8, []*fbField{}},
+	}
+	for _, name := range names {
+		columns = append(columns, arrowColumn{name, true, arrowTypeFloatingPoint, []*fbField{fbScalar(2, arrowPrecisionDouble)}})
+	}
+	return columns
+}
+
+func arrowSchema(columns []arrowColumn) []*fbField {
+	return []*fbField{
+		fbScalar(2, 0), // little endian
+		fbChild(func(b *fbBuilder) int {
+			return b.tableVector(len(columns), func(b *fbBuilder, i int) int {
+				column := columns[i]
+				nullable := uint64(0)
+				if column.nullable {
+					nullable = 1
+				}
+				return b.table([]*fbField{
+					fbChild(func(b *fbBuilder) int { return b.string(column.name) }),
+					fbScalar(1, nullable),
+					fbScalar(1, column.typeType),
+					fbChild(func(b *fbBuilder) int { return b.table(column.typ) }),
+					nil,
+					fbChild(func(b *fbBuilder) int { return b.tableVector(0, nil) }),
+				})
+			})
+		}),
+	}
+}
+
+func arrowMessage(headerType uint64, header []*fbField, bodyLength int) []byte {
+	return fbFinish([]*fbField{
+		fbScalar(2, arrowMetadataV5),
+		fbScalar(1, headerType),
+		fbChild(func(b *fbBuilder) int { return b.table(header) }),
+		fbScalar(8, uint64(bodyLength)),
+	})
+}
+
+// arrowBody accumulates the buffers of a record batch, each padded to 8 bytes.
+type arrowBody struct {
+	data    []byte
+	buffers [][2]int // offset, length
+	nodes   [][2]int // length, null count
+}
+
+func (body *arrowBody) buffer(data []byte) {
+	body.buffers = append(body.buffers, [2]int{len(body.data), len(data)})
+	body.data = append(body.data, data...)
+	for len(body.data)%8 != 0 {
+		body.data = append(body.data, 0)
+	}
+}
+
+func (body *arrowBody) validity(valid []bool) {
+	nulls := 0
+	bitmap := make([]byte, (len(valid)+7)/8)
+	for i, ok := range valid {
+		if ok {
+			bitmap[i/8] |= 1 << (i % 8)
+		} else {
+			nulls++
+		}
+	}
+	body.nodes = append(body.nodes, [2]int{len(valid), nulls})
+	if nulls == 0 {
+		bitmap = nil
+	}
+	body.buffer(bitmap)
+}
+
+func (body *arrowBody) strings(values []string) {
+	body.nodes = append(body.nodes, [2]int{len(values), 0})
+	body.buffer(nil)
+	offsets := make([]byte, 0, 4*(len(values)+1))
+	var data []byte
+	offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+	for _, value := range values {
+		data = append(data, value...)
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+	}
+	body.buffer(offsets)
+	body.buffer(data)
+}
+
+func arrowRecordBatch(table *typedSummaryTable) ([]byte, []byte) {
+	n := len(table.Chromosomes)
+	body := &arrowBody{}
+	allValid := make([]bool, n)
+	for i := range allValid {
+		allValid[i] = true
+	}
+
+	body.validity(allValid)
+	data := make([]byte, 0, 4*n)
+	for _, chromosome := range table.Chromosomes {
+		data = binary.LittleEndian.AppendUint32(data, chromosome)
+	}
+	body.buffer(data)
+
+	body.validity(allValid)
+	data = make([]byte, 0, 8*n)
+	for _, position := range table.Positions {
+		data = binary.LittleEndian.AppendUint64(data, position)
+	}
+	body.buffer(data)
+
+	body.strings(table.Refs)
+	body.strings(table.Alts)
+
+	for c, column := range table.Columns {
+		body.validity(table.Valid[c])
+		data = make([]byte, 0, 8*n)
+		for _, value := range column {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(value))
+		}
+		body.buffer(data)
+	}
+
+	metadata := arrowMessage(arrowHeaderRecordBatch, []*fbField{
+		fbScalar(8, uint64(n)),
+		fbChild(func(b *fbBuilder) int {
+			return b.structVector(len(body.nodes), 16, func(i int, element []byte) {
+				binary.LittleEndian.PutUint64(element, uint64(body.nodes[i][0]))
+				binary.LittleEndian.PutUint64(element[8:], uint64(body.nodes[i][1]))
+			})
+		}),
+		fbChild(func(b *fbBuilder) int {
+			return b.structVector(len(body.buffers), 16, func(i int, element []byte) {
+				binary.LittleEndian.PutUint64(element, uint64(body.buffers[i][0]))
+				binary.LittleEndian.PutUint64(element[8:], uint64(body.buffers[i][1]))
+			})
+		}),
+	}, len(body.data))
+	return metadata, body.data
+}
+
+// arrowBlock records where a message was written, for the file footer.
+type arrowBlock struct {
+	offset         int
+	metadataLength int
+	bodyLength     int
+}
+
+// appendArrowMessage writes an encapsulated message: continuation marker, metadata length, metadata and body.
+func appendArrowMessage(out []byte, metadata []byte, body []byte) ([]byte, arrowBlock) {
+	block := arrowBlock{offset: len(out), metadataLength: 8 + len(metadata), bodyLength: len(body)}
+	out = binary.LittleEndian.AppendUint32(out, arrowContinuationMarker)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(metadata)))
+	out = append(out, metadata...)
+	out = append(out, body...)
+	return out, block
+}
+
+// ArrowBytes writes the merged table in the Arrow IPC stream format, or the file
+// format when file is set. Each partition holds the blocks of every source, as passed
+// to SummaryBytesString, and becomes one record batch. Variant columns are typed
+// (uint32 chromosome, uint64 position, utf8 alleles) and every statistic column is a
+// nullable float64 with null for missing values.
+func ArrowBytes(partitions [][][]byte, delimiter string, file bool) ([]byte, error) {
+	tables, names, err := typedSummaryTables(partitions, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	columns := arrowSchemaColumns(names)
+
+	var out []byte
+	if file {
+		out = append(out, arrowMagic...)
+		out = append(out, 0, 0)
+	}
+	out, _ = appendArrowMessage(out, arrowMessage(arrowHeaderSchema, arrowSchema(columns), 0), nil)
+
+	var blocks []arrowBlock
+	for _, table := range tables {
+		metadata, body := arrowRecordBatch(table)
+		var block arrowBlock
+		out, block = appendArrowMessage(out, metadata, body)
+		blocks = append(blocks, block)
+	}
+	out = binary.LittleEndian.AppendUint32(out, arrowContinuationMarker)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	if !file {
+		return out, nil
+	}
+
+	footer := fbFinish([]*fbField{
+		fbScalar(2, arrowMetadataV5),
+		fbChild(func(b *fbBuilder) int { return b.table(arrowSchema(columns)) }),
+		fbChild(func(b *fbBuilder) int { return b.structVector(0, 24, nil) }),
+		fbChild(func(b *fbBuilder) int {
+			return b.structVector(len(blocks), 24, func(i int, element []byte) {
+				binary.LittleEndian.PutUint64(element, uint64(blocks[i].offset))
+				binary.LittleEndian.PutUint32(element[8:], uint32(blocks[i].metadataLength))
+				binary.LittleEndian.PutUint64(element[16:], uint64(blocks[i].bodyLength))
+			})
+		}),
+	})
+	out = append(out, footer...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(footer)))
+	out = append(out, arrowMagic...)
+	return out, nil
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// fbTableField returns the position of a field within a flatbuffer table, 0 when absent.
+func fbTableField(buf []byte, table int, field int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(buf[table:])))
+	if 4+2*field >= int(binary.LittleEndian.Uint16(buf[vtable:])) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(buf[vtable+4+2*field:]))
+}
+
+func fbDeref(buf []byte, at int) int {
+	return at + int(binary.LittleEndian.Uint32(buf[at:]))
+}
+
+// arrowSchemaFieldNames decodes the field names of the first message of a stream.
+func arrowSchemaFieldNames(t *testing.T, stream []byte) []string {
+	t.Helper()
+	if binary.LittleEndian.Uint32(stream) != arrowContinuationMarker {
+		t.Fatalf("stream does not start with a continuation marker")
+	}
+	length := int(binary.LittleEndian.Uint32(stream[4:]))
+	if (8+length)%8 != 0 {
+		t.Errorf("schema message length %d is not padded", length)
+	}
+	buf := stream[8 : 8+length]
+	message := fbDeref(buf, 0)
+	if version := binary.LittleEndian.Uint16(buf[message+fbTableField(buf, message, 0):]); version != arrowMetadataV5 {
+		t.Errorf("message version = %d, want %d", version, arrowMetadataV5)
+	}
+	if headerType := buf[message+fbTableField(buf, message, 1)]; headerType != arrowHeaderSchema {
+		t.Fatalf("message header type = %d, want schema", headerType)
+	}
+	schema := fbDeref(buf, message+fbTableField(buf, message, 2))
+	fields := fbDeref(buf, schema+fbTableField(buf, schema, 1))
+	var names []string
+	for i := 0; i < int(binary.LittleEndian.Uint32(buf[fields:])); i++ {
+		field := fbDeref(buf, fields+4+4*i)
+		name := fbDeref(buf, field+fbTableField(buf, field, 0))
+		nameLength := int(binary.LittleEndian.Uint32(buf[name:]))
+		names = append(names, string(buf[name+4:name+4+nameLength]))
+	}
+	return names
+}
+
+func TestArrowBytes(t *testing.T) {
+	partitions := [][][]byte{testMergedBlocks(t)}
+	wantNames := []string{
+		"chromosome", "position", "reference", "alternative",
+		"study_1_pval", "study_1_beta", "study_1_sebeta", "study_1_af",
+		"study2_pval", "study2_beta", "study2_sebeta", "study2_af",
+	}
+
+	stream, err := ArrowBytes(partitions, "\t", false)
+	if err != nil {
+		t.Fatalf("ArrowBytes() unexpected error: %v", err)
+	}
+	if names := arrowSchemaFieldNames(t, stream); !slices.Equal(names, wantNames) {
+		t.Errorf("schema fields = %q, want %q", names, wantNames)
+	}
+	if eos := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}; !bytes.HasSuffix(stream, eos) {
+		t.Errorf("stream does not end with an end-of-stream marker")
+	}
+	// study_1 beta of 1:12345 is the only -0.5 in the batch
+	if !bytes.Contains(stream, binary.LittleEndian.AppendUint64(nil, math.Float64bits(-0.5))) {
+		t.Errorf("stream does not contain the study_1 beta")
+	}
+
+	file, err := ArrowBytes(partitions, "\t", true)
+	if err != nil {
+		t.Fatalf("ArrowBytes() unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(file, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(file, []byte("ARROW1")) {
+		t.Errorf("file is not framed by the Arrow magic")
+	}
+	if !bytes.Equal(file[8:8+len(stream)], stream) {
+		t.Errorf("file does not embed the stream")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-10:]))
+	if got := 8 + len(stream) + footerLength + 10; got != len(file) {
+		t.Errorf("footer length %d does not account for the file size", footerLength)
+	}
+}
+
+func TestArrowBytesErrors(t *testing.T) {
+	study, err := proto.Marshal(&SummaryRows{Header: CreateHeader("study", nil)})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	other, err := proto.Marshal(&SummaryRows{Header: CreateHeader("other", nil)})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	invalid, err := proto.Marshal(&SummaryRows{
+		Header: CreateHeader("study", nil),
+		Rows:   map[string]*SummaryValues{"1\t1\tA\tT": {Values: []string{"x", "0.1", "0.1", "0.1"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	tests := []struct {
+		name       string
+		partitions [][][]byte
+	}{
+		{"no partitions", nil},
+		{"mismatched partitions", [][][]byte{{study}, {other}}},
+		{"non numeric value", [][][]byte{{invalid}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ArrowBytes(tt.partitions, "\t", false); err == nil {
+				t.Errorf("ArrowBytes() expected error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"math"
+)
+
+// ConcordanceConfiguration selects the variants and tags of PairwiseConcordance.
+// Lead variants are those with a p-value below PvalThreshold in at least one tag of
+// a pair, every variant with estimates in both tags when it is zero. Tags restricts
+// the report to the listed tags, every tag of the blocks when empty.
+type ConcordanceConfiguration struct {
+	PvalThreshold float64  `json:"pval_threshold,omitempty" validate:"gte=0,lte=1"`
+	Tags          []string `json:"tags,omitempty" validate:"dive,required"`
+}
+
+// ConcordanceReport holds the concordance of every pair of tags.
+type ConcordanceReport struct {
+	Pairs []TagConcordance `json:"pairs"`
+}
+
+// TagConcordance compares the betas of the lead variants shared by two tags.
+// SignBinomialPValue is the one-sided probability of at least ConcordantSigns
+// concordant signs among the variants with non zero betas if signs agreed by chance.
+// Slope, SlopeSE and Intercept are the least squares regression of the betas of
+// TagB on those of TagA; DemingSlope accounts for the error in both, with the ratio
+// of the error variances taken from the mean squared standard errors, and its
+// standard error is the jackknife one. Statistics that cannot be computed are null.
+type TagConcordance struct {
+	TagA               string   `json:"tag_a"`
+	TagB               string   `json:"tag_b"`
+	SharedVariants     int      `json:"shared_variants"`
+	ConcordantSigns    int      `json:"concordant_signs"`
+	SignConcordance    *float64 `json:"sign_concordance"`
+	SignBinomialPValue *float64 `json:"sign_binomial_pval"`
+	Correlation        *float64 `json:"correlation"`
+	Slope              *float64 `json:"slope"`
+	SlopeSE            *float64 `json:"slope_se"`
+	Intercept          *float64 `json:"intercept"`
+	DemingSlope        *float64 `json:"deming_slope"`
+	DemingSlopeSE      *float64 `json:"deming_slope_se"`
+}
+
+// concordancePoint is one shared lead variant of a pair of tags.
+type concordancePoint struct {
+	betaA, sebetaA, betaB, sebetaB float64
+}
+
+// PairwiseConcordance compares every pair of tags over the blocks of all partitions,
+// partitions[partition][source] as given to SummaryBytesString per partition.
+func PairwiseConcordance(partitions [][][]byte, configuration ConcordanceConfiguration) (ConcordanceReport, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return ConcordanceReport{}, err
+	}
+	// tags are reported in the order they are first seen, a partition may lack some
+	var names []string
+	index := make(map[string]int)
+	points := make(map[[2]string][]concordancePoint)
+	for _, buffer := range partitions {
+		rows, err := unmarshalSummaryRows(buffer)
+		if err != nil {
+			return ConcordanceReport{}, err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		tags, err := selectTags(rows, configuration.Tags)
+		if err != nil {
+			return ConcordanceReport{}, err
+		}
+		for _, tag := range tags {
+			if _, ok := index[tag.Tag]; !ok {
+				index[tag.Tag] = len(names)
+				names = append(names, tag.Tag)
+			}
+		}
+		for _, variant := range summaryVariants(rows) {
+			for i := range tags {
+				for j := i + 1; j < len(tags); j++ {
+					a, b := tags[i], tags[j]
+					if index[a.Tag] > index[b.Tag] {
+						a, b = b, a
+					}
+					point, ok := concordanceEstimate(rows, a, b, variant, configuration.PvalThreshold)
+					if ok {
+						pair := [2]string{a.Tag, b.Tag}
+						points[pair] = append(points[pair], point)
+					}
+				}
+			}
+		}
+	}
+
+	report := ConcordanceReport{Pairs: []TagConcordance{}}
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			report.Pairs = append(report.Pairs, tagConcordance(names[i], names[j], points[[2]string{names[i], names[j]}]))
+		}
+	}
+	return report, nil
+}
+
+// concordanceEstimate returns the estimates of a variant in both tags when it is a
+// lead variant of the pair.
+func concordanceEstimate(rows []*SummaryRows, a summaryTag, b summaryTag, variant string, threshold float64) (concordancePoint, bool) {
+	var point concordancePoint
+	var ok bool
+	if point.betaA, ok = a.float(rows, variant, StatisticBeta); !ok {
+		return point, false
+	}
+	if point.sebetaA, ok = a.float(rows, variant, StatisticSEBeta); !ok {
+		return point, false
+	}
+	if point.betaB, ok = b.float(rows, variant, StatisticBeta); !ok {
+		return point, false
+	}
+	if point.sebetaB, ok = b.float(rows, variant, StatisticSEBeta); !ok {
+		return point, false
+	}
+	if threshold > 0 {
+		pvalA, okA := a.float(rows, variant, StatisticPValue)
+		pvalB, okB := b.float(rows, variant, StatisticPValue)
+		if !(okA && pvalA < threshold) && !(okB && pvalB < threshold) {
+			return point, false
+		}
+	}
+	return point, true
+}
+
+// tagConcordance summarises the shared lead variants of two tags.
+func tagConcordance(tagA string, tagB string, points []concordancePoint) TagConcordance {
+	result := TagConcordance{TagA: tagA, TagB: tagB, SharedVariants: len(points)}
+	signed := 0
+	for _, point := range points {
+		if point.betaA == 0 || point.betaB == 0 {
+			continue
+		}
+		signed++
+		if (point.betaA > 0) == (point.betaB > 0) {
+			result.ConcordantSigns++
+		}
+	}
+	if signed > 0 {
+		result.SignConcordance = finite(float64(result.ConcordantSigns) / float64(signed))
+		result.SignBinomialPValue = finite(binomialUpperTail(result.ConcordantSigns, signed))
+	}
+
+	sums := newRegressionSums(points)
+	if len(points) >= 2 {
+		result.Correlation = finite(sums.sxy / math.Sqrt(sums.sxx*sums.syy))
+		slope := sums.sxy / sums.sxx
+		result.Slope = finite(slope)
+		result.Intercept = finite(sums.meanB - slope*sums.meanA)
+		result.DemingSlope = finite(sums.demingSlope())
+	}
+	if len(points) >= 3 {
+		slope := sums.sxy / sums.sxx
+		residual := (sums.syy - slope*sums.sxy) / float64(len(points)-2)
+		result.SlopeSE = finite(math.Sqrt(max(0, residual) / sums.sxx))
+		result.DemingSlopeSE = finite(jackknifeDemingSE(points))
+	}
+	return result
+}
+
+// regressionSums are the moments of the betas of a pair of tags.
+type regressionSums struct {
+	n, meanA, meanB, sxx, syy, sxy, varianceA, varianceB float64
+}
+
+func newRegressionSums(points []concordancePoint) regressionSums {
+	var sums regressionSums
+	sums.n = float64(len(points))
+	for _, point := range points {
+		sums.meanA += point.betaA
+		sums.meanB += point.betaB
+		sums.varianceA += point.sebetaA * point.sebetaA
+		sums.varianceB += point.sebetaB * point.sebetaB
+	}
+	sums.meanA /= sums.n
+	sums.meanB /= sums.n
+	for _, point := range points {
+		da, db := point.betaA-sums.meanA, point.betaB-sums.meanB
+		sums.sxx += da * da
+		sums.syy += db * db
+		sums.sxy += da * db
+	}
+	return sums
+}
+
+// demingSlope is the Deming regression slope with the ratio of the error variances
+// of the betas of TagB to those of TagA estimated from their standard errors.
+func (sums regressionSums) demingSlope() float64 {
+	delta := sums.varianceB / sums.varianceA
+	d := sums.syy - delta*sums.sxx
+	return (d + math.Sqrt(d*d+4*delta*sums.sxy*sums.sxy)) / (2 * sums.sxy)
+}
+
+// jackknifeDemingSE is the leave-one-out jackknife standard error of the Deming slope.
+func jackknifeDemingSE(points []concordancePoint) float64 {
+	n := float64(len(points))
+	slopes := make([]float64, len(points))
+	var mean float64
+	rest := make([]concordancePoint, 0, len(points)-1)
+	for i := range points {
+		rest = append(append(rest[:0], points[:i]...), points[i+1:]...)
+		slopes[i] = newRegressionSums(rest).demingSlope()
+		mean += slopes[i]
+	}
+	mean /= n
+	var squares float64
+	for _, slope := range slopes {
+		squares += (slope - mean) * (slope - mean)
+	}
+	return math.Sqrt((n - 1) / n * squares)
+}
+
+// binomialUpperTail is the probability of at least k successes in n fair trials.
+func binomialUpperTail(k int, n int) float64 {
+	lgammaN, _ := math.Lgamma(float64(n + 1))
+	var p float64
+	for i := k; i <= n; i++ {
+		lgammaI, _ := math.Lgamma(float64(i + 1))
+		lgammaRest, _ := math.Lgamma(float64(n - i + 1))
+		p += math.Exp(lgammaN - lgammaI - lgammaRest - float64(n)*math.Ln2)
+	}
+	return min(1, p)
+}
+
+// finite returns a pointer to v, nil when v is not a finite number.
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// testConcordancePartitions returns two partitions of tags a, b and c. Variants
+// 1:600 and 1:700 are not lead variants of a and b at 0.01, 1:800 has no beta in b
+// and c only has estimates for 1:100.
+func testConcordancePartitions(t *testing.T) [][][]byte {
+	t.Helper()
+	block := func(tag string, rows map[string]*SummaryValues) []byte {
+		data, err := proto.Marshal(&SummaryRows{Header: CreateHeader(tag, nil), Rows: rows})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return data
+	}
+	values := func(values ...string) *SummaryValues { return &SummaryValues{Values: values} }
+	return [][][]byte{
+		{
+			block("a", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("1e-5", "0.20", "0.04", "0.1"),
+				"1\t200\tA\tT": values("1e-4", "-0.10", "0.03", "0.1"),
+				"1\t300\tA\tT": values("0.5", "0.05", "0.05", "0.1"),
+				"1\t600\tA\tT": values("0.2", "0.05", "0.05", "0.1"),
+			}),
+			block("b", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("1e-3", "0.15", "0.05", "0.1"),
+				"1\t200\tA\tT": values("0.2", "-0.02", "0.06", "0.1"),
+				"1\t300\tA\tT": values("1e-6", "0.30", "0.05", "0.1"),
+				"1\t600\tA\tT": values("0.3", "-0.05", "0.05", "0.1"),
+			}),
+			block("c", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("1e-3", "0.15", "0.05", "0.1"),
+			}),
+		},
+		{
+			block("a", map[string]*SummaryValues{
+				"1\t400\tA\tT": values("1e-8", "0.40", "0.06", "0.1"),
+				"1\t500\tA\tT": values("0.003", "0.12", "0.04", "0.1"),
+				"1\t700\tA\tT": values("0.5", "0.01", "0.05", "0.1"),
+				"1\t800\tA\tT": values("1e-9", "0.5", "0.05", "0.1"),
+			}),
+			block("b", map[string]*SummaryValues{
+				"1\t400\tA\tT": values("1e-7", "0.35", "0.07", "0.1"),
+				"1\t500\tA\tT": values("0.04", "-0.05", "0.05", "0.1"),
+				"1\t700\tA\tT": values("0.6", "0.02", "0.05", "0.1"),
+				"1\t800\tA\tT": values("1e-9", "NA", "0.05", "0.1"),
+			}),
+			block("c", map[string]*SummaryValues{}),
+		},
+	}
+}
+
+func TestPairwiseConcordance(t *testing.T) {
+	report, err := PairwiseConcordance(testConcordancePartitions(t), ConcordanceConfiguration{PvalThreshold: 0.01})
+	if err != nil {
+		t.Fatalf("PairwiseConcordance() unexpected error: %v", err)
+	}
+	if len(report.Pairs) != 3 {
+		t.Fatalf("PairwiseConcordance() returned %d pairs, want 3", len(report.Pairs))
+	}
+	pair := report.Pairs[0]
+	if pair.TagA != "a" || pair.TagB != "b" || pair.SharedVariants != 5 || pair.ConcordantSigns != 4 {
+		t.Errorf("pair = %s/%s with %d shared and %d concordant, want a/b with 5 and 4",
+			pair.TagA, pair.TagB, pair.SharedVariants, pair.ConcordantSigns)
+	}
+	// Expected values computed independently from the definitions
+	expected := []struct {
+		name  string
+		value *float64
+		want  float64
+	}{
+		{"sign_concordance", pair.SignConcordance, 0.8},
+		{"sign_binomial_pval", pair.SignBinomialPValue, 0.1875},
+		{"correlation", pair.Correlation, 0.6198735090450775},
+		{"slope", pair.Slope, 0.6066219369894981},
+		{"slope_se", pair.SlopeSE, 0.44336257138312396},
+		{"intercept", pair.Intercept, 0.06471266044340725},
+		{"deming_slope", pair.DemingSlope, 0.8463366394779833},
+		{"deming_slope_se", pair.DemingSlopeSE, 0.4399853548439436},
+	}
+	for _, e := range expected {
+		if e.value == nil || math.Abs(*e.value-e.want) > 1e-12 {
+			t.Errorf("%s = %v, want %g", e.name, e.value, e.want)
+		}
+	}
+
+	// A single shared variant has a sign but no regression
+	pair = report.Pairs[1]
+	if pair.TagA != "a" || pair.TagB != "c" || pair.SharedVariants != 1 || pair.Correlation != nil || pair.SignConcordance == nil {
+		t.Errorf("pair a/c = %+v, want one shared variant without regression", pair)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	var decoded ConcordanceReport
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Pairs[1].Slope != nil {
+		t.Errorf("JSON round trip = %s, %v", data, err)
+	}
+}
+
+func TestPairwiseConcordanceTags(t *testing.T) {
+	report, err := PairwiseConcordance(testConcordancePartitions(t), ConcordanceConfiguration{Tags: []string{"b", "a"}})
+	if err != nil {
+		t.Fatalf("PairwiseConcordance() unexpected error: %v", err)
+	}
+	// Without a threshold 1:600 and 1:700 are shared as well
+	if len(report.Pairs) != 1 || report.Pairs[0].TagA != "b" || report.Pairs[0].SharedVariants != 7 {
+		t.Errorf("PairwiseConcordance() = %+v, want b/a with 7 shared variants", report.Pairs)
+	}
+
+	if _, err := PairwiseConcordance(testConcordancePartitions(t), ConcordanceConfiguration{Tags: []string{"x"}}); err == nil {
+		t.Error("PairwiseConcordance() expected error for unknown tag, got none")
+	}
+	if _, err := PairwiseConcordance(nil, ConcordanceConfiguration{PvalThreshold: 2}); err == nil {
+		t.Error("PairwiseConcordance() expected error for invalid threshold, got none")
+	}
+}
+
+func TestPairwiseConcordanceTagInLaterPartition(t *testing.T) {
+	partitions := testConcordancePartitions(t)
+	// d only has blocks in the second partition, before a and b
+	data, err := proto.Marshal(&SummaryRows{Header: CreateHeader("d", nil), Rows: map[string]*SummaryValues{
+		"1\t400\tA\tT": {Values: []string{"1e-6", "0.30", "0.06", "0.1"}},
+		"1\t500\tA\tT": {Values: []string{"1e-4", "0.10", "0.04", "0.1"}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	partitions[1] = append([][]byte{data}, partitions[1]...)
+
+	report, err := PairwiseConcordance(partitions, ConcordanceConfiguration{PvalThreshold: 0.01})
+	if err != nil {
+		t.Fatalf("PairwiseConcordance() unexpected error: %v", err)
+	}
+	// a, b and c first, then the pairs of d
+	if len(report.Pairs) != 6 {
+		t.Fatalf("PairwiseConcordance() = %+v, want 6 pairs", report.Pairs)
+	}
+	if ad := report.Pairs[2]; ad.TagA != "a" || ad.TagB != "d" || ad.SharedVariants != 2 || ad.ConcordantSigns != 2 {
+		t.Errorf("pair a/d = %+v, want 2 concordant shared variants", ad)
+	}
+}
+
+func TestBinomialUpperTail(t *testing.T) {
+	tests := []struct {
+		k, n     int
+		expected float64
+	}{
+		{0, 10, 1},
+		{10, 10, 1.0 / 1024},
+		{7, 10, 176.0 / 1024},
+		{60, 100, 0.028443966820490392},
+	}
+	for _, tt := range tests {
+		if got := binomialUpperTail(tt.k, tt.n); math.Abs(got-tt.expected) > 1e-12 {
+			t.Errorf("binomialUpperTail(%d, %d) = %g, want %g", tt.k, tt.n, got, tt.expected)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package lib
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -24,18 +25,63 @@ type FileColumns[T any] struct {
 type FileColumnsIndex = FileColumns[int]
 type FileColumnsDefinition = FileColumns[string]
 
+// FileConfiguration describes how to read one summary statistics file.
 type FileConfiguration struct {
 	Tag string `json:"tag" validate:"required"`
 	FileColumnsDefinition
 	PvalThreshold float32 `json:"pval_threshold" validate:"required"`
 	Delimiter     string  `json:"delimiter" validate:"required"`
+	// Headerless files have no header row, columns are given as 0-based positions
+	Headerless bool `json:"headerless"`
+	// CommentPrefixes are skipped besides the "##" metadata lines
+	CommentPrefixes []string `json:"comment_prefixes,omitempty" validate:"dive,required"`
+	// PhenotypeColumn splits a long format file into the tags "<tag>_<phenotype>"
+	PhenotypeColumn string `json:"phenotype_column,omitempty"`
+	// Phenotypes restricts a long format file to the listed phenotypes
+	Phenotypes []string `json:"phenotypes,omitempty" validate:"omitempty,excluded_without=PhenotypeColumn,dive,required"`
+	// ColumnNaming names the statistic columns in the output, "<tag>_<statistic>" when unset
+	ColumnNaming *ColumnNaming `json:"column_naming,omitempty"`
+	// SampleSizeColumn holds the sample size of each variant, reported as "<tag>_n"
+	SampleSizeColumn string `json:"sample_size_column,omitempty"`
+	// SampleSize is the sample size of the whole file when there is no such column
+	SampleSize uint64 `json:"sample_size,omitempty"`
 }
 
+// FileMetadata holds the "##key=value" lines found before the header.
+// Well known keys are lifted into typed fields, every line is kept in Fields.
+type FileMetadata struct {
+	GenomeBuild string              `json:"genome_build,omitempty"`
+	SampleSize  uint64              `json:"sample_size,omitempty"`
+	Fields      map[string][]string `json:"fields,omitempty"`
+}
+
+// BlockMetadata is the resolved form of a FileConfiguration used by the parsers.
 type BlockMetadata struct {
 	Tag string `json:"tag" validate:"required"`
 	FileColumnsIndex
 	PvalThreshold float32 `json:"pval_threshold" validate:"required"`
 	Delimiter     string  `json:"delimiter" validate:"required"`
+	// Headerless tells the caller to pass the first line to the parsers as data
+	Headerless bool `json:"headerless"`
+	// HeaderOffset is the number of bytes taken up by metadata, comments and the header
+	HeaderOffset    int          `json:"header_offset"`
+	CommentPrefixes []string     `json:"comment_prefixes,omitempty"`
+	FileMetadata    FileMetadata `json:"file_metadata"`
+	// FileFormat is empty for delimited files and FileFormatGWASVCF for VCF files
+	FileFormat string      `json:"file_format,omitempty"`
+	VCFSamples []VCFSample `json:"vcf_samples,omitempty"`
+	// VCFSampleSize adds the SS FORMAT field of every sample as its "<tag>_n" column
+	VCFSampleSize   bool     `json:"vcf_sample_size,omitempty"`
+	ColumnPhenotype *int     `json:"phenotype_column,omitempty"`
+	Phenotypes      []string `json:"phenotypes,omitempty"`
+	// MissingValues skip rows as p-value and are reported as NA for other statistics
+	MissingValues []string      `json:"missing_values,omitempty"`
+	ColumnNaming  *ColumnNaming `json:"column_naming,omitempty"`
+	// ColumnSampleSize and SampleSize are the resolved sample size of FileConfiguration
+	ColumnSampleSize *int   `json:"sample_size_column,omitempty"`
+	SampleSize       uint64 `json:"sample_size,omitempty"`
+	// GenomicControl maps tags to the lambda their statistics are corrected by
+	GenomicControl map[string]float64 `json:"genomic_control,omitempty"`
 }
 
 type VariantPartitions = [][]string
@@ -53,10 +99,22 @@ func ParseFileConfiguration(data []byte, logger func(string)) (FileConfiguration
 	return fileConfiguration, nil
 }
 
+// CreateFileColumnsIndex resolves the configured columns against the start of the file.
+// Leading metadata and comment lines are skipped and the first remaining line is
+// used as the header. For headerless files that line is a data row and is only
+// used to check that the configured positions exist.
 func CreateFileColumnsIndex(header []byte, configuration FileConfiguration) (BlockMetadata, error) {
 	delimiter := configuration.Delimiter
+	headerLine, lineStart, lineEnd, fileMetadata := splitPreamble(header, configuration.CommentPrefixes)
+	headerOffset := lineEnd
+	if configuration.Headerless {
+		headerOffset = lineStart
+	}
 	// Parse header to get column names
-	headerStr := strings.TrimSpace(string(header))
+	headerStr := strings.TrimSpace(string(headerLine))
+	if headerStr == "" {
+		return BlockMetadata{}, fmt.Errorf("header line not found")
+	}
 	columns := strings.Split(headerStr, string(delimiter))
 
 	// Create map of column name to index
@@ -72,6 +130,18 @@ func CreateFileColumnsIndex(header []byte, configuration FileConfiguration) (Blo
 		}
 		return -1, fmt.Errorf("column %q not found in header", columnName)
 	}
+	if configuration.Headerless {
+		findColumn = func(columnName string) (int, error) {
+			idx, err := strconv.Atoi(strings.TrimSpace(columnName))
+			if err != nil || idx < 0 {
+				return -1, fmt.Errorf("column %q is not a valid position", columnName)
+			}
+			if idx >= len(columns) {
+				return -1, fmt.Errorf("column position %d out of range: first line has %d columns", idx, len(columns))
+			}
+			return idx, nil
+		}
+	}
 
 	// Find all required columns
 	chromIdx, err := findColumn(configuration.ColumnChromosome)
@@ -114,11 +184,38 @@ func CreateFileColumnsIndex(header []byte, configuration FileConfiguration) (Blo
 		return BlockMetadata{}, err
 	}
 
+	var phenotypeIdx *int
+	if configuration.PhenotypeColumn != "" {
+		idx, err := findColumn(configuration.PhenotypeColumn)
+		if err != nil {
+			return BlockMetadata{}, err
+		}
+		phenotypeIdx = &idx
+	}
+
+	var sampleSizeIdx *int
+	if configuration.SampleSizeColumn != "" {
+		idx, err := findColumn(configuration.SampleSizeColumn)
+		if err != nil {
+			return BlockMetadata{}, err
+		}
+		sampleSizeIdx = &idx
+	}
+
 	// Create and return BlockMetadata
 	return BlockMetadata{
-		Tag:           configuration.Tag,
-		PvalThreshold: configuration.PvalThreshold,
-		Delimiter:     delimiter,
+		Tag:              configuration.Tag,
+		PvalThreshold:    configuration.PvalThreshold,
+		Delimiter:        delimiter,
+		Headerless:       configuration.Headerless,
+		HeaderOffset:     headerOffset,
+		CommentPrefixes:  configuration.CommentPrefixes,
+		FileMetadata:     fileMetadata,
+		ColumnPhenotype:  phenotypeIdx,
+		Phenotypes:       configuration.Phenotypes,
+		ColumnNaming:     configuration.ColumnNaming,
+		ColumnSampleSize: sampleSizeIdx,
+		SampleSize:       configuration.SampleSize,
 		FileColumnsIndex: FileColumnsIndex{
 			ColumnChromosome:      chromIdx,
 			ColumnPosition:        posIdx,
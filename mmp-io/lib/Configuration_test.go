@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -386,3 +387,334 @@ func TestCreateFileColumnsIndex_HeaderWithWhitespace(t *testing.T) {
 		t.Errorf("Expected ColumnChromosome index 0, got %d", metadata.ColumnChromosome)
 	}
 }
+
+func TestCreateFileColumnsIndex_Headerless(t *testing.T) {
+	firstLine := []byte("1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3")
+	config := FileConfiguration{
+		Tag: "shard",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome:      "0",
+			ColumnPosition:        "1",
+			ColumnReference:       "2",
+			ColumnAlternate:       "3",
+			ColumnPValue:          "4",
+			ColumnBeta:            "5",
+			ColumnSEBeta:          "6",
+			ColumnAlleleFrequency: "7",
+		},
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+		Headerless:    true,
+	}
+
+	metadata, err := CreateFileColumnsIndex(firstLine, config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !metadata.Headerless {
+		t.Error("Expected Headerless to be carried into metadata")
+	}
+	if metadata.ColumnChromosome != 0 || metadata.ColumnPValue != 4 || metadata.ColumnAlleleFrequency != 7 {
+		t.Errorf("Unexpected column positions: %+v", metadata.FileColumnsIndex)
+	}
+
+	// The first line is data, so it must parse like any other row
+	variants, err := BufferVariants(firstLine, metadata)
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	if len(variants) != 1 || variants[0] != "1\t12345\tA\tT" {
+		t.Errorf("BufferVariants() = %v, want [1\\t12345\\tA\\tT]", variants)
+	}
+}
+
+func TestCreateFileColumnsIndex_HeaderlessInvalidPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		position string
+	}{
+		{"not a number", "PVAL"},
+		{"negative", "-1"},
+		{"out of range", "8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := FileConfiguration{
+				Tag: "shard",
+				FileColumnsDefinition: FileColumnsDefinition{
+					ColumnChromosome:      "0",
+					ColumnPosition:        "1",
+					ColumnReference:       "2",
+					ColumnAlternate:       "3",
+					ColumnPValue:          tt.position,
+					ColumnBeta:            "5",
+					ColumnSEBeta:          "6",
+					ColumnAlleleFrequency: "7",
+				},
+				PvalThreshold: 0.05,
+				Delimiter:     "\t",
+				Headerless:    true,
+			}
+			_, err := CreateFileColumnsIndex([]byte("1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3"), config)
+			if err == nil {
+				t.Errorf("Expected error for %s, got none", tt.name)
+			}
+		})
+	}
+}
+
+func TestCreateFileColumnsIndex_MetadataAndComments(t *testing.T) {
+	preamble := "##genome_build=GRCh38\n" +
+		"##sample_size=412181\n" +
+		"##contig=<ID=1>\n" +
+		"##contig=<ID=2>\n" +
+		"// exported by tool\n" +
+		"CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\n"
+	data := "1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3\n"
+	config := FileConfiguration{
+		Tag: "ssf",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome:      "CHR",
+			ColumnPosition:        "POS",
+			ColumnReference:       "REF",
+			ColumnAlternate:       "ALT",
+			ColumnPValue:          "PVAL",
+			ColumnBeta:            "BETA",
+			ColumnSEBeta:          "SE",
+			ColumnAlleleFrequency: "AF",
+		},
+		PvalThreshold:   0.05,
+		Delimiter:       "\t",
+		CommentPrefixes: []string{"//"},
+	}
+
+	metadata, err := CreateFileColumnsIndex([]byte(preamble+data), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if metadata.ColumnPValue != 4 {
+		t.Errorf("Expected ColumnPValue index 4, got %d", metadata.ColumnPValue)
+	}
+	if metadata.HeaderOffset != len(preamble) {
+		t.Errorf("Expected HeaderOffset %d, got %d", len(preamble), metadata.HeaderOffset)
+	}
+	if metadata.FileMetadata.GenomeBuild != "GRCh38" {
+		t.Errorf("Expected genome build 'GRCh38', got '%s'", metadata.FileMetadata.GenomeBuild)
+	}
+	if metadata.FileMetadata.SampleSize != 412181 {
+		t.Errorf("Expected sample size 412181, got %d", metadata.FileMetadata.SampleSize)
+	}
+	if contigs := metadata.FileMetadata.Fields["contig"]; len(contigs) != 2 || contigs[1] != "<ID=2>" {
+		t.Errorf("Expected both contig lines to be kept, got %v", contigs)
+	}
+}
+
+// TestCreateFileColumnsIndex_ReaderChunks reads a file the way readFileInBlocks
+// passes it: the metadata and comment lines with the header as the first chunk,
+// the data lines in the chunks that follow.
+func TestCreateFileColumnsIndex_ReaderChunks(t *testing.T) {
+	chunks := []string{
+		"##genome_build=GRCh38\n##sample_size=1000\n// exported by tool\nCHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\n",
+		"1\t100\tA\tT\t0.001\t0.5\t0.1\t0.3\n1\t200\tG\tC\t0.002\t0.4\t0.1\t0.2\n",
+		"2\t300\tC\tG\t0.003\t0.3\t0.1\t0.1\n",
+	}
+	config := FileConfiguration{
+		Tag: "ssf",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome:      "CHR",
+			ColumnPosition:        "POS",
+			ColumnReference:       "REF",
+			ColumnAlternate:       "ALT",
+			ColumnPValue:          "PVAL",
+			ColumnBeta:            "BETA",
+			ColumnSEBeta:          "SE",
+			ColumnAlleleFrequency: "AF",
+		},
+		PvalThreshold:   0.05,
+		Delimiter:       "\t",
+		CommentPrefixes: []string{"//"},
+	}
+
+	metadata, err := CreateFileColumnsIndex([]byte(chunks[0]), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if metadata.HeaderOffset != len(chunks[0]) {
+		t.Errorf("Expected HeaderOffset %d, got %d", len(chunks[0]), metadata.HeaderOffset)
+	}
+	if metadata.FileMetadata.SampleSize != 1000 {
+		t.Errorf("Expected sample size 1000, got %d", metadata.FileMetadata.SampleSize)
+	}
+	var variants []string
+	for _, chunk := range chunks[1:] {
+		result, err := BufferVariants([]byte(chunk), metadata)
+		if err != nil {
+			t.Fatalf("BufferVariants() unexpected error: %v", err)
+		}
+		variants = append(variants, result...)
+	}
+	expected := []string{"1\t100\tA\tT", "1\t200\tG\tC", "2\t300\tC\tG"}
+	if strings.Join(variants, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected variants %q, got %q", expected, variants)
+	}
+
+	// The first line alone holds no header
+	if _, err := CreateFileColumnsIndex([]byte("##genome_build=GRCh38\n"), config); err == nil {
+		t.Error("Expected error for a first chunk without the header line, got none")
+	}
+}
+
+func TestCreateFileColumnsIndex_HeaderlessOffset(t *testing.T) {
+	preamble := "##source=split\n"
+	config := FileConfiguration{
+		Tag: "shard",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome:      "0",
+			ColumnPosition:        "1",
+			ColumnReference:       "2",
+			ColumnAlternate:       "3",
+			ColumnPValue:          "4",
+			ColumnBeta:            "5",
+			ColumnSEBeta:          "6",
+			ColumnAlleleFrequency: "7",
+		},
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+		Headerless:    true,
+	}
+
+	metadata, err := CreateFileColumnsIndex([]byte(preamble+"1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3\n"), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if metadata.HeaderOffset != len(preamble) {
+		t.Errorf("Expected HeaderOffset %d, got %d", len(preamble), metadata.HeaderOffset)
+	}
+}
+
+func TestCreateFileColumnsIndex_OnlyComments(t *testing.T) {
+	config := FileConfiguration{
+		Tag: "test",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome:      "CHR",
+			ColumnPosition:        "POS",
+			ColumnReference:       "REF",
+			ColumnAlternate:       "ALT",
+			ColumnPValue:          "PVAL",
+			ColumnBeta:            "BETA",
+			ColumnSEBeta:          "SE",
+			ColumnAlleleFrequency: "AF",
+		},
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+	}
+
+	if _, err := CreateFileColumnsIndex([]byte("##genome_build=GRCh37\n"), config); err == nil {
+		t.Error("Expected error when no header line is present, got none")
+	}
+}
+
+func TestCreateFileColumnsIndex_PhenotypeColumn(t *testing.T) {
+	validJSON := []byte(`{
+		"tag": "phewas",
+		"chromosomeColumn": "CHR",
+		"positionColumn": "POS",
+		"referenceColumn": "REF",
+		"alternativeColumn": "ALT",
+		"pValueColumn": "PVAL",
+		"betaColumn": "BETA",
+		"sebetaColumn": "SE",
+		"afColumn": "AF",
+		"phenotype_column": "PHENO",
+		"phenotypes": ["T2D", "BMI"],
+		"pval_threshold": 0.05,
+		"delimiter": "\t"
+	}`)
+	config, err := ParseFileConfiguration(validJSON, func(string) {})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	metadata, err := CreateFileColumnsIndex([]byte("PHENO\tCHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF"), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if metadata.ColumnPhenotype == nil || *metadata.ColumnPhenotype != 0 {
+		t.Errorf("Expected ColumnPhenotype 0, got %v", metadata.ColumnPhenotype)
+	}
+	if len(metadata.Phenotypes) != 2 || metadata.Phenotypes[1] != "BMI" {
+		t.Errorf("Expected phenotypes [T2D BMI], got %v", metadata.Phenotypes)
+	}
+
+	config.PhenotypeColumn = "TRAIT"
+	if _, err := CreateFileColumnsIndex([]byte("PHENO\tCHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF"), config); err == nil {
+		t.Error("Expected error for missing phenotype column, got none")
+	}
+}
+
+func TestCreateFileColumnsIndex_SampleSize(t *testing.T) {
+	validJSON := []byte(`{
+		"tag": "study",
+		"chromosomeColumn": "CHR",
+		"positionColumn": "POS",
+		"referenceColumn": "REF",
+		"alternativeColumn": "ALT",
+		"pValueColumn": "PVAL",
+		"betaColumn": "BETA",
+		"sebetaColumn": "SE",
+		"afColumn": "AF",
+		"sample_size_column": "N",
+		"pval_threshold": 0.05,
+		"delimiter": "\t"
+	}`)
+	config, err := ParseFileConfiguration(validJSON, func(string) {})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	metadata, err := CreateFileColumnsIndex([]byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\tN"), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if metadata.ColumnSampleSize == nil || *metadata.ColumnSampleSize != 8 {
+		t.Errorf("Expected ColumnSampleSize 8, got %v", metadata.ColumnSampleSize)
+	}
+
+	config.SampleSizeColumn = "NEFF"
+	if _, err := CreateFileColumnsIndex([]byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\tN"), config); err == nil {
+		t.Error("Expected error for missing sample size column, got none")
+	}
+
+	config.SampleSizeColumn = ""
+	config.SampleSize = 5000
+	metadata, err = CreateFileColumnsIndex([]byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF"), config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if metadata.ColumnSampleSize != nil || metadata.SampleSize != 5000 {
+		t.Errorf("Expected constant sample size 5000, got column %v size %d", metadata.ColumnSampleSize, metadata.SampleSize)
+	}
+}
+
+func TestParseFileConfiguration_PhenotypesWithoutColumn(t *testing.T) {
+	invalidJSON := []byte(`{
+		"tag": "phewas",
+		"chromosomeColumn": "CHR",
+		"positionColumn": "POS",
+		"referenceColumn": "REF",
+		"alternativeColumn": "ALT",
+		"pValueColumn": "PVAL",
+		"betaColumn": "BETA",
+		"sebetaColumn": "SE",
+		"afColumn": "AF",
+		"phenotypes": ["T2D"],
+		"pval_threshold": 0.05,
+		"delimiter": "\t"
+	}`)
+	if _, err := ParseFileConfiguration(invalidJSON, func(string) {}); err == nil {
+		t.Error("Expected validation error for phenotypes without phenotype_column, got none")
+	}
+}
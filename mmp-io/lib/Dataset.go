@@ -0,0 +1,181 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DatasetConfiguration binds several files, typically one per chromosome, to one
+// tag and file configuration. The files are listed in Files or matched by Glob.
+type DatasetConfiguration struct {
+	FileConfiguration
+	Files []string `json:"files,omitempty" validate:"dive,required"`
+	Glob  string   `json:"glob,omitempty"`
+}
+
+// DatasetMetadata is the BlockMetadata shared by every shard of a dataset, with the
+// header offset of each shard in file order. It can be passed wherever a
+// BlockMetadata is expected.
+type DatasetMetadata struct {
+	BlockMetadata
+	ShardHeaderOffsets []int `json:"shard_header_offsets"`
+}
+
+func ParseDatasetConfiguration(data []byte, logger func(string)) (DatasetConfiguration, error) {
+	var datasetConfiguration DatasetConfiguration
+	if err := json.Unmarshal(data, &datasetConfiguration); err != nil {
+		logger(fmt.Sprintf("unmarshal error: %v", err))
+		return datasetConfiguration, err
+	}
+	if err := validate.Struct(datasetConfiguration); err != nil {
+		logger(fmt.Sprintf("validation error: %v", err))
+		return datasetConfiguration, err
+	}
+	if len(datasetConfiguration.Files) == 0 && datasetConfiguration.Glob == "" {
+		err := fmt.Errorf("dataset %q needs files or a glob", datasetConfiguration.Tag)
+		logger(fmt.Sprintf("validation error: %v", err))
+		return datasetConfiguration, err
+	}
+	return datasetConfiguration, nil
+}
+
+// compareNatural compares strings with runs of digits ordered by value, so that
+// "chr2" sorts before "chr10".
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		if unicode.IsDigit(rune(a[0])) && unicode.IsDigit(rune(b[0])) {
+			i := strings.IndexFunc(a, func(r rune) bool { return !unicode.IsDigit(r) })
+			if i < 0 {
+				i = len(a)
+			}
+			j := strings.IndexFunc(b, func(r rune) bool { return !unicode.IsDigit(r) })
+			if j < 0 {
+				j = len(b)
+			}
+			na, nb := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if c := len(na) - len(nb); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+// DatasetFiles returns the files of the dataset found among the available file
+// names, in natural order so that shards named by chromosome follow chromosome order.
+func DatasetFiles(configuration DatasetConfiguration, available []string) ([]string, error) {
+	var result []string
+	if configuration.Glob != "" {
+		for _, name := range available {
+			matched, err := path.Match(configuration.Glob, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", configuration.Glob, err)
+			}
+			if matched {
+				result = append(result, name)
+			}
+		}
+	}
+	for _, name := range configuration.Files {
+		if !slices.Contains(available, name) {
+			return nil, fmt.Errorf("file %q not found", name)
+		}
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no files match dataset %q", configuration.Tag)
+	}
+	slices.SortFunc(result, compareNatural)
+	return result, nil
+}
+
+// CreateDatasetColumnsIndex resolves the columns against the start of every shard,
+// given in file order, and checks that the shards agree on their header.
+func CreateDatasetColumnsIndex(headers [][]byte, configuration DatasetConfiguration) (DatasetMetadata, error) {
+	if len(headers) == 0 {
+		return DatasetMetadata{}, fmt.Errorf("dataset %q has no files", configuration.Tag)
+	}
+	var result DatasetMetadata
+	var firstColumns []string
+	for i, header := range headers {
+		metadata, err := CreateFileColumnsIndex(header, configuration.FileConfiguration)
+		if err != nil {
+			return DatasetMetadata{}, fmt.Errorf("shard %d: %w", i, err)
+		}
+		headerLine, _, _, _ := splitPreamble(header, configuration.CommentPrefixes)
+		columns := strings.Split(strings.TrimSpace(string(headerLine)), configuration.Delimiter)
+		for j := range columns {
+			columns[j] = strings.TrimSpace(columns[j])
+		}
+		if i == 0 {
+			result.BlockMetadata = metadata
+			firstColumns = columns
+		} else if configuration.Headerless && len(columns) != len(firstColumns) {
+			return DatasetMetadata{}, fmt.Errorf("shard %d has %d columns, shard 0 has %d", i, len(columns), len(firstColumns))
+		} else if !configuration.Headerless && !slices.Equal(columns, firstColumns) {
+			return DatasetMetadata{}, fmt.Errorf("shard %d header does not match shard 0", i)
+		}
+		result.ShardHeaderOffsets = append(result.ShardHeaderOffsets, metadata.HeaderOffset)
+	}
+	return result, nil
+}
+
+// MergeSummaryBlocks merges SummaryRows blocks with the same header, such as the
+// blocks of one partition read from each shard of a dataset, into one block.
+// Values found in any block take precedence over variants without values.
+func MergeSummaryBlocks(blocks [][]byte) ([]byte, error) {
+	rows, err := unmarshalSummaryRows(blocks)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no blocks to merge")
+	}
+	merged, err := mergeSummaryRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	result, err := proto.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("marshal merged block: %w", err)
+	}
+	return result, nil
+}
+
+// mergeSummaryRows merges blocks sharing the header of the first one, found values
+// taking precedence over variants without values.
+func mergeSummaryRows(rows []*SummaryRows) (*SummaryRows, error) {
+	merged := &SummaryRows{
+		Header: rows[0].Header,
+		Rows:   make(map[string]*SummaryValues),
+		Naming: rows[0].Naming,
+	}
+	for i, block := range rows {
+		if !slices.Equal(block.Header, merged.Header) {
+			return nil, fmt.Errorf("block %d header does not match block 0", i)
+		}
+		for variant, values := range block.Rows {
+			if len(values.GetValues()) > 0 || merged.Rows[variant] == nil {
+				merged.Rows[variant] = values
+			}
+		}
+	}
+	return merged, nil
+}
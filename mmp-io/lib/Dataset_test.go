@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func testDatasetConfiguration() DatasetConfiguration {
+	return DatasetConfiguration{
+		FileConfiguration: FileConfiguration{
+			Tag: "sharded",
+			FileColumnsDefinition: FileColumnsDefinition{
+				ColumnChromosome:      "CHR",
+				ColumnPosition:        "POS",
+				ColumnReference:       "REF",
+				ColumnAlternate:       "ALT",
+				ColumnPValue:          "PVAL",
+				ColumnBeta:            "BETA",
+				ColumnSEBeta:          "SE",
+				ColumnAlleleFrequency: "AF",
+			},
+			PvalThreshold: 0.05,
+			Delimiter:     "\t",
+		},
+		Glob: "study.chr*.tsv.gz",
+	}
+}
+
+func TestParseDatasetConfiguration(t *testing.T) {
+	validJSON := []byte(`{
+		"tag": "sharded",
+		"chromosomeColumn": "CHR",
+		"positionColumn": "POS",
+		"referenceColumn": "REF",
+		"alternativeColumn": "ALT",
+		"pValueColumn": "PVAL",
+		"betaColumn": "BETA",
+		"sebetaColumn": "SE",
+		"afColumn": "AF",
+		"pval_threshold": 0.05,
+		"delimiter": "\t",
+		"files": ["chr1.tsv", "chr2.tsv"]
+	}`)
+	configuration, err := ParseDatasetConfiguration(validJSON, func(string) {})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if configuration.Tag != "sharded" || len(configuration.Files) != 2 {
+		t.Errorf("unexpected configuration: %+v", configuration)
+	}
+
+	noFiles := strings.Replace(string(validJSON), `"files": ["chr1.tsv", "chr2.tsv"]`, `"files": []`, 1)
+	if _, err := ParseDatasetConfiguration([]byte(noFiles), func(string) {}); err == nil {
+		t.Error("Expected validation error without files or glob, got none")
+	}
+}
+
+func TestDatasetFiles(t *testing.T) {
+	available := []string{"study.chr10.tsv.gz", "study.chr2.tsv.gz", "study.chrX.tsv.gz", "study.chr1.tsv.gz", "other.tsv"}
+	files, err := DatasetFiles(testDatasetConfiguration(), available)
+	if err != nil {
+		t.Fatalf("DatasetFiles() unexpected error: %v", err)
+	}
+	expected := "study.chr1.tsv.gz study.chr2.tsv.gz study.chr10.tsv.gz study.chrX.tsv.gz"
+	if strings.Join(files, " ") != expected {
+		t.Errorf("DatasetFiles() = %v, want %s", files, expected)
+	}
+
+	configuration := testDatasetConfiguration()
+	configuration.Glob = ""
+	configuration.Files = []string{"other.tsv", "missing.tsv"}
+	if _, err := DatasetFiles(configuration, available); err == nil {
+		t.Error("DatasetFiles() expected error for missing file, got none")
+	}
+
+	configuration.Glob = "nothing*"
+	configuration.Files = nil
+	if _, err := DatasetFiles(configuration, available); err == nil {
+		t.Error("DatasetFiles() expected error when nothing matches, got none")
+	}
+}
+
+func TestCreateDatasetColumnsIndex(t *testing.T) {
+	headers := [][]byte{
+		[]byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\n"),
+		[]byte("##chromosome=2\nCHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\n"),
+	}
+	metadata, err := CreateDatasetColumnsIndex(headers, testDatasetConfiguration())
+	if err != nil {
+		t.Fatalf("CreateDatasetColumnsIndex() unexpected error: %v", err)
+	}
+	if metadata.Tag != "sharded" || metadata.ColumnAlleleFrequency != 7 {
+		t.Errorf("unexpected metadata: %+v", metadata.BlockMetadata)
+	}
+	if len(metadata.ShardHeaderOffsets) != 2 || metadata.ShardHeaderOffsets[1] != len(headers[1]) {
+		t.Errorf("ShardHeaderOffsets = %v", metadata.ShardHeaderOffsets)
+	}
+
+	headers[1] = []byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\tINFO\n")
+	if _, err := CreateDatasetColumnsIndex(headers, testDatasetConfiguration()); err == nil {
+		t.Error("CreateDatasetColumnsIndex() expected error for mismatched headers, got none")
+	}
+	if _, err := CreateDatasetColumnsIndex(nil, testDatasetConfiguration()); err == nil {
+		t.Error("CreateDatasetColumnsIndex() expected error without shards, got none")
+	}
+}
+
+func TestMergeSummaryBlocks(t *testing.T) {
+	metadata, err := CreateDatasetColumnsIndex([][]byte{[]byte("CHR\tPOS\tREF\tALT\tPVAL\tBETA\tSE\tAF\n")}, testDatasetConfiguration())
+	if err != nil {
+		t.Fatalf("CreateDatasetColumnsIndex() unexpected error: %v", err)
+	}
+	partitions := VariantPartitions{{"1\t100\tA\tT", "2\t200\tG\tC"}}
+	shard1, err := BufferSummaryPasses([]byte("1\t100\tA\tT\t0.001\t0.5\t0.1\t0.3\n"), metadata.BlockMetadata, partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	shard2, err := BufferSummaryPasses([]byte("2\t200\tG\tC\t0.01\t0.2\t0.05\t0.4\n"), metadata.BlockMetadata, partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+
+	merged, err := MergeSummaryBlocks([][]byte{shard1[0], shard2[0]})
+	if err != nil {
+		t.Fatalf("MergeSummaryBlocks() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(merged, &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	for _, variant := range partitions[0] {
+		if len(summaryRows.Rows[variant].GetValues()) != 4 {
+			t.Errorf("variant %q: expected values from its shard, got %v", variant, summaryRows.Rows[variant])
+		}
+	}
+
+	other, _ := proto.Marshal(&SummaryRows{Header: CreateHeader("other", nil)})
+	if _, err := MergeSummaryBlocks([][]byte{shard1[0], other}); err == nil {
+		t.Error("MergeSummaryBlocks() expected error for different headers, got none")
+	}
+	if _, err := MergeSummaryBlocks(nil); err == nil {
+		t.Error("MergeSummaryBlocks() expected error without blocks, got none")
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"chr2", "chr10", true},
+		{"chr10", "chr2", false},
+		{"chr02", "chr10", true},
+		{"chr9.tsv", "chrX.tsv", true},
+		{"a", "ab", true},
+	}
+	for _, tt := range tests {
+		if got := compareNatural(tt.a, tt.b) < 0; got != tt.less {
+			t.Errorf("compareNatural(%q, %q) < 0 = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+}
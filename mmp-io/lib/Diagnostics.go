@@ -0,0 +1,314 @@
+package lib
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Issues reported by DiagnoseColumns.
+const (
+	IssuePValueAboveOne = "pval_above_one"
+	IssueBetaOddsRatio  = "beta_odds_ratio"
+	IssueMinorAllele    = "af_minor_allele"
+	IssueZInconsistent  = "z_inconsistent"
+)
+
+// DiagnoseColumns reads up to diagnosticsSample rows and only checks statistics
+// with at least diagnosticsMinValues values.
+const (
+	diagnosticsSample    = 10000
+	diagnosticsMinValues = 20
+)
+
+// diagnosticsTolerance is the relative difference allowed between a statistic and
+// the one derived from a candidate column, and diagnosticsMatch the share of the
+// sampled rows that must agree for the candidate to be suggested.
+const (
+	diagnosticsTolerance = 0.1
+	diagnosticsMatch     = 0.9
+)
+
+// ColumnIssue is one likely mistake in the columns of a FileConfiguration. Field is
+// the JSON name of the configuration field concerned and Fix the fields to change,
+// with their new values, when a column of the file fits better.
+type ColumnIssue struct {
+	Field   string            `json:"field"`
+	Issue   string            `json:"issue"`
+	Message string            `json:"message"`
+	Fix     map[string]string `json:"fix,omitempty"`
+}
+
+// ColumnDiagnostics holds the issues found over the sampled rows and, when any of
+// them has a fix, the configuration with every fix applied.
+type ColumnDiagnostics struct {
+	Rows      int                `json:"rows"`
+	Issues    []ColumnIssue      `json:"issues"`
+	Suggested *FileConfiguration `json:"suggested,omitempty"`
+}
+
+// columnSample holds the numeric values of every column of the sampled rows, NaN
+// where a value is missing or not a number.
+type columnSample struct {
+	names  []string
+	values [][]float64
+}
+
+// column returns the values of one column.
+func (sample columnSample) column(index int) []float64 {
+	result := make([]float64, len(sample.values))
+	for i, row := range sample.values {
+		result[i] = math.NaN()
+		if index < len(row) {
+			result[i] = row[index]
+		}
+	}
+	return result
+}
+
+// candidate returns the name of the first column, other than excluded, whose values
+// fit those of the configured columns on diagnosticsMatch of the rows where fit
+// applies. fit returns whether a row applies and whether the value fits.
+func (sample columnSample) candidate(excluded []int, fit func(value float64, row int) (bool, bool)) (string, bool) {
+	for column, name := range sample.names {
+		if slices.Contains(excluded, column) {
+			continue
+		}
+		values := sample.column(column)
+		applied, fitted := 0, 0
+		for row, value := range values {
+			if math.IsNaN(value) {
+				continue
+			}
+			if applies, fits := fit(value, row); applies {
+				applied++
+				if fits {
+					fitted++
+				}
+			}
+		}
+		if applied >= diagnosticsMinValues && float64(fitted) >= diagnosticsMatch*float64(applied) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// near reports whether a is within diagnosticsTolerance of b.
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) <= diagnosticsTolerance*max(math.Abs(b), 1e-12)
+}
+
+// finiteValues returns the values that are numbers.
+func finiteValues(values []float64) []float64 {
+	var result []float64
+	for _, value := range values {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// median returns the median of values, NaN when there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// sampleColumns reads up to diagnosticsSample data rows of the buffer given to
+// CreateFileColumnsIndex.
+func sampleColumns(buffer []byte, configuration FileConfiguration, metadata BlockMetadata) columnSample {
+	var sample columnSample
+	headerLine, _, _, _ := splitPreamble(buffer, configuration.CommentPrefixes)
+	columns := strings.Split(strings.TrimSpace(string(headerLine)), configuration.Delimiter)
+	for i, column := range columns {
+		if configuration.Headerless {
+			sample.names = append(sample.names, strconv.Itoa(i))
+		} else {
+			sample.names = append(sample.names, strings.TrimSpace(column))
+		}
+	}
+
+	tableReader := newTableReader(buffer[metadata.HeaderOffset:], metadata)
+	tableReader.FieldsPerRecord = -1
+	for len(sample.values) < diagnosticsSample {
+		row, err := tableReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			continue
+		} else if err != nil {
+			break
+		}
+		values := make([]float64, len(row))
+		for i, value := range row {
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || isMissingValue(value, metadata) {
+				v = math.NaN()
+			}
+			values[i] = v
+		}
+		sample.values = append(sample.values, values)
+	}
+	return sample
+}
+
+// DiagnoseColumns resolves the columns of a configuration against the start of a
+// file, as CreateFileColumnsIndex does, and checks the statistics of the rows that
+// follow the header in the buffer for likely mapping mistakes: p-values above 1
+// (-log10 p-values), only positive betas around 1 (odds ratios), allele frequencies
+// never above 0.5 (minor allele frequencies) and betas over standard errors that
+// do not match the z-scores of the p-values. For every issue the other columns of
+// the file are searched for one holding the expected statistic.
+func DiagnoseColumns(buffer []byte, configuration FileConfiguration) (ColumnDiagnostics, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return ColumnDiagnostics{}, err
+	}
+	metadata, err := CreateFileColumnsIndex(buffer, configuration)
+	if err != nil {
+		return ColumnDiagnostics{}, err
+	}
+	sample := sampleColumns(buffer, configuration, metadata)
+	index := metadata.FileColumnsIndex
+	excluded := []int{index.ColumnChromosome, index.ColumnPosition, index.ColumnReference, index.ColumnAlternate}
+	pvalues, betas := sample.column(index.ColumnPValue), sample.column(index.ColumnBeta)
+	sebetas, afs := sample.column(index.ColumnSEBeta), sample.column(index.ColumnAlleleFrequency)
+
+	result := ColumnDiagnostics{Rows: len(sample.values), Issues: []ColumnIssue{}}
+	add := func(issue ColumnIssue, candidate string, found bool) {
+		if found {
+			issue.Fix = map[string]string{issue.Field: candidate}
+			issue.Message += fmt.Sprintf(", column %q holds them", candidate)
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+
+	pvalueIssue := false
+	if valid := finiteValues(pvalues); len(valid) >= diagnosticsMinValues && slices.Min(valid) >= 0 && slices.Max(valid) > 1 {
+		pvalueIssue = true
+		above := 0
+		for _, p := range valid {
+			if p > 1 {
+				above++
+			}
+		}
+		candidate, found := sample.candidate(slices.Concat(excluded, []int{index.ColumnPValue}), func(value float64, row int) (bool, bool) {
+			return !math.IsNaN(pvalues[row]), value >= 0 && value <= 1 && near(-math.Log10(value), pvalues[row])
+		})
+		add(ColumnIssue{
+			Field:   "pValueColumn",
+			Issue:   IssuePValueAboveOne,
+			Message: fmt.Sprintf("%d of %d p-values are above 1, they look like -log10 p-values", above, len(valid)),
+		}, candidate, found)
+	}
+
+	betaIssue := false
+	if valid := finiteValues(betas); len(valid) >= diagnosticsMinValues && slices.Min(valid) > 0 {
+		if m := median(valid); m >= 2.0/3 && m <= 1.5 {
+			betaIssue = true
+			candidate, found := sample.candidate(slices.Concat(excluded, []int{index.ColumnBeta}), func(value float64, row int) (bool, bool) {
+				return betas[row] > 0 && math.Abs(math.Log(betas[row])) > 1e-3, near(value, math.Log(betas[row]))
+			})
+			add(ColumnIssue{
+				Field:   "betaColumn",
+				Issue:   IssueBetaOddsRatio,
+				Message: fmt.Sprintf("every beta is positive with a median of %g, they look like odds ratios", m),
+			}, candidate, found)
+		}
+	}
+
+	if valid := finiteValues(afs); len(valid) >= diagnosticsMinValues && slices.Min(valid) >= 0 && slices.Max(valid) <= 0.5 {
+		// Columns of minor allele frequencies fit as well, only columns above 0.5 are suggested
+		minor := slices.Concat(excluded, []int{index.ColumnAlleleFrequency})
+		for column := range sample.names {
+			if values := finiteValues(sample.column(column)); len(values) == 0 || slices.Max(values) <= 0.5 {
+				minor = append(minor, column)
+			}
+		}
+		candidate, found := sample.candidate(minor, func(value float64, row int) (bool, bool) {
+			return !math.IsNaN(afs[row]), value >= 0 && value <= 1 && near(min(value, 1-value), afs[row])
+		})
+		add(ColumnIssue{
+			Field:   "afColumn",
+			Issue:   IssueMinorAllele,
+			Message: fmt.Sprintf("no allele frequency of %d is above 0.5, they look like minor allele frequencies", len(valid)),
+		}, candidate, found)
+	}
+
+	if !pvalueIssue && !betaIssue {
+		// z-scores of the p-values, for rows where they are large enough to compare
+		z := make([]float64, len(pvalues))
+		var ratios []float64
+		for row, p := range pvalues {
+			z[row] = math.NaN()
+			if p > 0 && p < 0.5 {
+				z[row] = math.Sqrt2 * math.Erfcinv(p)
+				if sebetas[row] > 0 && !math.IsNaN(betas[row]) {
+					ratios = append(ratios, math.Abs(betas[row]/sebetas[row])/z[row])
+				}
+			}
+		}
+		if m := median(ratios); len(ratios) >= diagnosticsMinValues && !near(m, 1) {
+			issue := ColumnIssue{
+				Field:   "sebetaColumn",
+				Issue:   IssueZInconsistent,
+				Message: fmt.Sprintf("beta/sebeta is %.3g times the z-score of the p-value", m),
+			}
+			applies := func(row int) bool { return !math.IsNaN(z[row]) && sebetas[row] > 0 && !math.IsNaN(betas[row]) }
+			statistics := slices.Concat(excluded, []int{index.ColumnSEBeta, index.ColumnBeta})
+			candidate, found := sample.candidate(statistics, func(value float64, row int) (bool, bool) {
+				return applies(row), value > 0 && near(math.Abs(betas[row]/value), z[row])
+			})
+			if !found {
+				candidate, found = sample.candidate(statistics, func(value float64, row int) (bool, bool) {
+					return applies(row), near(math.Abs(value/sebetas[row]), z[row])
+				})
+				if found {
+					issue.Field = "betaColumn"
+				}
+			}
+			add(issue, candidate, found)
+		}
+	}
+
+	for _, issue := range result.Issues {
+		for field, column := range issue.Fix {
+			if result.Suggested == nil {
+				suggested := configuration
+				result.Suggested = &suggested
+			}
+			result.Suggested.setColumn(field, column)
+		}
+	}
+	return result, nil
+}
+
+// setColumn sets the column field with the given JSON name.
+func (configuration *FileConfiguration) setColumn(field string, column string) {
+	switch field {
+	case "pValueColumn":
+		configuration.ColumnPValue = column
+	case "betaColumn":
+		configuration.ColumnBeta = column
+	case "sebetaColumn":
+		configuration.ColumnSEBeta = column
+	case "afColumn":
+		configuration.ColumnAlleleFrequency = column
+	}
+}
@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testDiagnosticsFile returns a file holding every statistic under the right name
+// and under the names users mistake for it.
+func testDiagnosticsFile() []byte {
+	var b strings.Builder
+	b.WriteString("chr\tpos\tref\talt\tP\tLOG10P\tOR\tBETA\tSE\tSE2\tAF\tMAF\n")
+	for i := range 50 {
+		beta := 0.1 * float64(1+i%5)
+		if i%2 == 1 {
+			beta = -beta
+		}
+		se := 0.05 + 0.001*float64(i)
+		p := math.Erfc(math.Abs(beta/se) / math.Sqrt2)
+		af := 0.05 + 0.9*float64(i)/50
+		fmt.Fprintf(&b, "1\t%d\tA\tT\t%g\t%g\t%g\t%g\t%g\t%g\t%g\t%g\n",
+			100*(i+1), p, -math.Log10(p), math.Exp(beta), beta, se, se*se, af, min(af, 1-af))
+	}
+	return []byte(b.String())
+}
+
+func testDiagnosticsConfiguration() FileConfiguration {
+	return FileConfiguration{
+		Tag: "study",
+		FileColumnsDefinition: FileColumnsDefinition{
+			ColumnChromosome: "chr", ColumnPosition: "pos", ColumnReference: "ref", ColumnAlternate: "alt",
+			ColumnPValue: "P", ColumnBeta: "BETA", ColumnSEBeta: "SE", ColumnAlleleFrequency: "AF",
+		},
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+	}
+}
+
+func TestDiagnoseColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapped func(configuration *FileConfiguration)
+		issues []string
+		fixed  FileColumnsDefinition
+	}{
+		{
+			name:   "correct columns",
+			mapped: func(configuration *FileConfiguration) {},
+		},
+		{
+			name:   "-log10 p-values",
+			mapped: func(configuration *FileConfiguration) { configuration.ColumnPValue = "LOG10P" },
+			issues: []string{IssuePValueAboveOne},
+		},
+		{
+			name:   "odds ratios",
+			mapped: func(configuration *FileConfiguration) { configuration.ColumnBeta = "OR" },
+			issues: []string{IssueBetaOddsRatio},
+		},
+		{
+			name:   "minor allele frequencies",
+			mapped: func(configuration *FileConfiguration) { configuration.ColumnAlleleFrequency = "MAF" },
+			issues: []string{IssueMinorAllele},
+		},
+		{
+			name:   "variances",
+			mapped: func(configuration *FileConfiguration) { configuration.ColumnSEBeta = "SE2" },
+			issues: []string{IssueZInconsistent},
+		},
+		{
+			name: "several mistakes",
+			mapped: func(configuration *FileConfiguration) {
+				configuration.ColumnPValue = "LOG10P"
+				configuration.ColumnAlleleFrequency = "MAF"
+			},
+			issues: []string{IssuePValueAboveOne, IssueMinorAllele},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := testDiagnosticsConfiguration()
+			tt.mapped(&configuration)
+			result, err := DiagnoseColumns(testDiagnosticsFile(), configuration)
+			if err != nil {
+				t.Fatalf("DiagnoseColumns() unexpected error: %v", err)
+			}
+			if result.Rows != 50 {
+				t.Errorf("Rows = %d, want 50", result.Rows)
+			}
+			var issues []string
+			for _, issue := range result.Issues {
+				issues = append(issues, issue.Issue)
+				if len(issue.Fix) != 1 {
+					t.Errorf("issue %+v has no fix", issue)
+				}
+			}
+			if strings.Join(issues, ",") != strings.Join(tt.issues, ",") {
+				t.Fatalf("issues = %v, want %v", result.Issues, tt.issues)
+			}
+			if len(tt.issues) == 0 {
+				if result.Suggested != nil {
+					t.Errorf("Suggested = %+v, want none", result.Suggested)
+				}
+				return
+			}
+			// Every fix restores the original mapping
+			if result.Suggested == nil || result.Suggested.FileColumnsDefinition != testDiagnosticsConfiguration().FileColumnsDefinition {
+				t.Errorf("Suggested = %+v, want the original columns", result.Suggested)
+			}
+		})
+	}
+}
+
+func TestDiagnoseColumnsWithoutCandidate(t *testing.T) {
+	// Without the P and AF columns there is nothing to suggest
+	var lines []string
+	for i, line := range strings.Split(strings.TrimSpace(string(testDiagnosticsFile())), "\n") {
+		fields := strings.Split(line, "\t")
+		fields[4], fields[10] = "x", "y"
+		if i == 0 {
+			fields[4], fields[10] = "P", "AF"
+		}
+		lines = append(lines, strings.Join(fields, "\t"))
+	}
+	configuration := testDiagnosticsConfiguration()
+	configuration.ColumnPValue = "LOG10P"
+	configuration.ColumnAlleleFrequency = "MAF"
+	result, err := DiagnoseColumns([]byte(strings.Join(lines, "\n")), configuration)
+	if err != nil {
+		t.Fatalf("DiagnoseColumns() unexpected error: %v", err)
+	}
+	if len(result.Issues) != 2 || result.Issues[0].Fix != nil || result.Issues[1].Fix != nil || result.Suggested != nil {
+		t.Errorf("DiagnoseColumns() = %+v, want two issues without fixes", result)
+	}
+
+	configuration.ColumnPValue = "missing"
+	if _, err := DiagnoseColumns(testDiagnosticsFile(), configuration); err == nil {
+		t.Error("DiagnoseColumns() expected error for unknown column, got none")
+	}
+}
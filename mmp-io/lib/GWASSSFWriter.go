@@ -0,0 +1,173 @@
+package lib
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GWASSSFFileType is the file_type recorded in the GWAS-SSF metadata.
+const GWASSSFFileType = "GWAS-SSF v1.0"
+
+// gwasSSFMissingValue is the missing value marker required by GWAS-SSF.
+const gwasSSFMissingValue = "#NA"
+
+// GWASSSFConfiguration controls the GWAS-SSF export.
+// Delimiter is the delimiter used in the variant keys of the blocks.
+// Date is the date_metadata_last_modified value (YYYY-MM-DD), today when empty.
+// NumberFormat formats the statistics as in SummaryOptions.
+type GWASSSFConfiguration struct {
+	Delimiter        string            `json:"delimiter" validate:"required"`
+	GenomeAssembly   string            `json:"genome_assembly" validate:"required"`
+	NegLog10PValue   bool              `json:"neglog10_pval"`
+	Date             string            `json:"date,omitempty"`
+	TraitDescription map[string]string `json:"trait_description,omitempty"`
+	SampleSize       map[string]uint64 `json:"sample_size,omitempty"`
+	NumberFormat     NumberFormat      `json:"number_format"`
+}
+
+// GWASSSFFile is the GWAS-SSF export of one tag: a tab separated data file
+// and the YAML metadata file that accompanies it.
+type GWASSSFFile struct {
+	Tag              string   `json:"tag"`
+	FileName         string   `json:"file_name"`
+	MetadataFileName string   `json:"metadata_file_name"`
+	Header           string   `json:"header"`
+	Rows             []string `json:"rows"`
+	Metadata         string   `json:"metadata"`
+}
+
+func gwasSSFHeader(negLog10PValue bool) []string {
+	pValueColumn := "p_value"
+	if negLog10PValue {
+		pValueColumn = "neg_log_10_p_value"
+	}
+	return []string{
+		"chromosome",
+		"base_pair_location",
+		"effect_allele",
+		"other_allele",
+		"beta",
+		"standard_error",
+		"effect_allele_frequency",
+		pValueColumn,
+	}
+}
+
+// negLog10 converts a p-value to -log10(p), missing for a p-value of 0 whose
+// -log10 is not finite.
+func negLog10(pValue string) (string, error) {
+	p, err := strconv.ParseFloat(pValue, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid pvalue: %w", err)
+	}
+	if p < 0 || p > 1 {
+		return "", fmt.Errorf("pvalue %s out of range", pValue)
+	}
+	if p == 0 {
+		return gwasSSFMissingValue, nil
+	}
+	return strconv.FormatFloat(-math.Log10(p), 'g', -1, 64), nil
+}
+
+// yamlString quotes free text for the metadata file; JSON strings are valid YAML.
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
+
+func gwasSSFMetadata(file GWASSSFFile, md5sum string, configuration GWASSSFConfiguration, date string) string {
+	var b strings.Builder
+	b.WriteString("# Study meta-data\n")
+	fmt.Fprintf(&b, "date_metadata_last_modified: %s\n", date)
+	description := file.Tag
+	if text, ok := configuration.TraitDescription[file.Tag]; ok {
+		description = text
+	}
+	fmt.Fprintf(&b, "trait_description:\n  - %s\n", yamlString(description))
+	if n, ok := configuration.SampleSize[file.Tag]; ok {
+		fmt.Fprintf(&b, "samples:\n  - sample_size: %d\n", n)
+	}
+	b.WriteString("\n# Genotyping Information\n")
+	fmt.Fprintf(&b, "genome_assembly: %s\n", yamlString(configuration.GenomeAssembly))
+	b.WriteString("coordinate_system: 1-based\n")
+	b.WriteString("\n# Summary Statistic information\n")
+	fmt.Fprintf(&b, "data_file_name: %s\n", yamlString(file.FileName))
+	fmt.Fprintf(&b, "file_type: %s\n", GWASSSFFileType)
+	fmt.Fprintf(&b, "data_file_md5sum: %s\n", md5sum)
+	b.WriteString("is_harmonised: false\n")
+	b.WriteString("is_sorted: true\n")
+	return b.String()
+}
+
+// GWASSSFBytesString writes every tag of the blocks as a GWAS-SSF data file with its
+// metadata. The alternate allele is reported as the effect allele, rows are sorted
+// by chromosome and position and variants without values for a tag are left out.
+func GWASSSFBytesString(buffer [][]byte, configuration GWASSSFConfiguration) ([]GWASSSFFile, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return nil, err
+	}
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return nil, err
+	}
+	date := configuration.Date
+	if date == "" {
+		date = time.Now().UTC().Format(time.DateOnly)
+	}
+
+	header := gwasSSFHeader(configuration.NegLog10PValue)
+	statistics := []string{StatisticBeta, StatisticSEBeta, StatisticAlleleFrequency, StatisticPValue}
+	var result []GWASSSFFile
+	for _, tag := range summaryTags(rows) {
+		keys := make([]string, 0, len(rows[tag.Block].Rows))
+		for key := range rows[tag.Block].Rows {
+			if tag.present(rows, key) {
+				keys = append(keys, key)
+			}
+		}
+		variants, err := sortVariantKeys(keys, configuration.Delimiter)
+		if err != nil {
+			return nil, err
+		}
+
+		file := GWASSSFFile{
+			Tag:              tag.Tag,
+			FileName:         tag.Tag + ".tsv",
+			MetadataFileName: tag.Tag + ".tsv-meta.yaml",
+			Header:           strings.Join(header, "\t"),
+			Rows:             make([]string, len(keys)),
+		}
+		digest := md5.New()
+		digest.Write([]byte(file.Header + "\n"))
+		for i, key := range keys {
+			fields := make([]string, 0, len(header))
+			fields = append(fields,
+				strconv.FormatUint(uint64(variants[i].Chromosome), 10),
+				strconv.FormatUint(variants[i].Position, 10),
+				variants[i].Alt,
+				variants[i].Ref)
+			for _, statistic := range statistics {
+				value, ok := tag.value(rows, key, statistic)
+				if !ok {
+					value = gwasSSFMissingValue
+				} else if statistic == StatisticPValue && configuration.NegLog10PValue {
+					if value, err = negLog10(value); err != nil {
+						return nil, fmt.Errorf("tag %s variant %q: %w", tag.Tag, key, err)
+					}
+				} else {
+					value = configuration.NumberFormat.formatValue(statistic, value)
+				}
+				fields = append(fields, value)
+			}
+			file.Rows[i] = strings.Join(fields, "\t")
+			digest.Write([]byte(file.Rows[i] + "\n"))
+		}
+		file.Metadata = gwasSSFMetadata(file, hex.EncodeToString(digest.Sum(nil)), configuration, date)
+		result = append(result, file)
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func testMergedBlocks(t *testing.T) [][]byte {
+	t.Helper()
+	study1 := &SummaryRows{
+		Header: CreateHeader("study_1", nil),
+		Rows: map[string]*SummaryValues{
+			"2\t500\tG\tC":   {Values: []string{"1.000000e-08", "0.200000", "0.050000", "0.400000"}},
+			"1\t12345\tA\tT": {Values: []string{"1.000000e-03", "-0.500000", "0.100000", "NA"}},
+			"1\t999\tC\tG":   nil,
+		},
+	}
+	study2 := &SummaryRows{
+		Header: CreateHeader("study2", nil),
+		Rows: map[string]*SummaryValues{
+			"1\t12345\tA\tT": {Values: []string{"5.000000e-02", "0.100000", "0.050000", "0.300000"}},
+		},
+	}
+	var buffer [][]byte
+	for _, rows := range []*SummaryRows{study1, study2} {
+		data, err := proto.Marshal(rows)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		buffer = append(buffer, data)
+	}
+	return buffer
+}
+
+func TestGWASSSFBytesString(t *testing.T) {
+	configuration := GWASSSFConfiguration{
+		Delimiter:        "\t",
+		GenomeAssembly:   "GRCh38",
+		Date:             "2026-01-02",
+		TraitDescription: map[string]string{"study_1": "Type 2 diabetes"},
+		SampleSize:       map[string]uint64{"study_1": 5000},
+	}
+	files, err := GWASSSFBytesString(testMergedBlocks(t), configuration)
+	if err != nil {
+		t.Fatalf("GWASSSFBytesString() unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("GWASSSFBytesString() returned %d files, want 2", len(files))
+	}
+
+	file := files[0]
+	if file.Tag != "study_1" || file.FileName != "study_1.tsv" || file.MetadataFileName != "study_1.tsv-meta.yaml" {
+		t.Errorf("unexpected file names: %+v", file)
+	}
+	expectedHeader := "chromosome\tbase_pair_location\teffect_allele\tother_allele\tbeta\tstandard_error\teffect_allele_frequency\tp_value"
+	if file.Header != expectedHeader {
+		t.Errorf("Header = %q, want %q", file.Header, expectedHeader)
+	}
+	// Sorted by position, the variant without values is left out
+	expectedRows := []string{
+		"1\t12345\tT\tA\t-0.500000\t0.100000\t#NA\t1.000000e-03",
+		"2\t500\tC\tG\t0.200000\t0.050000\t0.400000\t1.000000e-08",
+	}
+	if strings.Join(file.Rows, "\n") != strings.Join(expectedRows, "\n") {
+		t.Errorf("Rows = %q, want %q", file.Rows, expectedRows)
+	}
+
+	// md5 of the data file: the header and the rows above, each ending with a newline
+	const expectedMD5 = "9f97ff42ba7ef8a24ff3a5b3cd2f0b1f"
+	if sum := md5.Sum([]byte(file.Header + "\n" + strings.Join(file.Rows, "\n") + "\n")); hex.EncodeToString(sum[:]) != expectedMD5 {
+		t.Errorf("data file md5 = %x, want %s", sum, expectedMD5)
+	}
+	for _, line := range []string{
+		"date_metadata_last_modified: 2026-01-02",
+		"  - \"Type 2 diabetes\"",
+		"  - sample_size: 5000",
+		"genome_assembly: \"GRCh38\"",
+		"data_file_name: \"study_1.tsv\"",
+		"file_type: GWAS-SSF v1.0",
+		"data_file_md5sum: " + expectedMD5,
+		"is_sorted: true",
+	} {
+		if !strings.Contains(file.Metadata, line+"\n") {
+			t.Errorf("Metadata missing %q:\n%s", line, file.Metadata)
+		}
+	}
+
+	if len(files[1].Rows) != 1 || !strings.Contains(files[1].Metadata, "  - \"study2\"") {
+		t.Errorf("unexpected second file: %+v", files[1])
+	}
+}
+
+func TestGWASSSFBytesStringNegLog10(t *testing.T) {
+	configuration := GWASSSFConfiguration{Delimiter: "\t", GenomeAssembly: "GRCh37", NegLog10PValue: true}
+	files, err := GWASSSFBytesString(testMergedBlocks(t), configuration)
+	if err != nil {
+		t.Fatalf("GWASSSFBytesString() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(files[0].Header, "\tneg_log_10_p_value") {
+		t.Errorf("Header = %q, want neg_log_10_p_value column", files[0].Header)
+	}
+	if !strings.HasSuffix(files[0].Rows[1], "\t8") {
+		t.Errorf("Rows[1] = %q, want -log10(1e-8) = 8", files[0].Rows[1])
+	}
+
+	configuration.NumberFormat = NumberFormat{Shortest: true}
+	files, err = GWASSSFBytesString(testMergedBlocks(t), configuration)
+	if err != nil {
+		t.Fatalf("GWASSSFBytesString() unexpected error: %v", err)
+	}
+	if want := "2\t500\tC\tG\t0.2\t0.05\t0.4\t8"; files[0].Rows[1] != want {
+		t.Errorf("Rows[1] = %q, want %q", files[0].Rows[1], want)
+	}
+}
+
+func TestGWASSSFBytesStringNegLog10Zero(t *testing.T) {
+	block := func(pvalue string) [][]byte {
+		data, err := proto.Marshal(&SummaryRows{
+			Header: CreateHeader("study", nil),
+			Rows:   map[string]*SummaryValues{"1\t100\tA\tT": {Values: []string{pvalue, "0.2", "0.05", "0.4"}}},
+		})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return [][]byte{data}
+	}
+	configuration := GWASSSFConfiguration{Delimiter: "\t", GenomeAssembly: "GRCh37", NegLog10PValue: true}
+	files, err := GWASSSFBytesString(block("0"), configuration)
+	if err != nil {
+		t.Fatalf("GWASSSFBytesString() unexpected error: %v", err)
+	}
+	if want := "1\t100\tT\tA\t0.200000\t0.050000\t0.400000\t#NA"; files[0].Rows[0] != want {
+		t.Errorf("Rows[0] = %q, want %q", files[0].Rows[0], want)
+	}
+	if _, err := GWASSSFBytesString(block("1.5"), configuration); err == nil {
+		t.Error("GWASSSFBytesString() expected error for a p-value above 1, got none")
+	}
+}
+
+func TestGWASSSFBytesStringErrors(t *testing.T) {
+	if _, err := GWASSSFBytesString(testMergedBlocks(t), GWASSSFConfiguration{Delimiter: "\t"}); err == nil {
+		t.Error("GWASSSFBytesString() expected error without genome assembly, got none")
+	}
+	configuration := GWASSSFConfiguration{Delimiter: "\t", GenomeAssembly: "GRCh38"}
+	if _, err := GWASSSFBytesString([][]byte{[]byte("invalid protobuf")}, configuration); err == nil {
+		t.Error("GWASSSFBytesString() expected error for invalid protobuf, got none")
+	}
+}
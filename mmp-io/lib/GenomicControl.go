@@ -0,0 +1,360 @@
+package lib
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// genomicControlBins is the number of uniform p-value bins of GenomicControlCounts.
+const genomicControlBins = 1000
+
+// genomicControlMedian is the median of the chi² distribution with one degree of
+// freedom that lambda is relative to.
+const genomicControlMedian = 0.456
+
+// GenomicControlConfiguration selects the groups lambda is computed for besides the
+// whole file. ByChromosome adds one lambda per chromosome and MAFBins, ascending
+// upper bounds of the minor allele frequency, one per bin: the first bin holds the
+// variants with a MAF up to MAFBins[0], the next those above it up to MAFBins[1].
+// Variants without an allele frequency or with a MAF above the last bound are only
+// counted in the other groups.
+type GenomicControlConfiguration struct {
+	ByChromosome bool      `json:"by_chromosome,omitempty"`
+	MAFBins      []float64 `json:"maf_bins,omitempty" validate:"dive,gt=0,lte=0.5"`
+}
+
+// GenomicControlCounts holds the p-value histograms of one tag over one buffer,
+// genomicControlBins uniform bins over [0, 1]. Counts of the buffers of a file are
+// merged by GenomicControl.
+type GenomicControlCounts struct {
+	Tag         string              `json:"tag"`
+	PValues     []uint64            `json:"pvalues"`
+	Chromosomes map[uint32][]uint64 `json:"chromosomes,omitempty"`
+	MAFBins     [][]uint64          `json:"maf_bins,omitempty"`
+}
+
+// GenomicControlLambda is the genomic inflation factor of a group of variants, the
+// median chi² of their p-values over genomicControlMedian. Lambda is null for
+// groups without variants.
+type GenomicControlLambda struct {
+	Variants uint64   `json:"variants"`
+	Lambda   *float64 `json:"lambda"`
+}
+
+// ChromosomeLambda is the lambda of the variants of one chromosome.
+type ChromosomeLambda struct {
+	Chromosome uint32 `json:"chromosome"`
+	GenomicControlLambda
+}
+
+// MAFBinLambda is the lambda of the variants with a MAF above MinMAF up to MaxMAF.
+type MAFBinLambda struct {
+	MinMAF float64 `json:"min_maf"`
+	MaxMAF float64 `json:"max_maf"`
+	GenomicControlLambda
+}
+
+// GenomicControlReport holds the lambda of one tag, per chromosome and per MAF bin
+// as configured.
+type GenomicControlReport struct {
+	Tag string `json:"tag"`
+	GenomicControlLambda
+	Chromosomes []ChromosomeLambda `json:"chromosomes,omitempty"`
+	MAFBins     []MAFBinLambda     `json:"maf_bins,omitempty"`
+}
+
+func (configuration GenomicControlConfiguration) validate() error {
+	if err := validate.Struct(configuration); err != nil {
+		return err
+	}
+	if !slices.IsSorted(configuration.MAFBins) || len(slices.Compact(slices.Clone(configuration.MAFBins))) != len(configuration.MAFBins) {
+		return fmt.Errorf("MAF bins must be strictly ascending")
+	}
+	return nil
+}
+
+// newGenomicControlCounts returns empty counts of a tag.
+func newGenomicControlCounts(tag string, configuration GenomicControlConfiguration) GenomicControlCounts {
+	counts := GenomicControlCounts{Tag: tag, PValues: make([]uint64, genomicControlBins)}
+	if configuration.ByChromosome {
+		counts.Chromosomes = make(map[uint32][]uint64)
+	}
+	if len(configuration.MAFBins) > 0 {
+		counts.MAFBins = make([][]uint64, len(configuration.MAFBins))
+		for i := range counts.MAFBins {
+			counts.MAFBins[i] = make([]uint64, genomicControlBins)
+		}
+	}
+	return counts
+}
+
+// add counts one p-value, maf is NaN when the variant has no allele frequency.
+// P-values outside [0, 1] are not counted.
+func (counts *GenomicControlCounts) add(pvalue float64, chromosome uint32, maf float64, configuration GenomicControlConfiguration) {
+	if !(pvalue >= 0 && pvalue <= 1) {
+		return
+	}
+	bin := min(int(pvalue*genomicControlBins), genomicControlBins-1)
+	counts.PValues[bin]++
+	if counts.Chromosomes != nil {
+		if counts.Chromosomes[chromosome] == nil {
+			counts.Chromosomes[chromosome] = make([]uint64, genomicControlBins)
+		}
+		counts.Chromosomes[chromosome][bin]++
+	}
+	if i := mafBin(maf, configuration.MAFBins); i >= 0 && i < len(counts.MAFBins) {
+		counts.MAFBins[i][bin]++
+	}
+}
+
+// mafBin returns the bin of a minor allele frequency, -1 when it is in none.
+func mafBin(maf float64, bins []float64) int {
+	if !(maf >= 0) {
+		return -1
+	}
+	for i, bound := range bins {
+		if maf <= bound {
+			return i
+		}
+	}
+	return -1
+}
+
+// minorAlleleFrequency folds an allele frequency, NaN when it is not a frequency.
+func minorAlleleFrequency(af float64) float64 {
+	if !(af >= 0 && af <= 1) {
+		return math.NaN()
+	}
+	return min(af, 1-af)
+}
+
+// histogramMedian returns the number of values of a histogram and their median as
+// a fractional bin position, interpolated linearly within the bin.
+func histogramMedian(histogram []uint64) (uint64, float64) {
+	var total uint64
+	for _, count := range histogram {
+		total += count
+	}
+	half := float64(total) / 2
+	var cumulative float64
+	for i, count := range histogram {
+		if count > 0 && cumulative+float64(count) >= half {
+			return total, float64(i) + (half-cumulative)/float64(count)
+		}
+		cumulative += float64(count)
+	}
+	return total, math.NaN()
+}
+
+// histogramLambda is the lambda of a p-value histogram.
+func histogramLambda(histogram []uint64) GenomicControlLambda {
+	variants, median := histogramMedian(histogram)
+	result := GenomicControlLambda{Variants: variants}
+	if variants > 0 {
+		chiSquare := 2 * math.Pow(math.Erfcinv(median/genomicControlBins), 2)
+		result.Lambda = finite(chiSquare / genomicControlMedian)
+	}
+	return result
+}
+
+// addHistogram adds the counts of b to a, allocating a when it is nil.
+func addHistogram(a []uint64, b []uint64) []uint64 {
+	if a == nil {
+		a = make([]uint64, len(b))
+	}
+	for i := range min(len(a), len(b)) {
+		a[i] += b[i]
+	}
+	return a
+}
+
+// metadataTags returns the tags of a delimited file, one per selected phenotype for
+// long format files.
+func metadataTags(metadata BlockMetadata) ([]string, error) {
+	if metadata.ColumnPhenotype == nil {
+		return []string{metadata.Tag}, nil
+	}
+	if len(metadata.Phenotypes) == 0 {
+		return nil, fmt.Errorf("phenotypes must be listed in the configuration or collected with BufferPhenotypes")
+	}
+	tags := make([]string, 0, len(metadata.Phenotypes))
+	for _, phenotype := range metadata.Phenotypes {
+		tags = append(tags, subTag(metadata.Tag, phenotype))
+	}
+	return tags, nil
+}
+
+// BufferGenomicControl counts the p-values of every row of a buffer, one
+// GenomicControlCounts per tag of the file. Like BufferVariants it is called on
+// every buffer of the file and the counts are merged by GenomicControl. P-values
+// are counted as read, before any correction by metadata.GenomicControl.
+func BufferGenomicControl(buffer []byte, metadata BlockMetadata, configuration GenomicControlConfiguration) ([]GenomicControlCounts, error) {
+	if err := configuration.validate(); err != nil {
+		return nil, err
+	}
+	if metadata.FileFormat == FileFormatGWASVCF {
+		return bufferVCFGenomicControl(buffer, metadata, configuration)
+	}
+	tags, err := metadataTags(metadata)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]GenomicControlCounts, len(tags))
+	for i, tag := range tags {
+		result[i] = newGenomicControlCounts(tag, configuration)
+	}
+	phenotypes := phenotypeSet(metadata)
+
+	afColumn := -1
+	if len(configuration.MAFBins) > 0 {
+		afColumn = metadata.ColumnAlleleFrequency
+	}
+	requiredLen := max(metadata.FileColumnsIndex.ColumnChromosome, metadata.FileColumnsIndex.ColumnPValue,
+		afColumn, phenotypeColumn(metadata)) + 1
+	firstRow := true
+	tableReader := newTableReader(buffer, metadata)
+
+	for {
+		row, err := tableReader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, csv.ErrFieldCount) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if firstRow {
+			if len(row) < requiredLen {
+				return nil, fmt.Errorf("insufficient columns: expected at least %d, got %d", requiredLen, len(row))
+			}
+			firstRow = false
+		}
+
+		index := 0
+		if phenotypes != nil {
+			phenotype, ok := phenotypes[row[*metadata.ColumnPhenotype]]
+			if !ok {
+				continue
+			}
+			index = phenotype
+		}
+
+		if isMissingValue(row[metadata.ColumnPValue], metadata) {
+			continue
+		}
+		pvalue, err := parsePValue(row, metadata.FileColumnsIndex)
+		if err != nil {
+			return nil, err
+		}
+		chromosome, err := parseChromosome(row[metadata.ColumnChromosome])
+		if err != nil {
+			return nil, fmt.Errorf("invalid chromosome: %w", err)
+		}
+		maf := math.NaN()
+		if afColumn >= 0 && !isMissingValue(row[afColumn], metadata) {
+			af, err := parseFloat32(row[afColumn])
+			if err != nil {
+				return nil, fmt.Errorf("invalid allele frequency: %w", err)
+			}
+			maf = minorAlleleFrequency(float64(af))
+		}
+		result[index].add(float64(pvalue), chromosome, maf, configuration)
+	}
+	return result, nil
+}
+
+// GenomicControl merges the counts of every buffer of a file into one report per
+// tag, in the order the tags first appear. configuration must be the one the counts
+// were made with.
+func GenomicControl(counts []GenomicControlCounts, configuration GenomicControlConfiguration) ([]GenomicControlReport, error) {
+	if err := configuration.validate(); err != nil {
+		return nil, err
+	}
+	var tags []string
+	merged := make(map[string]*GenomicControlCounts)
+	for _, count := range counts {
+		total, ok := merged[count.Tag]
+		if !ok {
+			tags = append(tags, count.Tag)
+			empty := newGenomicControlCounts(count.Tag, configuration)
+			total = &empty
+			merged[count.Tag] = total
+		}
+		if len(count.MAFBins) > len(total.MAFBins) {
+			return nil, fmt.Errorf("tag %q: counts have %d MAF bins, configuration %d", count.Tag, len(count.MAFBins), len(total.MAFBins))
+		}
+		total.PValues = addHistogram(total.PValues, count.PValues)
+		for chromosome, histogram := range count.Chromosomes {
+			if total.Chromosomes != nil {
+				total.Chromosomes[chromosome] = addHistogram(total.Chromosomes[chromosome], histogram)
+			}
+		}
+		for i, histogram := range count.MAFBins {
+			total.MAFBins[i] = addHistogram(total.MAFBins[i], histogram)
+		}
+	}
+
+	result := make([]GenomicControlReport, 0, len(tags))
+	for _, tag := range tags {
+		total := merged[tag]
+		report := GenomicControlReport{Tag: tag, GenomicControlLambda: histogramLambda(total.PValues)}
+		chromosomes := make([]uint32, 0, len(total.Chromosomes))
+		for chromosome := range total.Chromosomes {
+			chromosomes = append(chromosomes, chromosome)
+		}
+		slices.Sort(chromosomes)
+		for _, chromosome := range chromosomes {
+			report.Chromosomes = append(report.Chromosomes, ChromosomeLambda{
+				Chromosome:           chromosome,
+				GenomicControlLambda: histogramLambda(total.Chromosomes[chromosome]),
+			})
+		}
+		lower := 0.0
+		for i, bound := range configuration.MAFBins {
+			report.MAFBins = append(report.MAFBins, MAFBinLambda{
+				MinMAF:               lower,
+				MaxMAF:               bound,
+				GenomicControlLambda: histogramLambda(total.MAFBins[i]),
+			})
+			lower = bound
+		}
+		result = append(result, report)
+	}
+	return result, nil
+}
+
+// correctPValue divides the chi² of a p-value by lambda. P-values outside [0, 1]
+// and a lambda of at most 1 leave it unchanged: deflated statistics are not inflated.
+func correctPValue(pvalue float64, lambda float64) float64 {
+	if !(lambda > 1) || !(pvalue >= 0 && pvalue <= 1) {
+		return pvalue
+	}
+	return math.Erfc(math.Erfcinv(pvalue) / math.Sqrt(lambda))
+}
+
+// correctAssociationStatistic applies the genomic control correction by lambda,
+// reporting whether the statistic was corrected. As in correctPValue, only a lambda
+// above 1 corrects.
+func correctAssociationStatistic(assoc *AssociationStatistic, lambda float64) bool {
+	if !(lambda > 1) {
+		return false
+	}
+	assoc.PValue = float32(correctPValue(float64(assoc.PValue), lambda))
+	assoc.Sebeta = correctStandardError(assoc.Sebeta, lambda)
+	return true
+}
+
+// correctStandardError multiplies a standard error by the square root of lambda,
+// leaving it unchanged for a lambda of at most 1 as correctPValue does.
+func correctStandardError(sebeta float32, lambda float64) float32 {
+	if !(lambda > 1) {
+		return sebeta
+	}
+	return float32(float64(sebeta) * math.Sqrt(lambda))
+}
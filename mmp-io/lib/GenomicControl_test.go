@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func genomicControlTestMetadata() BlockMetadata {
+	return BlockMetadata{
+		Tag:           "study",
+		PvalThreshold: 1e-6,
+		Delimiter:     "\t",
+		MissingValues: []string{"NA"},
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome: 0, ColumnPosition: 1, ColumnReference: 2, ColumnAlternate: 3,
+			ColumnPValue: 4, ColumnBeta: 5, ColumnSEBeta: 6, ColumnAlleleFrequency: 7,
+		},
+	}
+}
+
+// genomicControlTestRows returns n rows per chromosome whose chi² are the quantiles
+// of the chi² distribution scaled by lambda, chromosome 1 with AF 0.02 and
+// chromosome 2 with AF 0.7, and the exact lambda of each chromosome.
+func genomicControlTestRows(n int, lambdas map[uint32]float64) ([]string, map[uint32]float64) {
+	var rows []string
+	expected := make(map[uint32]float64)
+	for _, chromosome := range []uint32{1, 2} {
+		af := map[uint32]string{1: "0.02", 2: "0.7"}[chromosome]
+		pvalues := make([]float64, n)
+		for i := range pvalues {
+			u := (float64(i) + 0.5) / float64(n)
+			pvalues[i] = math.Erfc(math.Sqrt(lambdas[chromosome]) * math.Erfcinv(u))
+			rows = append(rows, fmt.Sprintf("%d\t%d\tA\tT\t%g\t0.1\t0.05\t%s", chromosome, i+1, pvalues[i], af))
+		}
+		slices.Sort(pvalues)
+		median := (pvalues[n/2-1] + pvalues[n/2]) / 2
+		expected[chromosome] = 2 * math.Pow(math.Erfcinv(median), 2) / genomicControlMedian
+	}
+	return rows, expected
+}
+
+func TestGenomicControl(t *testing.T) {
+	rows, expected := genomicControlTestRows(2000, map[uint32]float64{1: 1.5, 2: 1})
+	configuration := GenomicControlConfiguration{ByChromosome: true, MAFBins: []float64{0.05, 0.5}}
+	metadata := genomicControlTestMetadata()
+
+	// Counts of two buffers merge into those of the whole file
+	var counts []GenomicControlCounts
+	for _, buffer := range []string{strings.Join(rows[:1500], "\n"), strings.Join(rows[1500:], "\n") + "\n"} {
+		result, err := BufferGenomicControl([]byte(buffer), metadata, configuration)
+		if err != nil {
+			t.Fatalf("BufferGenomicControl() unexpected error: %v", err)
+		}
+		counts = append(counts, result...)
+	}
+	reports, err := GenomicControl(counts, configuration)
+	if err != nil {
+		t.Fatalf("GenomicControl() unexpected error: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Tag != "study" || reports[0].Variants != 4000 {
+		t.Fatalf("GenomicControl() = %+v, want one report of 4000 variants", reports)
+	}
+	report := reports[0]
+	if report.Lambda == nil || *report.Lambda <= expected[2] || *report.Lambda >= expected[1] {
+		t.Errorf("lambda = %v, want between %g and %g", report.Lambda, expected[2], expected[1])
+	}
+	if len(report.Chromosomes) != 2 || len(report.MAFBins) != 2 {
+		t.Fatalf("report has %d chromosomes and %d MAF bins, want 2 and 2", len(report.Chromosomes), len(report.MAFBins))
+	}
+	// The MAF bins split the variants like the chromosomes
+	groups := []struct {
+		name   string
+		group  GenomicControlLambda
+		lambda float64
+	}{
+		{"chromosome 1", report.Chromosomes[0].GenomicControlLambda, expected[1]},
+		{"chromosome 2", report.Chromosomes[1].GenomicControlLambda, expected[2]},
+		{"maf bin 1", report.MAFBins[0].GenomicControlLambda, expected[1]},
+		{"maf bin 2", report.MAFBins[1].GenomicControlLambda, expected[2]},
+	}
+	for _, group := range groups {
+		if group.group.Variants != 2000 || group.group.Lambda == nil || math.Abs(*group.group.Lambda-group.lambda) > 2e-3 {
+			t.Errorf("%s = %d variants with lambda %v, want 2000 with %g", group.name, group.group.Variants, group.group.Lambda, group.lambda)
+		}
+	}
+	if report.MAFBins[1].MinMAF != 0.05 || report.MAFBins[1].MaxMAF != 0.5 {
+		t.Errorf("MAF bin 2 = %+v, want (0.05, 0.5]", report.MAFBins[1])
+	}
+}
+
+func TestBufferGenomicControlPhenotypes(t *testing.T) {
+	counts, err := BufferGenomicControl([]byte(phenotypeTestBuffer), phenotypeTestMetadata([]string{"BMI", "T2D"}), GenomicControlConfiguration{})
+	if err != nil {
+		t.Fatalf("BufferGenomicControl() unexpected error: %v", err)
+	}
+	reports, err := GenomicControl(counts, GenomicControlConfiguration{})
+	if err != nil {
+		t.Fatalf("GenomicControl() unexpected error: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Tag != "phewas_BMI" || reports[1].Tag != "phewas_T2D" ||
+		reports[0].Variants != 2 || reports[1].Variants != 2 || reports[0].Chromosomes != nil {
+		t.Errorf("GenomicControl() = %+v, want two phenotypes of 2 variants", reports)
+	}
+}
+
+func TestBufferGenomicControlVCF(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	configuration := GenomicControlConfiguration{MAFBins: []float64{0.5}}
+	counts, err := BufferGenomicControl([]byte(testVCFData), metadata, configuration)
+	if err != nil {
+		t.Fatalf("BufferGenomicControl() unexpected error: %v", err)
+	}
+	reports, err := GenomicControl(counts, configuration)
+	if err != nil {
+		t.Fatalf("GenomicControl() unexpected error: %v", err)
+	}
+	// rs3 has no AF and rs2 no estimate in TRAIT2
+	if len(reports) != 2 || reports[0].Variants != 3 || reports[0].MAFBins[0].Variants != 2 || reports[1].Variants != 2 {
+		t.Errorf("GenomicControl() = %+v", reports)
+	}
+}
+
+func TestGenomicControlErrors(t *testing.T) {
+	metadata := genomicControlTestMetadata()
+	tests := []struct {
+		name          string
+		buffer        string
+		configuration GenomicControlConfiguration
+	}{
+		{"descending bins", "1\t1\tA\tT\t0.5\t0.1\t0.05\t0.1\n", GenomicControlConfiguration{MAFBins: []float64{0.2, 0.1}}},
+		{"bin above 0.5", "1\t1\tA\tT\t0.5\t0.1\t0.05\t0.1\n", GenomicControlConfiguration{MAFBins: []float64{0.6}}},
+		{"invalid p-value", "1\t1\tA\tT\tx\t0.1\t0.05\t0.1\n", GenomicControlConfiguration{}},
+		{"invalid allele frequency", "1\t1\tA\tT\t0.5\t0.1\t0.05\tx\n", GenomicControlConfiguration{MAFBins: []float64{0.5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BufferGenomicControl([]byte(tt.buffer), metadata, tt.configuration); err == nil {
+				t.Error("BufferGenomicControl() expected error, got none")
+			}
+		})
+	}
+	if _, err := GenomicControl([]GenomicControlCounts{{Tag: "study", MAFBins: make([][]uint64, 2)}}, GenomicControlConfiguration{}); err == nil {
+		t.Error("GenomicControl() expected error for counts of another configuration, got none")
+	}
+}
+
+func TestHistogramLambda(t *testing.T) {
+	if got := histogramLambda(make([]uint64, genomicControlBins)); got.Variants != 0 || got.Lambda != nil {
+		t.Errorf("histogramLambda() of no variants = %+v, want null lambda", got)
+	}
+	// One variant per bin is uniform, the median p-value is 0.5
+	histogram := make([]uint64, genomicControlBins)
+	for i := range histogram {
+		histogram[i] = 1
+	}
+	want := 2 * math.Pow(math.Erfcinv(0.5), 2) / genomicControlMedian
+	if got := histogramLambda(histogram); got.Lambda == nil || math.Abs(*got.Lambda-want) > 1e-12 {
+		t.Errorf("histogramLambda() of uniform p-values = %v, want %g", got.Lambda, want)
+	}
+}
+
+func TestGenomicControlCorrection(t *testing.T) {
+	metadata := genomicControlTestMetadata()
+	buffer := []byte("1\t100\tA\tT\t1e-8\t0.2\t0.1\t0.3\n1\t200\tA\tT\t1e-20\t0.5\t0.1\t0.3\n")
+	variants, err := BufferVariants(buffer, metadata)
+	if err != nil || len(variants) != 2 {
+		t.Fatalf("BufferVariants() = %q, %v, want both variants", variants, err)
+	}
+	// Halving the chi² of 1e-8 moves it above the threshold
+	metadata.GenomicControl = map[string]float64{"study": 2}
+	variants, err = BufferVariants(buffer, metadata)
+	if err != nil || strings.Join(variants, "|") != "1\t200\tA\tT" {
+		t.Errorf("BufferVariants() corrected = %q, %v, want only 1:200", variants, err)
+	}
+
+	blocks, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{{"1\t100\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var block SummaryRows
+	if err := proto.Unmarshal(blocks[0], &block); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	values := block.Rows["1\t100\tA\tT"].GetValues()
+	if len(values) != 4 || values[1] != "0.2" || values[3] != "0.3" {
+		t.Fatalf("values = %v, want original beta and af", values)
+	}
+	var pvalue, sebeta float64
+	fmt.Sscan(values[0], &pvalue)
+	fmt.Sscan(values[2], &sebeta)
+	chiSquare := 2 * math.Pow(math.Erfcinv(1e-8), 2)
+	if got := 2 * math.Pow(math.Erfcinv(pvalue), 2); math.Abs(got-chiSquare/2) > 1e-4 {
+		t.Errorf("corrected chi² = %g, want %g", got, chiSquare/2)
+	}
+	if math.Abs(sebeta-0.1*math.Sqrt2) > 1e-6 {
+		t.Errorf("corrected sebeta = %g, want %g", sebeta, 0.1*math.Sqrt2)
+	}
+}
+
+func TestGenomicControlCorrectionDeflated(t *testing.T) {
+	metadata := genomicControlTestMetadata()
+	metadata.GenomicControl = map[string]float64{"study": 0.8}
+	buffer := []byte("1\t100\tA\tT\t0.02\t0.2\t0.1\t0.3\n")
+	blocks, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{{"1\t100\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var block SummaryRows
+	if err := proto.Unmarshal(blocks[0], &block); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	// A lambda below 1 must not make the statistics more significant
+	if values := block.Rows["1\t100\tA\tT"].GetValues(); strings.Join(values, " ") != "0.02 0.2 0.1 0.3" {
+		t.Errorf("values = %v, want the uncorrected statistics", values)
+	}
+}
+
+func TestGenomicControlCorrectionVCF(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	// A third of the chi² of rs1 (p=1e-3) is above 0.05 in TRAIT1
+	metadata.GenomicControl = map[string]float64{"ieu_TRAIT1": 3}
+	variants, err := BufferVariants([]byte(testVCFData), metadata)
+	if err != nil || strings.Join(variants, "|") != "23\t11111\tC\tG" {
+		t.Errorf("BufferVariants() corrected = %q, %v, want only rs3", variants, err)
+	}
+
+	blocks, err := BufferSummaryPasses([]byte(testVCFData), metadata, VariantPartitions{{"1\t12345\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var block SummaryRows
+	if err := proto.Unmarshal(blocks[0], &block); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	values := block.Rows["1\t12345\tA\tT"].GetValues()
+	pvalue, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		t.Fatalf("p-value %q: %v", values[0], err)
+	}
+	chiSquare := 2 * math.Pow(math.Erfcinv(1e-3), 2)
+	if got := 2 * math.Pow(math.Erfcinv(pvalue), 2); math.Abs(got-chiSquare/3) > 1e-9 {
+		t.Errorf("corrected chi² = %g, want %g", got, chiSquare/3)
+	}
+	// TRAIT2 has no lambda
+	if values[4] != "0.31622776601683794" {
+		t.Errorf("TRAIT2 p-value = %q, want uncorrected", values[4])
+	}
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// appendJSONNumber writes a statistic as a JSON number, null when missing or not finite.
+func appendJSONNumber(line []byte, value float64, valid bool) []byte {
+	if !valid || math.IsNaN(value) || math.IsInf(value, 0) {
+		return append(line, "null"...)
+	}
+	return strconv.AppendFloat(line, value, 'g', -1, 64)
+}
+
+func appendJSONString(line []byte, value string) []byte {
+	encoded, _ := json.Marshal(value)
+	return append(line, encoded...)
+}
+
+// SummaryJSONLines returns the blocks of a partition, as passed to SummaryBytesString,
+// as JSON Lines: one object per variant in genomic order with chromosome, position,
+// ref and alt, and a "statistics" object holding, for every tag in header order, an
+// object of its statistics as numbers, null when missing.
+func SummaryJSONLines(buffer [][]byte, delimiter string) ([]string, error) {
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []string{}, nil
+	}
+	tags := summaryTags(rows)
+	table, err := newTypedSummaryTable(rows, tags, delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, len(table.Chromosomes))
+	var line []byte
+	for i := range table.Chromosomes {
+		line = append(line[:0], `{"chromosome":`...)
+		line = strconv.AppendUint(line, uint64(table.Chromosomes[i]), 10)
+		line = append(line, `,"position":`...)
+		line = strconv.AppendUint(line, table.Positions[i], 10)
+		line = append(line, `,"ref":`...)
+		line = appendJSONString(line, table.Refs[i])
+		line = append(line, `,"alt":`...)
+		line = appendJSONString(line, table.Alts[i])
+		line = append(line, `,"statistics":{`...)
+		column := 0
+		for t, tag := range tags {
+			if t > 0 {
+				line = append(line, ',')
+			}
+			line = appendJSONString(line, tag.Tag)
+			line = append(line, ":{"...)
+			for s, statistic := range tag.Statistics {
+				if s > 0 {
+					line = append(line, ',')
+				}
+				line = appendJSONString(line, statistic)
+				line = append(line, ':')
+				line = appendJSONNumber(line, table.Columns[column][i], table.Valid[column][i])
+				column++
+			}
+			line = append(line, '}')
+		}
+		line = append(line, "}}"...)
+		result[i] = string(line)
+	}
+	return result, nil
+}
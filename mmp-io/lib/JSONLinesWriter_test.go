@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSummaryJSONLines(t *testing.T) {
+	lines, err := SummaryJSONLines(testMergedBlocks(t), "\t")
+	if err != nil {
+		t.Fatalf("SummaryJSONLines() unexpected error: %v", err)
+	}
+	want := []string{
+		`{"chromosome":1,"position":999,"ref":"C","alt":"G","statistics":{"study_1":{"pval":null,"beta":null,"sebeta":null,"af":null},"study2":{"pval":null,"beta":null,"sebeta":null,"af":null}}}`,
+		`{"chromosome":1,"position":12345,"ref":"A","alt":"T","statistics":{"study_1":{"pval":0.001,"beta":-0.5,"sebeta":0.1,"af":null},"study2":{"pval":0.05,"beta":0.1,"sebeta":0.05,"af":0.3}}}`,
+		`{"chromosome":2,"position":500,"ref":"G","alt":"C","statistics":{"study_1":{"pval":1e-08,"beta":0.2,"sebeta":0.05,"af":0.4},"study2":{"pval":null,"beta":null,"sebeta":null,"af":null}}}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("SummaryJSONLines() returned %d lines, want %d", len(lines), len(want))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %s\nwant %s", i, lines[i], want[i])
+		}
+		var object map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &object); err != nil {
+			t.Errorf("line %d is not valid JSON: %v", i, err)
+		}
+	}
+}
+
+func TestSummaryJSONLinesEdgeCases(t *testing.T) {
+	block := func(t *testing.T, rows *SummaryRows) []byte {
+		data, err := proto.Marshal(rows)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return data
+	}
+
+	lines, err := SummaryJSONLines(nil, "\t")
+	if err != nil || len(lines) != 0 {
+		t.Errorf("SummaryJSONLines(nil) = %q, %v, want no lines", lines, err)
+	}
+
+	quoted := block(t, &SummaryRows{
+		Header: CreateHeader(`st"udy`, nil),
+		Rows:   map[string]*SummaryValues{"1\t5\tA\tT": {Values: []string{"Inf", "NaN", "0.1", "0.2"}}},
+	})
+	lines, err = SummaryJSONLines([][]byte{quoted}, "\t")
+	if err != nil {
+		t.Fatalf("SummaryJSONLines() unexpected error: %v", err)
+	}
+	want := `{"chromosome":1,"position":5,"ref":"A","alt":"T","statistics":{"st\"udy":{"pval":null,"beta":null,"sebeta":0.1,"af":0.2}}}`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("SummaryJSONLines() = %q, want %q", lines, want)
+	}
+
+	invalid := block(t, &SummaryRows{
+		Header: CreateHeader("study", nil),
+		Rows:   map[string]*SummaryValues{"1\t5\tA\tT": {Values: []string{"x", "0.1", "0.1", "0.1"}}},
+	})
+	if _, err := SummaryJSONLines([][]byte{invalid}, "\t"); err == nil {
+		t.Errorf("SummaryJSONLines() expected error for non numeric value, got none")
+	}
+}
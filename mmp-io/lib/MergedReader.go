@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MergedConfiguration describes a merged wide table written by HeaderBytesString and
+// SummaryBytesString with the variant columns included.
+type MergedConfiguration struct {
+	PvalThreshold float32 `json:"pval_threshold" validate:"required"`
+	Delimiter     string  `json:"delimiter" validate:"required"`
+	// Naming is the naming the table was written with, "<tag>_<statistic>" when unset
+	Naming *ColumnNaming `json:"naming,omitempty"`
+	// MissingValues are the markers written for missing statistics, NA when empty
+	MissingValues []string `json:"missing_values,omitempty" validate:"dive,required"`
+}
+
+// mergedColumnStatistics are the per tag columns a merged table may hold.
+var mergedColumnStatistics = []string{StatisticPValue, StatisticBeta, StatisticSEBeta, StatisticAlleleFrequency,
+	StatisticSampleSize, statusStatistic, statisticReplicated}
+
+// mergedStatistics are the per tag columns every tag of a merged table must have.
+var mergedStatistics = []string{StatisticPValue, StatisticBeta, StatisticSEBeta, StatisticAlleleFrequency}
+
+// CreateMergedColumnsIndex recognises the header of a merged wide table and returns
+// one BlockMetadata per tag, in header order. Passing each of them to BufferVariants
+// and BufferSummaryPasses reads the table back as one source per tag, with the
+// naming of the table kept for its output; the missing value markers are treated as
+// missing, "<tag>_n" columns are read as the sample size and "<tag>_status",
+// "<tag>_replicated" and meta-analysis columns are ignored.
+func CreateMergedColumnsIndex(header []byte, configuration MergedConfiguration) ([]BlockMetadata, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return nil, err
+	}
+	headerLine, _, headerOffset, fileMetadata := splitPreamble(header, nil)
+	columns := strings.Split(strings.TrimSpace(string(headerLine)), configuration.Delimiter)
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	if len(columns) < len(cpraHeader) || !slices.Equal(columns[:len(cpraHeader)], cpraHeader) {
+		return nil, fmt.Errorf("not a merged table: header must start with %s", strings.Join(cpraHeader, configuration.Delimiter))
+	}
+
+	naming := configuration.Naming
+	metaColumns := (&MetaAnalysis{RandomEffects: RandomEffectsDerSimonianLaird, Stouffer: true}).columns(naming)
+	metaColumns = append(metaColumns, naming.column(metaTag, statisticDirection))
+	missingValues := configuration.MissingValues
+	if len(missingValues) == 0 {
+		missingValues = []string{missingValue}
+	}
+
+	var tags []string
+	tagColumns := make(map[string]map[string]int)
+	for i := len(cpraHeader); i < len(columns); i++ {
+		if slices.Contains(metaColumns, columns[i]) {
+			continue
+		}
+		tag, statistic, ok := naming.parseColumn(columns[i], mergedColumnStatistics)
+		if !ok {
+			return nil, fmt.Errorf("column %q is not a %s column", columns[i], naming.column("<tag>", "<statistic>"))
+		}
+		if statistic == statusStatistic || statistic == statisticReplicated {
+			continue
+		}
+		if _, ok := tagColumns[tag]; !ok {
+			tags = append(tags, tag)
+			tagColumns[tag] = make(map[string]int)
+		}
+		if _, ok := tagColumns[tag][statistic]; ok {
+			return nil, fmt.Errorf("duplicate column %q", columns[i])
+		}
+		tagColumns[tag][statistic] = i
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("merged table has no tags")
+	}
+
+	result := make([]BlockMetadata, len(tags))
+	for i, tag := range tags {
+		for _, statistic := range mergedStatistics {
+			if _, ok := tagColumns[tag][statistic]; !ok {
+				return nil, fmt.Errorf("tag %q has no %s column", tag, statistic)
+			}
+		}
+		var sampleSizeIdx *int
+		if idx, ok := tagColumns[tag][StatisticSampleSize]; ok {
+			sampleSizeIdx = &idx
+		}
+		result[i] = BlockMetadata{
+			Tag:              tag,
+			PvalThreshold:    configuration.PvalThreshold,
+			Delimiter:        configuration.Delimiter,
+			HeaderOffset:     headerOffset,
+			FileMetadata:     fileMetadata,
+			MissingValues:    missingValues,
+			ColumnNaming:     naming,
+			ColumnSampleSize: sampleSizeIdx,
+			FileColumnsIndex: FileColumnsIndex{
+				ColumnChromosome:      0,
+				ColumnPosition:        1,
+				ColumnReference:       2,
+				ColumnAlternate:       3,
+				ColumnPValue:          tagColumns[tag][StatisticPValue],
+				ColumnBeta:            tagColumns[tag][StatisticBeta],
+				ColumnSEBeta:          tagColumns[tag][StatisticSEBeta],
+				ColumnAlleleFrequency: tagColumns[tag][StatisticAlleleFrequency],
+			},
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateMergedColumnsIndex(t *testing.T) {
+	header := "chromosome\tposition\treference\talternative\t" +
+		"study_1_pval\tstudy_1_beta\tstudy_1_sebeta\tstudy_1_af\t" +
+		"study2_pval\tstudy2_beta\tstudy2_sebeta\tstudy2_af\n"
+	configuration := MergedConfiguration{PvalThreshold: 0.05, Delimiter: "\t"}
+
+	metadata, err := CreateMergedColumnsIndex([]byte(header), configuration)
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("CreateMergedColumnsIndex() returned %d tags, want 2", len(metadata))
+	}
+	if metadata[0].Tag != "study_1" || metadata[1].Tag != "study2" {
+		t.Errorf("tags = %q, %q, want study_1, study2", metadata[0].Tag, metadata[1].Tag)
+	}
+	if metadata[1].ColumnPValue != 8 || metadata[1].ColumnAlleleFrequency != 11 {
+		t.Errorf("study2 columns = %+v", metadata[1].FileColumnsIndex)
+	}
+	if metadata[0].HeaderOffset != len(header) {
+		t.Errorf("HeaderOffset = %d, want %d", metadata[0].HeaderOffset, len(header))
+	}
+}
+
+func TestCreateMergedColumnsIndexErrors(t *testing.T) {
+	configuration := MergedConfiguration{PvalThreshold: 0.05, Delimiter: "\t"}
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no variant columns", "study_pval\tstudy_beta\tstudy_sebeta\tstudy_af"},
+		{"no tags", "chromosome\tposition\treference\talternative"},
+		{"unknown column", "chromosome\tposition\treference\talternative\tstudy_pval\tstudy_beta\tstudy_sebeta\tstudy_af\tstudy_info"},
+		{"incomplete tag", "chromosome\tposition\treference\talternative\tstudy_pval\tstudy_beta"},
+		{"duplicate column", "chromosome\tposition\treference\talternative\tstudy_pval\tstudy_pval\tstudy_beta\tstudy_sebeta\tstudy_af"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreateMergedColumnsIndex([]byte(tt.header), configuration); err == nil {
+				t.Errorf("CreateMergedColumnsIndex() expected error, got none")
+			}
+		})
+	}
+}
+
+func TestCreateMergedColumnsIndexSampleSize(t *testing.T) {
+	header := []byte("chromosome\tposition\treference\talternative\tstudy_pval\tstudy_beta\tstudy_sebeta\tstudy_af\tstudy_n\tother_pval\tother_beta\tother_sebeta\tother_af\n")
+	metadata, err := CreateMergedColumnsIndex(header, MergedConfiguration{PvalThreshold: 0.05, Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("CreateMergedColumnsIndex() returned %d tags, want 2", len(metadata))
+	}
+	if metadata[0].ColumnSampleSize == nil || *metadata[0].ColumnSampleSize != 8 {
+		t.Errorf("study ColumnSampleSize = %v, want 8", metadata[0].ColumnSampleSize)
+	}
+	if metadata[1].ColumnSampleSize != nil {
+		t.Errorf("other ColumnSampleSize = %v, want none", *metadata[1].ColumnSampleSize)
+	}
+}
+
+func TestMergedRoundTrip(t *testing.T) {
+	buffer := testMergedBlocks(t)
+	header, err := HeaderBytesString(buffer, "\t", true)
+	if err != nil {
+		t.Fatalf("HeaderBytesString() unexpected error: %v", err)
+	}
+	rows, err := SummaryBytesString(buffer, "\t", true)
+	if err != nil {
+		t.Fatalf("SummaryBytesString() unexpected error: %v", err)
+	}
+	merged := []byte(header + "\n" + strings.Join(rows, "\n") + "\n")
+
+	metadata, err := CreateMergedColumnsIndex(merged, MergedConfiguration{PvalThreshold: 0.01, Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	data := merged[metadata[0].HeaderOffset:]
+
+	// study2 only has a p-value of 0.05, study_1 has two variants below 0.01
+	variants, err := BufferVariants(data, metadata[0])
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	if len(variants) != 2 {
+		t.Errorf("BufferVariants() study_1 = %q, want 2 variants", variants)
+	}
+	variants, err = BufferVariants(data, metadata[1])
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	if len(variants) != 0 {
+		t.Errorf("BufferVariants() study2 = %q, want none", variants)
+	}
+
+	partitions := VariantPartitions{{"1\t12345\tA\tT", "2\t500\tG\tC"}}
+	for _, tagMetadata := range metadata {
+		blocks, err := BufferSummaryPasses(data, tagMetadata, partitions)
+		if err != nil {
+			t.Fatalf("BufferSummaryPasses(%s) unexpected error: %v", tagMetadata.Tag, err)
+		}
+		var summaryRows SummaryRows
+		if err := proto.Unmarshal(blocks[0], &summaryRows); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if summaryRows.Header[0] != tagMetadata.Tag+"_pval" {
+			t.Errorf("header[0] = %q, want %q", summaryRows.Header[0], tagMetadata.Tag+"_pval")
+		}
+		switch tagMetadata.Tag {
+		case "study_1":
+			if got := strings.Join(summaryRows.Rows["1\t12345\tA\tT"].GetValues(), " "); got != "1.000000e-03 -0.500000 0.100000 NA" {
+				t.Errorf("study_1 values = %q", got)
+			}
+		case "study2":
+			if values := summaryRows.Rows["2\t500\tG\tC"]; len(values.GetValues()) != 0 {
+				t.Errorf("study2 should have no values for 2:500, got %v", values.GetValues())
+			}
+		}
+	}
+}
+
+func TestMergedRoundTripNamingAndMarkers(t *testing.T) {
+	buffer := testMergedBlocks(t)
+	naming := &ColumnNaming{StatisticFirst: true, Separator: ".", Statistics: map[string]string{"pval": "P", "sebeta": "SE"}}
+	options := SummaryOptions{
+		Delimiter:    "\t",
+		CPRA:         true,
+		NotInFile:    "-",
+		NotRequested: ".",
+		StatusColumn: true,
+		Naming:       naming,
+		Meta:         &MetaAnalysis{},
+	}
+	header, err := HeaderBytesStringWithOptions(buffer, options)
+	if err != nil {
+		t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+	}
+	rows, err := SummaryBytesStringWithOptions(buffer, options)
+	if err != nil {
+		t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+	}
+	merged := []byte(header + "\n" + strings.Join(rows, "\n") + "\n")
+
+	if _, err := CreateMergedColumnsIndex(merged, MergedConfiguration{PvalThreshold: 0.01, Delimiter: "\t"}); err == nil {
+		t.Error("CreateMergedColumnsIndex() expected error without the naming of the table, got none")
+	}
+	configuration := MergedConfiguration{PvalThreshold: 0.01, Delimiter: "\t", Naming: naming, MissingValues: []string{"NA", "-", "."}}
+	metadata, err := CreateMergedColumnsIndex(merged, configuration)
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(metadata) != 2 || metadata[0].Tag != "study_1" || metadata[1].Tag != "study2" {
+		t.Fatalf("CreateMergedColumnsIndex() = %+v, want study_1 and study2", metadata)
+	}
+
+	data := merged[metadata[0].HeaderOffset:]
+	partitions := VariantPartitions{{"1\t12345\tA\tT", "2\t500\tG\tC", "1\t999\tC\tG"}}
+	want := map[string]map[string]string{
+		"study_1": {"1\t12345\tA\tT": "1.000000e-03 -0.500000 0.100000 NA", "2\t500\tG\tC": "1.000000e-08 0.200000 0.050000 0.400000", "1\t999\tC\tG": ""},
+		"study2":  {"1\t12345\tA\tT": "5.000000e-02 0.100000 0.050000 0.300000", "2\t500\tG\tC": "", "1\t999\tC\tG": ""},
+	}
+	for _, tagMetadata := range metadata {
+		blocks, err := BufferSummaryPasses(data, tagMetadata, partitions)
+		if err != nil {
+			t.Fatalf("BufferSummaryPasses(%s) unexpected error: %v", tagMetadata.Tag, err)
+		}
+		var summaryRows SummaryRows
+		if err := proto.Unmarshal(blocks[0], &summaryRows); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if summaryRows.Naming.GetSeparator() != "." {
+			t.Errorf("%s block naming = %v, want the naming of the table", tagMetadata.Tag, summaryRows.Naming)
+		}
+		// Markers of variants not in a source are read back as missing rows
+		for variant, values := range want[tagMetadata.Tag] {
+			if got := strings.Join(summaryRows.Rows[variant].GetValues(), " "); got != values {
+				t.Errorf("%s values of %q = %q, want %q", tagMetadata.Tag, variant, got, values)
+			}
+		}
+	}
+}
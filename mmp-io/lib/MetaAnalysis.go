@@ -0,0 +1,426 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// metaTag names the tag of the meta-analysis columns of the merged output.
+const metaTag = "meta"
+
+// StatisticZ is the z-score of a meta-analysis.
+const StatisticZ = "z"
+
+// Estimators of the between-tag variance of a random-effects meta-analysis.
+const (
+	RandomEffectsDerSimonianLaird = "dl"
+	RandomEffectsREML             = "reml"
+)
+
+// metaStatistics are the columns of a fixed-effect meta-analysis.
+var metaStatistics = []string{StatisticBeta, StatisticSEBeta, StatisticZ, StatisticPValue}
+
+// randomEffectsStatistics are the columns added by a random-effects meta-analysis.
+var randomEffectsStatistics = []string{
+	"random_" + StatisticBeta, "random_" + StatisticSEBeta, "random_" + StatisticZ, "random_" + StatisticPValue,
+	"q", "q_" + StatisticPValue, "i2", "tau2",
+}
+
+// stoufferStatistics are the columns of a sample size weighted z-score meta-analysis.
+var stoufferStatistics = []string{"stouffer_" + StatisticZ, "stouffer_" + StatisticPValue, "stouffer_" + StatisticSampleSize}
+
+// MetaAnalysis adds an inverse-variance weighted fixed-effect meta-analysis of the
+// listed tags, or of every tag when Tags is empty, after the statistics of the
+// merged output as "meta_beta", "meta_sebeta", "meta_z" and "meta_pval". Tags
+// without a finite beta and a positive standard error for a variant are skipped
+// and variants without any are written with the missing value marker.
+// RandomEffects adds the random-effects estimate with tau² from the DerSimonian-Laird
+// or REML estimator as "meta_random_beta", "meta_random_sebeta", "meta_random_z" and
+// "meta_random_pval", followed by Cochran's Q, its p-value, I² in percent and tau².
+// Heterogeneity needs two tags, with a single one the random-effects estimate is the
+// fixed-effect one and the heterogeneity columns are missing.
+// Stouffer adds the sample size weighted z-score of the tags with a sample size as
+// "meta_stouffer_z", "meta_stouffer_pval" and their total sample size "meta_stouffer_n".
+// Each tag contributes the z-score of its p-value with the sign of its beta, weighted
+// by the square root of its sample size, so it does not need standard errors.
+type MetaAnalysis struct {
+	Tags          []string `json:"tags,omitempty" validate:"dive,required"`
+	RandomEffects string   `json:"random_effects,omitempty" validate:"omitempty,oneof=dl reml"`
+	Stouffer      bool     `json:"stouffer,omitempty"`
+}
+
+// metaStudy is the estimate of one tag for a variant.
+type metaStudy struct {
+	beta, sebeta float64
+}
+
+// stoufferStudy is the signed z-score and sample size of one tag for a variant.
+type stoufferStudy struct {
+	z, n float64
+}
+
+// statistics lists the meta-analysis columns in output order.
+func (meta *MetaAnalysis) statistics() []string {
+	statistics := slices.Clone(metaStatistics)
+	if meta.RandomEffects != "" {
+		statistics = append(statistics, randomEffectsStatistics...)
+	}
+	if meta.Stouffer {
+		statistics = append(statistics, stoufferStatistics...)
+	}
+	return statistics
+}
+
+// metaTags selects the tags of the blocks taking part in the meta-analysis.
+func (meta *MetaAnalysis) metaTags(rows []*SummaryRows) ([]summaryTag, error) {
+	if err := validate.Struct(meta); err != nil {
+		return nil, err
+	}
+	return selectTags(rows, meta.Tags)
+}
+
+// selectTags returns the named tags of the blocks in the given order, every tag when
+// names is empty.
+func selectTags(rows []*SummaryRows, names []string) ([]summaryTag, error) {
+	tags := summaryTags(rows)
+	if len(names) == 0 {
+		return tags, nil
+	}
+	selected := make([]summaryTag, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(tags, func(tag summaryTag) bool { return tag.Tag == name })
+		if index < 0 {
+			return nil, fmt.Errorf("tag %q not found", name)
+		}
+		selected = append(selected, tags[index])
+	}
+	return selected, nil
+}
+
+// columns names the meta-analysis columns following naming.
+func (meta *MetaAnalysis) columns(naming *ColumnNaming) []string {
+	statistics := meta.statistics()
+	columns := make([]string, len(statistics))
+	for i, statistic := range statistics {
+		columns[i] = naming.column(metaTag, statistic)
+	}
+	return columns
+}
+
+// metaStudies collects the usable estimates of the tags for a variant.
+func metaStudies(rows []*SummaryRows, tags []summaryTag, variant string) []metaStudy {
+	studies := make([]metaStudy, 0, len(tags))
+	for _, tag := range tags {
+		beta, ok := tag.float(rows, variant, StatisticBeta)
+		if !ok {
+			continue
+		}
+		sebeta, ok := tag.float(rows, variant, StatisticSEBeta)
+		if !ok || sebeta <= 0 {
+			continue
+		}
+		studies = append(studies, metaStudy{beta: beta, sebeta: sebeta})
+	}
+	return studies
+}
+
+// stoufferStudies collects the signed z-scores of the tags with a p-value, a beta
+// and a sample size for a variant.
+func stoufferStudies(rows []*SummaryRows, tags []summaryTag, variant string) []stoufferStudy {
+	studies := make([]stoufferStudy, 0, len(tags))
+	for _, tag := range tags {
+		pval, ok := tag.float(rows, variant, StatisticPValue)
+		if !ok || pval <= 0 || pval > 1 {
+			continue
+		}
+		beta, ok := tag.float(rows, variant, StatisticBeta)
+		if !ok {
+			continue
+		}
+		n, ok := tag.float(rows, variant, StatisticSampleSize)
+		if !ok || n <= 0 {
+			continue
+		}
+		z := math.Sqrt2 * math.Erfcinv(pval)
+		if beta < 0 {
+			z = -z
+		}
+		studies = append(studies, stoufferStudy{z: z, n: n})
+	}
+	return studies
+}
+
+// float returns a statistic of a variant as a finite number.
+func (tag summaryTag) float(rows []*SummaryRows, variant string, statistic string) (float64, bool) {
+	value, ok := tag.value(rows, variant, statistic)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// fixedEffects is the inverse-variance weighted mean of the estimates and its
+// standard error, with tau2 added to the variance of every estimate.
+func fixedEffects(studies []metaStudy, tau2 float64) (float64, float64) {
+	var weights, weighted float64
+	for _, study := range studies {
+		w := 1 / (study.sebeta*study.sebeta + tau2)
+		weights += w
+		weighted += w * study.beta
+	}
+	return weighted / weights, math.Sqrt(1 / weights)
+}
+
+// heterogeneity is Cochran's Q of the estimates around the fixed-effect mean.
+func heterogeneity(studies []metaStudy, beta float64) float64 {
+	var q float64
+	for _, study := range studies {
+		d := (study.beta - beta) / study.sebeta
+		q += d * d
+	}
+	return q
+}
+
+// derSimonianLaird is the method of moments estimate of tau² from Cochran's Q.
+func derSimonianLaird(studies []metaStudy, q float64) float64 {
+	var weights, squared float64
+	for _, study := range studies {
+		w := 1 / (study.sebeta * study.sebeta)
+		weights += w
+		squared += w * w
+	}
+	c := weights - squared/weights
+	if c <= 0 {
+		return 0
+	}
+	return max(0, (q-float64(len(studies)-1))/c)
+}
+
+// reml is the restricted maximum likelihood estimate of tau², found by Fisher
+// scoring from the DerSimonian-Laird estimate.
+func reml(studies []metaStudy, tau2 float64) float64 {
+	for range 1000 {
+		beta, _ := fixedEffects(studies, tau2)
+		var weights, squared, score float64
+		for _, study := range studies {
+			v := study.sebeta * study.sebeta
+			w := 1 / (v + tau2)
+			d := study.beta - beta
+			weights += w
+			squared += w * w
+			score += w * w * (d*d - v)
+		}
+		next := max(0, score/squared+1/weights)
+		if math.Abs(next-tau2) <= 1e-12*next {
+			return next
+		}
+		tau2 = next
+	}
+	return tau2
+}
+
+// normalPValue is the two-sided p-value of a standard normal z-score.
+func normalPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// chiSquarePValue is the upper tail probability of a chi-square statistic.
+func chiSquarePValue(x float64, df float64) float64 {
+	return upperIncompleteGamma(df/2, x/2)
+}
+
+// upperIncompleteGamma is the regularized upper incomplete gamma function Q(a, x),
+// from its series below a+1 and its continued fraction above.
+func upperIncompleteGamma(a float64, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lgamma, _ := math.Lgamma(a)
+	logPrefix := a*math.Log(x) - x - lgamma
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1.0; n < 1000; n++ {
+			term *= x / (a + n)
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return max(0, 1-sum*math.Exp(logPrefix))
+	}
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1.0; n < 1000; n++ {
+		an := -n * (n - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return math.Exp(logPrefix) * h
+}
+
+// formatMetaValue writes a derived statistic in its shortest form.
+func formatMetaValue(v float64, marker string) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return marker
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// appendMetaValues appends the meta-analysis columns of a variant.
+func (meta *MetaAnalysis) appendMetaValues(values []string, rows []*SummaryRows, tags []summaryTag, variant string, marker string) []string {
+	values = meta.appendInverseVariance(values, metaStudies(rows, tags, variant), marker)
+	if meta.Stouffer {
+		values = appendStouffer(values, stoufferStudies(rows, tags, variant), marker)
+	}
+	return values
+}
+
+// appendInverseVariance appends the fixed-effect and, when requested, random-effects columns.
+func (meta *MetaAnalysis) appendInverseVariance(values []string, studies []metaStudy, marker string) []string {
+	count := len(metaStatistics)
+	if meta.RandomEffects != "" {
+		count += len(randomEffectsStatistics)
+	}
+	if len(studies) == 0 {
+		for range count {
+			values = append(values, marker)
+		}
+		return values
+	}
+	beta, sebeta := fixedEffects(studies, 0)
+	values = appendEstimate(values, beta, sebeta, marker)
+	if meta.RandomEffects == "" {
+		return values
+	}
+
+	if len(studies) < 2 {
+		values = appendEstimate(values, beta, sebeta, marker)
+		return append(values, marker, marker, marker, marker)
+	}
+	q := heterogeneity(studies, beta)
+	df := float64(len(studies) - 1)
+	tau2 := derSimonianLaird(studies, q)
+	if meta.RandomEffects == RandomEffectsREML {
+		tau2 = reml(studies, tau2)
+	}
+	i2 := 0.0
+	if q > df {
+		i2 = 100 * (q - df) / q
+	}
+	randomBeta, randomSebeta := fixedEffects(studies, tau2)
+	values = appendEstimate(values, randomBeta, randomSebeta, marker)
+	return append(values,
+		formatMetaValue(q, marker),
+		formatMetaValue(chiSquarePValue(q, df), marker),
+		formatMetaValue(i2, marker),
+		formatMetaValue(tau2, marker),
+	)
+}
+
+// appendStouffer appends the combined z-score, its p-value and the total sample size.
+func appendStouffer(values []string, studies []stoufferStudy, marker string) []string {
+	if len(studies) == 0 {
+		return append(values, marker, marker, marker)
+	}
+	var weighted, n float64
+	for _, study := range studies {
+		weighted += math.Sqrt(study.n) * study.z
+		n += study.n
+	}
+	z := weighted / math.Sqrt(n)
+	return append(values,
+		formatMetaValue(z, marker),
+		formatMetaValue(normalPValue(z), marker),
+		formatMetaValue(n, marker),
+	)
+}
+
+// appendEstimate appends an estimate, its standard error, z-score and p-value.
+func appendEstimate(values []string, beta float64, sebeta float64, marker string) []string {
+	z := beta / sebeta
+	return append(values,
+		formatMetaValue(beta, marker),
+		formatMetaValue(sebeta, marker),
+		formatMetaValue(z, marker),
+		formatMetaValue(normalPValue(z), marker),
+	)
+}
+
+// statisticDirection names the direction column of the merged output.
+const statisticDirection = "direction"
+
+// Direction adds a METAL style "meta_direction" column to the merged output with one
+// character per tag, in the order of Tags or of the header when Tags is empty: "+"
+// for a positive beta, "-" for a negative one, "0" for zero and "?" when the tag has
+// no beta for the variant. With a SignificanceThreshold the signs are written as
+// "P" and "N" for tags whose p-value is below the threshold and as "p" and "n"
+// for the others.
+type Direction struct {
+	Tags                  []string `json:"tags,omitempty" validate:"dive,required"`
+	SignificanceThreshold float64  `json:"significance_threshold,omitempty" validate:"gte=0,lte=1"`
+}
+
+// directionTags selects the tags written in the direction column.
+func (direction *Direction) directionTags(rows []*SummaryRows) ([]summaryTag, error) {
+	if err := validate.Struct(direction); err != nil {
+		return nil, err
+	}
+	return selectTags(rows, direction.Tags)
+}
+
+// directionString builds the direction of a variant over the tags.
+func (direction *Direction) directionString(rows []*SummaryRows, tags []summaryTag, variant string) string {
+	result := make([]byte, len(tags))
+	for i, tag := range tags {
+		beta, ok := tag.float(rows, variant, StatisticBeta)
+		if !ok {
+			result[i] = '?'
+			continue
+		}
+		sign := byte('0')
+		switch {
+		case beta > 0:
+			sign = '+'
+		case beta < 0:
+			sign = '-'
+		}
+		if direction.SignificanceThreshold > 0 && sign != '0' {
+			pval, ok := tag.float(rows, variant, StatisticPValue)
+			significant := ok && pval < direction.SignificanceThreshold
+			switch {
+			case sign == '+' && significant:
+				sign = 'P'
+			case sign == '+':
+				sign = 'p'
+			case significant:
+				sign = 'N'
+			default:
+				sign = 'n'
+			}
+		}
+		result[i] = sign
+	}
+	return string(result)
+}
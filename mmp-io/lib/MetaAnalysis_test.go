@@ -0,0 +1,278 @@
+package lib
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetaAnalysis(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     MetaAnalysis
+		variant  string
+		expected []float64 // beta, sebeta, z, pval; nil when missing
+	}{
+		{
+			name:     "all tags",
+			variant:  "1\t12345\tA\tT",
+			expected: []float64{-0.02, math.Sqrt(1.0 / 500), -0.02 * math.Sqrt(500), 0.6547208460185770},
+		},
+		{
+			name:     "selected tag",
+			meta:     MetaAnalysis{Tags: []string{"study2"}},
+			variant:  "1\t12345\tA\tT",
+			expected: []float64{0.1, 0.05, 2, 0.04550026389635842},
+		},
+		{
+			name:     "missing tags are skipped",
+			variant:  "2\t500\tG\tC",
+			expected: []float64{0.2, 0.05, 4, 6.334248366623996e-05},
+		},
+		{
+			name:    "no estimates",
+			variant: "1\t999\tC\tG",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := SummaryOptions{Delimiter: "\t", CPRA: true, Meta: &tt.meta}
+			buffer := testMergedBlocks(t)
+			header, err := HeaderBytesStringWithOptions(buffer, options)
+			if err != nil {
+				t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(header, "\tstudy2_af\tmeta_beta\tmeta_sebeta\tmeta_z\tmeta_pval") {
+				t.Errorf("HeaderBytesStringWithOptions() = %q, want meta columns last", header)
+			}
+			rows, err := SummaryBytesStringWithOptions(buffer, options)
+			if err != nil {
+				t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+			}
+			var fields []string
+			for _, row := range rows {
+				if strings.HasPrefix(row, tt.variant+"\t") {
+					fields = strings.Split(row, "\t")
+				}
+			}
+			if len(fields) != len(strings.Split(header, "\t")) {
+				t.Fatalf("row of %q = %q, want as many fields as the header", tt.variant, fields)
+			}
+			meta := fields[len(fields)-len(metaStatistics):]
+			for i, value := range meta {
+				if tt.expected == nil {
+					if value != missingValue {
+						t.Errorf("%s = %q, want %s", metaStatistics[i], value, missingValue)
+					}
+					continue
+				}
+				got, err := strconv.ParseFloat(value, 64)
+				if err != nil || math.Abs(got-tt.expected[i]) > 1e-12*math.Max(1, math.Abs(tt.expected[i])) {
+					t.Errorf("%s = %q, want %g", metaStatistics[i], value, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRandomEffectsMetaAnalysis(t *testing.T) {
+	var buffer [][]byte
+	for _, study := range []struct{ tag, beta, sebeta string }{{"a", "0.10", "0.05"}, {"b", "0.30", "0.08"}, {"c", "-0.05", "0.10"}} {
+		data, err := proto.Marshal(&SummaryRows{
+			Header: CreateHeader(study.tag, nil),
+			Rows:   map[string]*SummaryValues{"1\t100\tA\tT": {Values: []string{"0.01", study.beta, study.sebeta, "0.2"}}},
+		})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		buffer = append(buffer, data)
+	}
+
+	// Expected values from the textbook formulas, REML by solving its score equation by bisection
+	fixed := []float64{0.12476190476190477, 0.03903600291794133}
+	heterogeneity := []float64{8.097619047619046, 0.017443127912370994, 75.30138194648632}
+	tests := []struct {
+		name     string
+		method   string
+		expected []float64 // random beta, sebeta, tau2
+	}{
+		{"DerSimonian-Laird", RandomEffectsDerSimonianLaird, []float64{0.1228386661502506, 0.08723152237989475, 0.01693783068783068}},
+		{"REML", RandomEffectsREML, []float64{0.12209686788019405, 0.09488563287357023, 0.021056779409161293}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := SummaryOptions{Delimiter: "\t", Meta: &MetaAnalysis{RandomEffects: tt.method}}
+			header, err := HeaderBytesStringWithOptions(buffer, options)
+			if err != nil {
+				t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+			}
+			want := "meta_beta\tmeta_sebeta\tmeta_z\tmeta_pval\tmeta_random_beta\tmeta_random_sebeta\tmeta_random_z\tmeta_random_pval\tmeta_q\tmeta_q_pval\tmeta_i2\tmeta_tau2"
+			if !strings.HasSuffix(header, want) {
+				t.Errorf("HeaderBytesStringWithOptions() = %q, want suffix %q", header, want)
+			}
+			rows, err := SummaryBytesStringWithOptions(buffer, options)
+			if err != nil || len(rows) != 1 {
+				t.Fatalf("SummaryBytesStringWithOptions() = %q, %v, want one row", rows, err)
+			}
+			fields := strings.Split(rows[0], "\t")
+			meta := fields[len(fields)-12:]
+			expected := map[int]float64{
+				0: fixed[0], 1: fixed[1],
+				4: tt.expected[0], 5: tt.expected[1],
+				8: heterogeneity[0], 9: heterogeneity[1], 10: heterogeneity[2], 11: tt.expected[2],
+			}
+			for i, want := range expected {
+				got, err := strconv.ParseFloat(meta[i], 64)
+				if err != nil || math.Abs(got-want) > 1e-10*math.Abs(want) {
+					t.Errorf("column %d = %q, want %g", i, meta[i], want)
+				}
+			}
+		})
+	}
+
+	// A single tag has no heterogeneity
+	options := SummaryOptions{Delimiter: "\t", Meta: &MetaAnalysis{Tags: []string{"b"}, RandomEffects: RandomEffectsDerSimonianLaird}}
+	rows, err := SummaryBytesStringWithOptions(buffer, options)
+	if err != nil {
+		t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+	}
+	want := "0.3\t0.08\t3.75\t0.0001768345704016081\t0.3\t0.08\t3.75\t0.0001768345704016081\tNA\tNA\tNA\tNA"
+	if !strings.HasSuffix(rows[0], want) {
+		t.Errorf("SummaryBytesStringWithOptions() = %q, want suffix %q", rows[0], want)
+	}
+}
+
+func TestStoufferMetaAnalysis(t *testing.T) {
+	var buffer [][]byte
+	for _, study := range []struct{ tag, pval, beta, n string }{
+		{"a", "1e-4", "0.2", "10000"},
+		{"b", "0.03", "-0.1", "2500"},
+		{"c", "0.5", "0.05", "400"},
+		{"d", "0.2", "0.3", ""},
+	} {
+		header := CreateHeader(study.tag, nil)
+		values := []string{study.pval, study.beta, "NA", "NA"}
+		if study.n != "" {
+			header = append(header, study.tag+"_n")
+			values = append(values, study.n)
+		}
+		data, err := proto.Marshal(&SummaryRows{Header: header, Rows: map[string]*SummaryValues{"1\t100\tA\tT": {Values: values}}})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		buffer = append(buffer, data)
+	}
+
+	options := SummaryOptions{Delimiter: "\t", Meta: &MetaAnalysis{Stouffer: true}}
+	header, err := HeaderBytesStringWithOptions(buffer, options)
+	if err != nil {
+		t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+	}
+	if want := "\tmeta_pval\tmeta_stouffer_z\tmeta_stouffer_pval\tmeta_stouffer_n"; !strings.HasSuffix(header, want) {
+		t.Errorf("HeaderBytesStringWithOptions() = %q, want suffix %q", header, want)
+	}
+	rows, err := SummaryBytesStringWithOptions(buffer, options)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("SummaryBytesStringWithOptions() = %q, %v, want one row", rows, err)
+	}
+	fields := strings.Split(rows[0], "\t")
+	// Without standard errors there is no inverse-variance estimate, d has no sample size
+	meta := fields[len(fields)-7:]
+	for i, value := range meta[:4] {
+		if value != missingValue {
+			t.Errorf("%s = %q, want %s", metaStatistics[i], value, missingValue)
+		}
+	}
+	for i, want := range []float64{2.5889171550326266, 0.009627825411421069, 12900} {
+		got, err := strconv.ParseFloat(meta[4+i], 64)
+		if err != nil || math.Abs(got-want) > 1e-10*want {
+			t.Errorf("%s = %q, want %g", stoufferStatistics[i], meta[4+i], want)
+		}
+	}
+}
+
+func TestChiSquarePValue(t *testing.T) {
+	tests := []struct {
+		x, df, expected float64
+	}{
+		{3.841458820694124, 1, 0.05},
+		{5.991464547107979, 2, 0.05},
+		{10, 5, 0.07523524614651217},
+		{0.5, 4, 0.9735009788392561},
+		{0, 3, 1},
+	}
+	for _, tt := range tests {
+		if got := chiSquarePValue(tt.x, tt.df); math.Abs(got-tt.expected) > 1e-12 {
+			t.Errorf("chiSquarePValue(%g, %g) = %g, want %g", tt.x, tt.df, got, tt.expected)
+		}
+	}
+}
+
+func TestMetaAnalysisErrors(t *testing.T) {
+	options := SummaryOptions{Delimiter: "\t", Meta: &MetaAnalysis{Tags: []string{"unknown"}}}
+	if _, err := HeaderBytesStringWithOptions(testMergedBlocks(t), options); err == nil {
+		t.Error("HeaderBytesStringWithOptions() expected error for unknown tag, got none")
+	}
+	if _, err := SummaryBytesStringWithOptions(testMergedBlocks(t), options); err == nil {
+		t.Error("SummaryBytesStringWithOptions() expected error for unknown tag, got none")
+	}
+	options.Meta = &MetaAnalysis{RandomEffects: "bayes"}
+	if _, err := SummaryBytesStringWithOptions(testMergedBlocks(t), options); err == nil {
+		t.Error("SummaryBytesStringWithOptions() expected error for unknown estimator, got none")
+	}
+}
+
+func TestMergedColumnsIndexSkipsMeta(t *testing.T) {
+	header := []byte("chromosome\tposition\treference\talternative\tstudy_pval\tstudy_beta\tstudy_sebeta\tstudy_af\tmeta_beta\tmeta_sebeta\tmeta_z\tmeta_pval\n")
+	index, err := CreateMergedColumnsIndex(header, MergedConfiguration{PvalThreshold: 1, Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(index) != 1 || index[0].Tag != "study" {
+		t.Errorf("CreateMergedColumnsIndex() = %+v, want one block for study", index)
+	}
+}
+
+func TestDirection(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction Direction
+		expected  []string // rows in genomic order
+	}{
+		{"signs", Direction{}, []string{"??", "-+", "+?"}},
+		{"selected tags", Direction{Tags: []string{"study2", "study_1"}}, []string{"??", "+-", "?+"}},
+		{"significance aware", Direction{SignificanceThreshold: 0.01}, []string{"??", "Np", "P?"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := SummaryOptions{Delimiter: "\t", Direction: &tt.direction}
+			buffer := testMergedBlocks(t)
+			header, err := HeaderBytesStringWithOptions(buffer, options)
+			if err != nil {
+				t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(header, "\tstudy2_af\tmeta_direction") {
+				t.Errorf("HeaderBytesStringWithOptions() = %q, want direction column last", header)
+			}
+			rows, err := SummaryBytesStringWithOptions(buffer, options)
+			if err != nil {
+				t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+			}
+			for i, row := range rows {
+				fields := strings.Split(row, "\t")
+				if got := fields[len(fields)-1]; got != tt.expected[i] {
+					t.Errorf("row %d direction = %q, want %q", i, got, tt.expected[i])
+				}
+			}
+		})
+	}
+
+	options := SummaryOptions{Delimiter: "\t", Direction: &Direction{Tags: []string{"unknown"}}}
+	if _, err := SummaryBytesStringWithOptions(testMergedBlocks(t), options); err == nil {
+		t.Error("SummaryBytesStringWithOptions() expected error for unknown tag, got none")
+	}
+}
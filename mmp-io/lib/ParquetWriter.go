@@ -0,0 +1,370 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Page compression codecs accepted by ParquetBytes.
+const (
+	ParquetCompressionNone   = "none"
+	ParquetCompressionGzip   = "gzip"
+	ParquetCompressionSnappy = "snappy"
+)
+
+// Parquet format enums, see parquet.thrift.
+const (
+	parquetTypeInt32     = 1
+	parquetTypeInt64     = 2
+	parquetTypeDouble    = 5
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetConvertedUTF8   = 0
+	parquetConvertedUint32 = 13
+	parquetConvertedUint64 = 14
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetCodecSnappy       = 1
+	parquetCodecGzip         = 2
+
+	parquetPageData = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// Thrift compact protocol types.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// thriftWriter encodes structs in the thrift compact protocol.
+type thriftWriter struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64((v<<1)^(v>>63)))
+}
+
+func (w *thriftWriter) field(id int16, thriftType byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|thriftType)
+	} else {
+		w.buf = append(w.buf, thriftType)
+		w.varint(int64(id))
+	}
+	w.last = id
+}
+
+// begin starts a struct, end writes its stop field.
+func (w *thriftWriter) begin() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+func (w *thriftWriter) i16(id int16, v int16) {
+	w.field(id, thriftI16)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) byteField(id int16, v int8) {
+	w.field(id, thriftByte)
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		w.field(id, thriftBoolTrue)
+	} else {
+		w.field(id, thriftBoolFalse)
+	}
+}
+
+func (w *thriftWriter) binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// list starts a list of size elements, which are written without field headers.
+func (w *thriftWriter) list(id int16, elementType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elementType)
+	} else {
+		w.buf = append(w.buf, 0xf0|elementType)
+		w.buf = binary.AppendUvarint(w.buf, uint64(size))
+	}
+}
+
+// parquetColumn is a leaf of the Parquet schema.
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	repetition    int32
+	convertedType int32 // -1 when none
+	bitWidth      int8  // unsigned integer logical type, 0 when none
+}
+
+func parquetSchemaColumns(names []string) []parquetColumn {
+	columns := []parquetColumn{
+		{cpraHeader[0], parquetTypeInt32, parquetRequired, parquetConvertedUint32, 32},
+		{cpraHeader[1], parquetTypeInt64, parquetRequired, parquetConvertedUint64, 64},
+		{cpraHeader[2], parquetTypeByteArray, parquetRequired, parquetConvertedUTF8, 0},
+		{cpraHeader[3], parquetTypeByteArray, parquetRequired, parquetConvertedUTF8, 0},
+	}
+	for _, name := range names {
+		columns = append(columns, parquetColumn{name, parquetTypeDouble, parquetOptional, -1, 0})
+	}
+	return columns
+}
+
+func (column parquetColumn) writeSchemaElement(w *thriftWriter) {
+	w.begin()
+	w.i32(1, column.physicalType)
+	w.i32(3, column.repetition)
+	w.binary(4, column.name)
+	if column.convertedType >= 0 {
+		w.i32(6, column.convertedType)
+		w.structField(10)
+		if column.bitWidth > 0 {
+			w.structField(10) // IntType
+			w.byteField(1, column.bitWidth)
+			w.boolField(2, false)
+		} else {
+			w.structField(1) // StringType
+		}
+		w.end()
+		w.end()
+	}
+	w.end()
+}
+
+// parquetChunk is the metadata of a column chunk written to the file.
+type parquetChunk struct {
+	offset           int64
+	values           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// parquetLevels encodes definition levels of bit width 1 as RLE runs, prefixed by their length.
+func parquetLevels(valid []bool) []byte {
+	var runs []byte
+	for i := 0; i < len(valid); {
+		j := i
+		for j < len(valid) && valid[j] == valid[i] {
+			j++
+		}
+		runs = binary.AppendUvarint(runs, uint64(j-i)<<1)
+		if valid[i] {
+			runs = append(runs, 1)
+		} else {
+			runs = append(runs, 0)
+		}
+		i = j
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(runs))), runs...)
+}
+
+// parquetPages returns the plain encoded page of every column of a table, definition
+// levels included for the optional statistic columns.
+func parquetPages(table *typedSummaryTable) [][]byte {
+	var pages [][]byte
+	var page []byte
+	for _, chromosome := range table.Chromosomes {
+		page = binary.LittleEndian.AppendUint32(page, chromosome)
+	}
+	pages = append(pages, page)
+	page = nil
+	for _, position := range table.Positions {
+		page = binary.LittleEndian.AppendUint64(page, position)
+	}
+	pages = append(pages, page)
+	for _, values := range [][]string{table.Refs, table.Alts} {
+		page = nil
+		for _, value := range values {
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(value)))
+			page = append(page, value...)
+		}
+		pages = append(pages, page)
+	}
+	for c, column := range table.Columns {
+		page = parquetLevels(table.Valid[c])
+		for i, value := range column {
+			if table.Valid[c][i] {
+				page = binary.LittleEndian.AppendUint64(page, math.Float64bits(value))
+			}
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func parquetCompress(page []byte, compression string) ([]byte, int32, error) {
+	switch compression {
+	case "", ParquetCompressionNone:
+		return page, parquetCodecUncompressed, nil
+	case ParquetCompressionSnappy:
+		return snappyEncode(page), parquetCodecSnappy, nil
+	case ParquetCompressionGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(page); err != nil {
+			return nil, 0, fmt.Errorf("gzip: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, 0, fmt.Errorf("gzip: %w", err)
+		}
+		return buffer.Bytes(), parquetCodecGzip, nil
+	}
+	return nil, 0, fmt.Errorf("unknown parquet compression %q", compression)
+}
+
+// ParquetBytes writes the merged table as a Parquet file. Each partition holds the
+// blocks of every source, as passed to SummaryBytesString, and becomes one row group.
+// Variant columns are required (uint32 chromosome, uint64 position, utf8 alleles) and
+// every statistic column is an optional double, null for missing values. Pages are
+// compressed with compression: "none" (or empty), "gzip" or "snappy".
+func ParquetBytes(partitions [][][]byte, delimiter string, compression string) ([]byte, error) {
+	_, codec, err := parquetCompress(nil, compression)
+	if err != nil {
+		return nil, err
+	}
+	tables, names, err := typedSummaryTables(partitions, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	columns := parquetSchemaColumns(names)
+
+	out := append([]byte(nil), parquetMagic...)
+	var rowGroups [][]parquetChunk
+	var numRows int64
+	for _, table := range tables {
+		rows := len(table.Chromosomes)
+		if rows == 0 {
+			continue
+		}
+		numRows += int64(rows)
+		var chunks []parquetChunk
+		for _, page := range parquetPages(table) {
+			compressed, _, err := parquetCompress(page, compression)
+			if err != nil {
+				return nil, err
+			}
+			header := &thriftWriter{}
+			header.begin()
+			header.i32(1, parquetPageData)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(compressed)))
+			header.structField(5)
+			header.i32(1, int32(rows))
+			header.i32(2, parquetEncodingPlain)
+			header.i32(3, parquetEncodingRLE)
+			header.i32(4, parquetEncodingRLE)
+			header.end()
+			header.end()
+			chunks = append(chunks, parquetChunk{
+				offset:           int64(len(out)),
+				values:           int64(rows),
+				uncompressedSize: int64(len(header.buf) + len(page)),
+				compressedSize:   int64(len(header.buf) + len(compressed)),
+			})
+			out = append(out, header.buf...)
+			out = append(out, compressed...)
+		}
+		rowGroups = append(rowGroups, chunks)
+	}
+
+	w := &thriftWriter{}
+	w.begin()
+	w.i32(1, 1)
+	w.list(2, thriftStruct, len(columns)+1)
+	w.begin()
+	w.binary(4, "schema")
+	w.i32(5, int32(len(columns)))
+	w.end()
+	for _, column := range columns {
+		column.writeSchemaElement(w)
+	}
+	w.i64(3, numRows)
+	w.list(4, thriftStruct, len(rowGroups))
+	for ordinal, chunks := range rowGroups {
+		var uncompressedSize, compressedSize int64
+		w.begin()
+		w.list(1, thriftStruct, len(chunks))
+		for c, chunk := range chunks {
+			uncompressedSize += chunk.uncompressedSize
+			compressedSize += chunk.compressedSize
+			w.begin()
+			w.i64(2, chunk.offset)
+			w.structField(3)
+			w.i32(1, columns[c].physicalType)
+			w.list(2, thriftI32, 2)
+			w.varint(parquetEncodingPlain)
+			w.varint(parquetEncodingRLE)
+			w.list(3, thriftBinary, 1)
+			w.buf = binary.AppendUvarint(w.buf, uint64(len(columns[c].name)))
+			w.buf = append(w.buf, columns[c].name...)
+			w.i32(4, codec)
+			w.i64(5, chunk.values)
+			w.i64(6, chunk.uncompressedSize)
+			w.i64(7, chunk.compressedSize)
+			w.i64(9, chunk.offset)
+			w.end()
+			w.end()
+		}
+		w.i64(2, uncompressedSize)
+		w.i64(3, chunks[0].values)
+		w.i64(5, chunks[0].offset)
+		w.i64(6, compressedSize)
+		w.i16(7, int16(ordinal))
+		w.end()
+	}
+	w.binary(6, "mmp-io")
+	w.end()
+
+	out = append(out, w.buf...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(w.buf)))
+	return append(out, parquetMagic...), nil
+}
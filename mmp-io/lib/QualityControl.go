@@ -0,0 +1,456 @@
+package lib
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Reasons of QualityControlCounts.ParseFailures.
+const (
+	qcInsufficientColumns = "insufficient_columns"
+	qcMalformedLine       = "malformed_line"
+	qcInvalidChromosome   = "invalid_chromosome"
+	qcInvalidPosition     = "invalid_position"
+	qcInvalidPValue       = "invalid_pval"
+	qcPValueOutOfRange    = "pval_out_of_range"
+	qcInvalidBeta         = "invalid_beta"
+	qcInvalidSEBeta       = "invalid_sebeta"
+	qcSEBetaNotPositive   = "sebeta_not_positive"
+	qcInvalidAF           = "invalid_af"
+	qcAFOutOfRange        = "af_out_of_range"
+	qcInvalidSampleSize   = "invalid_sample_size"
+)
+
+// P-value thresholds counted by PValueDistribution.
+const (
+	genomeWideThreshold = 5e-8
+	suggestiveThreshold = 1e-5
+)
+
+// qcMAFBins are the upper bounds of the MAF bins of AlleleFrequencyDistribution,
+// the first bin holding the monomorphic variants.
+var qcMAFBins = []float64{0, 0.001, 0.01, 0.05, 0.1, 0.2, 0.5}
+
+// The ratios of StandardErrorConsistency are counted in log10 bins of width
+// 1/seRatioBinsPerDecade over [-seRatioDecades, seRatioDecades].
+const (
+	seRatioDecades       = 4
+	seRatioBinsPerDecade = 100
+)
+
+// seRatioOutlier is the factor a ratio differs from the median ratio by to be an outlier.
+const seRatioOutlier = 2
+
+// QualityControlCounts holds the statistics of one buffer of a delimited file.
+// BufferQualityControl is called on every buffer of the file and the counts are
+// merged in file order by QualityControl. First and Last are the first and last
+// variants of the buffer, which tell whether the file stays sorted across buffers,
+// and KeyHashes the sorted 64-bit hashes of its variant keys, which tell whether
+// variants are duplicated across buffers.
+type QualityControlCounts struct {
+	Rows          uint64               `json:"rows"`
+	FailedRows    uint64               `json:"failed_rows"`
+	ParseFailures map[string]uint64    `json:"parse_failures,omitempty"`
+	Missing       map[string]uint64    `json:"missing,omitempty"`
+	PValues       []uint64             `json:"pvalues"`
+	MinPValue     *float64             `json:"min_pval,omitempty"`
+	GenomeWide    uint64               `json:"genome_wide"`
+	Suggestive    uint64               `json:"suggestive"`
+	AFSum         float64              `json:"af_sum"`
+	MAFBins       []uint64             `json:"maf_bins"`
+	SERatios      []uint64             `json:"se_ratios"`
+	Duplicates    uint64               `json:"duplicates"`
+	UnsortedRows  uint64               `json:"unsorted_rows"`
+	Chromosomes   map[uint32]*Coverage `json:"chromosomes,omitempty"`
+	First         *Variant             `json:"first,omitempty"`
+	Last          *Variant             `json:"last,omitempty"`
+	KeyHashes     []byte               `json:"key_hashes,omitempty"`
+}
+
+// Coverage counts the variants of one chromosome and the range of their positions.
+type Coverage struct {
+	Variants    uint64 `json:"variants"`
+	MinPosition uint64 `json:"min_position"`
+	MaxPosition uint64 `json:"max_position"`
+}
+
+// ChromosomeCoverage is the Coverage of one chromosome, 23 to 25 being X, Y and MT.
+type ChromosomeCoverage struct {
+	Chromosome uint32 `json:"chromosome"`
+	Coverage
+}
+
+// PValueDistribution summarises the valid p-values of the file: their lambda, the
+// smallest one, the number below the genome-wide (5e-8) and suggestive (1e-5)
+// thresholds and the number in each decile of [0, 1], a tenth each under the null.
+type PValueDistribution struct {
+	GenomicControlLambda
+	Min        *float64 `json:"min"`
+	GenomeWide uint64   `json:"genome_wide"`
+	Suggestive uint64   `json:"suggestive"`
+	Deciles    []uint64 `json:"deciles"`
+}
+
+// MAFBinCount is the number of variants with a MAF above the previous bin up to MaxMAF.
+type MAFBinCount struct {
+	MaxMAF   float64 `json:"max_maf"`
+	Variants uint64  `json:"variants"`
+}
+
+// AlleleFrequencyDistribution summarises the valid allele frequencies of the file.
+// The first MAF bin holds the monomorphic variants.
+type AlleleFrequencyDistribution struct {
+	Variants uint64        `json:"variants"`
+	Mean     *float64      `json:"mean"`
+	MAFBins  []MAFBinCount `json:"maf_bins"`
+}
+
+// StandardErrorConsistency compares the standard errors with those expected from the
+// allele frequency and sample size, which scale as 1/sqrt(2·N·MAF·(1-MAF)). The ratio
+// of the two is about the phenotype standard deviation for quantitative traits and
+// should be the same for every variant; Outliers differ from MedianRatio by more than
+// a factor of 2. Variants are only compared when the file has a sample size.
+type StandardErrorConsistency struct {
+	Variants    uint64   `json:"variants"`
+	MedianRatio *float64 `json:"median_ratio"`
+	Outliers    uint64   `json:"outliers"`
+}
+
+// QualityControlReport describes one delimited file. FailedRows counts the rows with
+// at least one ParseFailures reason and Missing the statistics holding a missing
+// value. Duplicates counts the rows of a variant, and phenotype for long format
+// files, seen before anywhere in the file. Sorted tells whether the rows are in chromosome and
+// position order and UnsortedRows counts the rows before their predecessor.
+type QualityControlReport struct {
+	Rows              uint64                      `json:"rows"`
+	FailedRows        uint64                      `json:"failed_rows"`
+	ParseFailures     map[string]uint64           `json:"parse_failures"`
+	Missing           map[string]uint64           `json:"missing"`
+	PValues           PValueDistribution          `json:"pval"`
+	AlleleFrequencies AlleleFrequencyDistribution `json:"af"`
+	StandardErrors    StandardErrorConsistency    `json:"se_consistency"`
+	Duplicates        uint64                      `json:"duplicates"`
+	Chromosomes       []ChromosomeCoverage        `json:"chromosomes"`
+	MissingAutosomes  []uint32                    `json:"missing_autosomes"`
+	Sorted            bool                        `json:"sorted"`
+	UnsortedRows      uint64                      `json:"unsorted_rows"`
+}
+
+func newQualityControlCounts() QualityControlCounts {
+	return QualityControlCounts{
+		ParseFailures: make(map[string]uint64),
+		Missing:       make(map[string]uint64),
+		PValues:       make([]uint64, genomicControlBins),
+		MAFBins:       make([]uint64, len(qcMAFBins)),
+		SERatios:      make([]uint64, 2*seRatioDecades*seRatioBinsPerDecade),
+		Chromosomes:   make(map[uint32]*Coverage),
+	}
+}
+
+// qcRow parses the statistics of one row, recording the failures and missing values.
+type qcRow struct {
+	counts   *QualityControlCounts
+	metadata BlockMetadata
+	row      []string
+	failed   bool
+}
+
+func (r *qcRow) fail(reason string) {
+	r.counts.ParseFailures[reason]++
+	r.failed = true
+}
+
+// float parses a column, ok is false when it is missing or invalid.
+func (r *qcRow) float(column int, statistic string, invalid string) (float64, bool) {
+	value := r.row[column]
+	if isMissingValue(value, r.metadata) {
+		r.counts.Missing[statistic]++
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		r.fail(invalid)
+		return 0, false
+	}
+	return v, true
+}
+
+// sampleSize returns the sample size of the row, ok is false when there is none.
+func (r *qcRow) sampleSize() (float64, bool) {
+	if r.metadata.ColumnSampleSize == nil {
+		return float64(r.metadata.SampleSize), r.metadata.SampleSize > 0
+	}
+	n, ok := r.float(*r.metadata.ColumnSampleSize, StatisticSampleSize, qcInvalidSampleSize)
+	if ok && n < 0 {
+		r.fail(qcInvalidSampleSize)
+		return 0, false
+	}
+	return n, ok && n > 0
+}
+
+// keyHash is the 64-bit hash duplicates are found by, keys are not kept across buffers.
+func keyHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
+
+// addVariant updates the coverage, order and duplicates with a parsed variant.
+func (counts *QualityControlCounts) addVariant(variant *Variant, key string, seen map[uint64]bool) {
+	hash := keyHash(key)
+	if seen[hash] {
+		counts.Duplicates++
+	}
+	seen[hash] = true
+	if counts.Last != nil && compareVariantPositions(variant, counts.Last) < 0 {
+		counts.UnsortedRows++
+	}
+	if counts.First == nil {
+		counts.First = variant
+	}
+	counts.Last = variant
+
+	coverage := counts.Chromosomes[variant.Chromosome]
+	if coverage == nil {
+		coverage = &Coverage{MinPosition: variant.Position, MaxPosition: variant.Position}
+		counts.Chromosomes[variant.Chromosome] = coverage
+	}
+	coverage.Variants++
+	coverage.MinPosition = min(coverage.MinPosition, variant.Position)
+	coverage.MaxPosition = max(coverage.MaxPosition, variant.Position)
+}
+
+// compareVariantPositions orders variants by chromosome and position only.
+func compareVariantPositions(a, b *Variant) int {
+	return cmp.Or(cmp.Compare(a.Chromosome, b.Chromosome), cmp.Compare(a.Position, b.Position))
+}
+
+// addStatistics counts the statistics of a row with a valid variant.
+func (r *qcRow) addStatistics() {
+	counts, index := r.counts, r.metadata.FileColumnsIndex
+	if pvalue, ok := r.float(index.ColumnPValue, StatisticPValue, qcInvalidPValue); ok {
+		if pvalue < 0 || pvalue > 1 || math.IsNaN(pvalue) {
+			r.fail(qcPValueOutOfRange)
+		} else {
+			counts.PValues[min(int(pvalue*genomicControlBins), genomicControlBins-1)]++
+			if counts.MinPValue == nil || pvalue < *counts.MinPValue {
+				counts.MinPValue = &pvalue
+			}
+			if pvalue < genomeWideThreshold {
+				counts.GenomeWide++
+			}
+			if pvalue < suggestiveThreshold {
+				counts.Suggestive++
+			}
+		}
+	}
+	r.float(index.ColumnBeta, StatisticBeta, qcInvalidBeta)
+	sebeta, hasSEBeta := r.float(index.ColumnSEBeta, StatisticSEBeta, qcInvalidSEBeta)
+	if hasSEBeta && !(sebeta > 0) {
+		r.fail(qcSEBetaNotPositive)
+		hasSEBeta = false
+	}
+	af, hasAF := r.float(index.ColumnAlleleFrequency, StatisticAlleleFrequency, qcInvalidAF)
+	maf := minorAlleleFrequency(af)
+	if hasAF && math.IsNaN(maf) {
+		r.fail(qcAFOutOfRange)
+		hasAF = false
+	}
+	if hasAF {
+		counts.AFSum += af
+		for i, bound := range qcMAFBins {
+			if maf <= bound {
+				counts.MAFBins[i]++
+				break
+			}
+		}
+	}
+	n, hasN := r.sampleSize()
+	if hasSEBeta && hasAF && hasN && maf > 0 {
+		ratio := math.Log10(sebeta * math.Sqrt(2*n*maf*(1-maf)))
+		bin := int(math.Floor((ratio + seRatioDecades) * seRatioBinsPerDecade))
+		counts.SERatios[max(0, min(bin, len(counts.SERatios)-1))]++
+	}
+}
+
+// BufferQualityControl collects the QualityControlCounts of one buffer of a delimited
+// file. Unlike the other parsers it reads past malformed rows, counting why they
+// could not be read.
+func BufferQualityControl(buffer []byte, metadata BlockMetadata) (QualityControlCounts, error) {
+	if metadata.FileFormat == FileFormatGWASVCF {
+		return QualityControlCounts{}, fmt.Errorf("quality control is only available for delimited files")
+	}
+	counts := newQualityControlCounts()
+	tableReader := newTableReader(buffer, metadata)
+	tableReader.FieldsPerRecord = -1
+	requiredLen := max(metadata.FileColumnsIndex.ColumnChromosome, metadata.FileColumnsIndex.ColumnPosition,
+		metadata.FileColumnsIndex.ColumnReference, metadata.FileColumnsIndex.ColumnAlternate,
+		metadata.FileColumnsIndex.ColumnBeta, metadata.FileColumnsIndex.ColumnSEBeta,
+		metadata.FileColumnsIndex.ColumnPValue, metadata.FileColumnsIndex.ColumnAlleleFrequency,
+		phenotypeColumn(metadata), sampleSizeColumn(metadata)) + 1
+	seen := make(map[uint64]bool)
+
+	for {
+		row, err := tableReader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			counts.Rows++
+			counts.FailedRows++
+			counts.ParseFailures[qcMalformedLine]++
+			continue
+		} else if err != nil {
+			return QualityControlCounts{}, err
+		}
+
+		counts.Rows++
+		r := qcRow{counts: &counts, metadata: metadata, row: row}
+		if len(row) < requiredLen {
+			r.fail(qcInsufficientColumns)
+		} else if _, err := parseChromosome(row[metadata.ColumnChromosome]); err != nil {
+			r.fail(qcInvalidChromosome)
+		} else if variant, err := parseVariant(row, metadata.FileColumnsIndex); err != nil {
+			r.fail(qcInvalidPosition)
+		} else {
+			key := variantKey(variant, metadata.Delimiter)
+			if metadata.ColumnPhenotype != nil {
+				key += metadata.Delimiter + row[*metadata.ColumnPhenotype]
+			}
+			counts.addVariant(variant, key, seen)
+			r.addStatistics()
+		}
+		if r.failed {
+			counts.FailedRows++
+		}
+	}
+	hashes := slices.Sorted(maps.Keys(seen))
+	counts.KeyHashes = make([]byte, 0, 8*len(hashes))
+	for _, hash := range hashes {
+		counts.KeyHashes = binary.BigEndian.AppendUint64(counts.KeyHashes, hash)
+	}
+	return counts, nil
+}
+
+// QualityControl merges the counts of the buffers of a file, in file order, into its report.
+func QualityControl(counts []QualityControlCounts) QualityControlReport {
+	total := newQualityControlCounts()
+	seen := make(map[uint64]bool)
+	for _, count := range counts {
+		if total.Last != nil && count.First != nil && compareVariantPositions(count.First, total.Last) < 0 {
+			total.UnsortedRows++
+		}
+		if count.First != nil {
+			if total.First == nil {
+				total.First = count.First
+			}
+			total.Last = count.Last
+		}
+		for i := 0; i+8 <= len(count.KeyHashes); i += 8 {
+			hash := binary.BigEndian.Uint64(count.KeyHashes[i:])
+			if seen[hash] {
+				total.Duplicates++
+			}
+			seen[hash] = true
+		}
+		total.Rows += count.Rows
+		total.FailedRows += count.FailedRows
+		for reason, n := range count.ParseFailures {
+			total.ParseFailures[reason] += n
+		}
+		for statistic, n := range count.Missing {
+			total.Missing[statistic] += n
+		}
+		total.PValues = addHistogram(total.PValues, count.PValues)
+		if count.MinPValue != nil && (total.MinPValue == nil || *count.MinPValue < *total.MinPValue) {
+			total.MinPValue = count.MinPValue
+		}
+		total.GenomeWide += count.GenomeWide
+		total.Suggestive += count.Suggestive
+		total.AFSum += count.AFSum
+		total.MAFBins = addHistogram(total.MAFBins, count.MAFBins)
+		total.SERatios = addHistogram(total.SERatios, count.SERatios)
+		total.Duplicates += count.Duplicates
+		total.UnsortedRows += count.UnsortedRows
+		for chromosome, coverage := range count.Chromosomes {
+			merged := total.Chromosomes[chromosome]
+			if merged == nil {
+				merged = &Coverage{MinPosition: coverage.MinPosition, MaxPosition: coverage.MaxPosition}
+				total.Chromosomes[chromosome] = merged
+			}
+			merged.Variants += coverage.Variants
+			merged.MinPosition = min(merged.MinPosition, coverage.MinPosition)
+			merged.MaxPosition = max(merged.MaxPosition, coverage.MaxPosition)
+		}
+	}
+	return total.report()
+}
+
+func (total QualityControlCounts) report() QualityControlReport {
+	report := QualityControlReport{
+		Rows:          total.Rows,
+		FailedRows:    total.FailedRows,
+		ParseFailures: total.ParseFailures,
+		Missing:       total.Missing,
+		Duplicates:    total.Duplicates,
+		Sorted:        total.UnsortedRows == 0,
+		UnsortedRows:  total.UnsortedRows,
+	}
+
+	report.PValues = PValueDistribution{
+		GenomicControlLambda: histogramLambda(total.PValues),
+		Min:                  total.MinPValue,
+		GenomeWide:           total.GenomeWide,
+		Suggestive:           total.Suggestive,
+		Deciles:              make([]uint64, 10),
+	}
+	for i, count := range total.PValues {
+		report.PValues.Deciles[i*10/len(total.PValues)] += count
+	}
+
+	for i, bound := range qcMAFBins {
+		report.AlleleFrequencies.MAFBins = append(report.AlleleFrequencies.MAFBins, MAFBinCount{MaxMAF: bound, Variants: total.MAFBins[i]})
+		report.AlleleFrequencies.Variants += total.MAFBins[i]
+	}
+	if report.AlleleFrequencies.Variants > 0 {
+		report.AlleleFrequencies.Mean = finite(total.AFSum / float64(report.AlleleFrequencies.Variants))
+	}
+
+	variants, median := histogramMedian(total.SERatios)
+	report.StandardErrors.Variants = variants
+	if variants > 0 {
+		logMedian := median/seRatioBinsPerDecade - seRatioDecades
+		report.StandardErrors.MedianRatio = finite(math.Pow(10, logMedian))
+		for i, count := range total.SERatios {
+			center := (float64(i)+0.5)/seRatioBinsPerDecade - seRatioDecades
+			if math.Abs(center-logMedian) > math.Log10(seRatioOutlier) {
+				report.StandardErrors.Outliers += count
+			}
+		}
+	}
+
+	report.Chromosomes = []ChromosomeCoverage{}
+	for chromosome, coverage := range total.Chromosomes {
+		report.Chromosomes = append(report.Chromosomes, ChromosomeCoverage{Chromosome: chromosome, Coverage: *coverage})
+	}
+	slices.SortFunc(report.Chromosomes, func(a, b ChromosomeCoverage) int { return cmp.Compare(a.Chromosome, b.Chromosome) })
+	report.MissingAutosomes = []uint32{}
+	for chromosome := uint32(1); chromosome <= 22; chromosome++ {
+		if total.Chromosomes[chromosome] == nil {
+			report.MissingAutosomes = append(report.MissingAutosomes, chromosome)
+		}
+	}
+	return report
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func qualityControlTestMetadata() BlockMetadata {
+	metadata := genomicControlTestMetadata()
+	metadata.SampleSize = 10000
+	return metadata
+}
+
+// qualityControlTestRows are sorted but for 1:150, repeat 1:200 and mostly fail to
+// parse from 2:400 on.
+var qualityControlTestRows = []string{
+	"1\t100\tA\tT\t1e-9\t0.2\t0.0144\t0.4",
+	"1\t200\tA\tT\t0.5\t0.01\t0.0144\t0.6",
+	"1\t200\tA\tT\t0.5\t0.01\t0.0144\t0.6",
+	"1\t150\tA\tT\t0.2\t0.05\t0.072\t0.4",
+	"2\t300\tA\tT\tNA\t0.1\t0.02\t0.1",
+	"2\t400\tA\tT\t2\t0.1\t0.02\t0.001",
+	"Z\t500\tA\tT\t0.1\t0.1\t0.02\t0.1",
+	"2\tabc\tA\tT\t0.1\t0.1\t0.02\t0.1",
+	"2\t600\tA\tT\t0.3\tx\t-1\t0",
+	"2\t700\tA\tT\t0.01",
+	"X\t800\tA\tT\t1e-6\t0.1\t0.1\t1.5",
+}
+
+func TestQualityControl(t *testing.T) {
+	metadata := qualityControlTestMetadata()
+	counts, err := BufferQualityControl([]byte(strings.Join(qualityControlTestRows, "\n")+"\n"), metadata)
+	if err != nil {
+		t.Fatalf("BufferQualityControl() unexpected error: %v", err)
+	}
+	report := QualityControl([]QualityControlCounts{counts})
+
+	if report.Rows != 11 || report.FailedRows != 6 || report.Duplicates != 1 || report.Sorted || report.UnsortedRows != 1 {
+		t.Errorf("report = %d rows, %d failed, %d duplicates, sorted %v with %d unsorted, want 11, 6, 1, false, 1",
+			report.Rows, report.FailedRows, report.Duplicates, report.Sorted, report.UnsortedRows)
+	}
+	failures := map[string]uint64{
+		qcPValueOutOfRange: 1, qcInvalidChromosome: 1, qcInvalidPosition: 1, qcInvalidBeta: 1,
+		qcSEBetaNotPositive: 1, qcInsufficientColumns: 1, qcAFOutOfRange: 1,
+	}
+	if !reflect.DeepEqual(report.ParseFailures, failures) {
+		t.Errorf("ParseFailures = %v, want %v", report.ParseFailures, failures)
+	}
+	if !reflect.DeepEqual(report.Missing, map[string]uint64{StatisticPValue: 1}) {
+		t.Errorf("Missing = %v, want one pval", report.Missing)
+	}
+
+	pvalues := report.PValues
+	if pvalues.Variants != 6 || pvalues.Min == nil || *pvalues.Min != 1e-9 || pvalues.GenomeWide != 1 || pvalues.Suggestive != 2 ||
+		!reflect.DeepEqual(pvalues.Deciles, []uint64{2, 0, 1, 1, 0, 2, 0, 0, 0, 0}) || pvalues.Lambda == nil {
+		t.Errorf("PValues = %+v", pvalues)
+	}
+
+	afs := report.AlleleFrequencies
+	mafBins := []uint64{1, 1, 0, 0, 1, 0, 4}
+	for i, bin := range afs.MAFBins {
+		if bin.MaxMAF != qcMAFBins[i] || bin.Variants != mafBins[i] {
+			t.Errorf("MAF bin %d = %+v, want %d variants", i, bin, mafBins[i])
+		}
+	}
+	if afs.Variants != 7 || afs.Mean == nil || math.Abs(*afs.Mean-2.101/7) > 1e-12 {
+		t.Errorf("AlleleFrequencies = %d variants with mean %v, want 7 with %g", afs.Variants, afs.Mean, 2.101/7)
+	}
+
+	// 1:150 is 5 times and 2:400 11 times below the expected standard error
+	se := report.StandardErrors
+	if se.Variants != 6 || se.MedianRatio == nil || math.Abs(*se.MedianRatio-0.9977) > 0.03 || se.Outliers != 2 {
+		t.Errorf("StandardErrors = %d variants, median ratio %v, %d outliers, want 6, 1, 2", se.Variants, se.MedianRatio, se.Outliers)
+	}
+
+	chromosomes := []ChromosomeCoverage{
+		{Chromosome: 1, Coverage: Coverage{Variants: 4, MinPosition: 100, MaxPosition: 200}},
+		{Chromosome: 2, Coverage: Coverage{Variants: 3, MinPosition: 300, MaxPosition: 600}},
+		{Chromosome: 23, Coverage: Coverage{Variants: 1, MinPosition: 800, MaxPosition: 800}},
+	}
+	if !reflect.DeepEqual(report.Chromosomes, chromosomes) {
+		t.Errorf("Chromosomes = %+v, want %+v", report.Chromosomes, chromosomes)
+	}
+	if len(report.MissingAutosomes) != 20 || report.MissingAutosomes[0] != 3 {
+		t.Errorf("MissingAutosomes = %v, want 3 to 22", report.MissingAutosomes)
+	}
+}
+
+func TestQualityControlBuffers(t *testing.T) {
+	metadata := qualityControlTestMetadata()
+	whole, err := BufferQualityControl([]byte(strings.Join(qualityControlTestRows, "\n")), metadata)
+	if err != nil {
+		t.Fatalf("BufferQualityControl() unexpected error: %v", err)
+	}
+	// The duplicate 1:200 and the unsorted 1:150 straddle the buffers
+	var counts []QualityControlCounts
+	for _, rows := range [][]string{qualityControlTestRows[:2], qualityControlTestRows[2:3], qualityControlTestRows[3:]} {
+		count, err := BufferQualityControl([]byte(strings.Join(rows, "\n")), metadata)
+		if err != nil {
+			t.Fatalf("BufferQualityControl() unexpected error: %v", err)
+		}
+		// Counts travel through JSON between the calls
+		data, err := json.Marshal(count)
+		if err != nil {
+			t.Fatalf("counts are not valid JSON: %v", err)
+		}
+		var decoded QualityControlCounts
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("failed to decode counts: %v", err)
+		}
+		counts = append(counts, decoded)
+	}
+	expected, err := json.Marshal(QualityControl([]QualityControlCounts{whole}))
+	if err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	got, _ := json.Marshal(QualityControl(counts))
+	if string(got) != string(expected) {
+		t.Errorf("QualityControl() of buffers = %s, want %s", got, expected)
+	}
+}
+
+func TestQualityControlDuplicatesAcrossBuffers(t *testing.T) {
+	metadata := qualityControlTestMetadata()
+	// 1:100 comes back two buffers later and twice in the last one
+	var counts []QualityControlCounts
+	for _, rows := range []string{
+		"1\t100\tA\tT\t0.5\t0.1\t0.1\t0.1\n1\t200\tA\tT\t0.5\t0.1\t0.1\t0.1\n",
+		"1\t300\tA\tT\t0.5\t0.1\t0.1\t0.1\n",
+		"1\t100\tA\tT\t0.5\t0.1\t0.1\t0.1\n1\t100\tA\tT\t0.5\t0.1\t0.1\t0.1\n",
+	} {
+		count, err := BufferQualityControl([]byte(rows), metadata)
+		if err != nil {
+			t.Fatalf("BufferQualityControl() unexpected error: %v", err)
+		}
+		counts = append(counts, count)
+	}
+	if report := QualityControl(counts); report.Duplicates != 2 {
+		t.Errorf("Duplicates = %d, want 2", report.Duplicates)
+	}
+}
+
+func TestQualityControlMalformedLine(t *testing.T) {
+	buffer := "1\t100\tA\"C\tT\t0.5\t0.1\t0.1\t0.1\n1\t200\tA\tT\t0.5\t0.1\t0.1\t0.1\n"
+	counts, err := BufferQualityControl([]byte(buffer), qualityControlTestMetadata())
+	if err != nil {
+		t.Fatalf("BufferQualityControl() unexpected error: %v", err)
+	}
+	report := QualityControl([]QualityControlCounts{counts})
+	if report.Rows != 2 || report.ParseFailures[qcMalformedLine] != 1 || report.PValues.Variants != 1 || !report.Sorted {
+		t.Errorf("report = %+v, want one malformed and one valid row", report)
+	}
+
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if _, err := BufferQualityControl([]byte(testVCFData), metadata); err == nil {
+		t.Error("BufferQualityControl() expected error for GWAS-VCF, got none")
+	}
+}
@@ -0,0 +1,376 @@
+package lib
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Defaults of ReferencePanelConfiguration.
+const (
+	defaultReferenceMaxDifference = 0.2
+	defaultReferenceAFColumn      = "af"
+)
+
+// ReferencePanelConfiguration describes a reference allele frequency table, one
+// variant per line with a header naming the columns, and how the allele frequencies
+// of the tags are compared with it. Delimiter is the delimiter of the variant keys
+// of the blocks, as given to SummaryBytesString, and ReferenceDelimiter that of the
+// table, a tab when empty. The variant columns default to the names of the merged
+// output (chromosome, position, reference and alternative) and afColumn to "af";
+// a leading "#" of the header is ignored. A variant is an outlier of a tag when its
+// allele frequency differs from the reference one by more than MaxDifference, 0.2
+// when zero. Tags restricts the check to the listed tags, every tag when empty.
+type ReferencePanelConfiguration struct {
+	Delimiter             string   `json:"delimiter" validate:"required"`
+	ReferenceDelimiter    string   `json:"reference_delimiter,omitempty"`
+	ColumnChromosome      string   `json:"chromosomeColumn,omitempty"`
+	ColumnPosition        string   `json:"positionColumn,omitempty"`
+	ColumnReference       string   `json:"referenceColumn,omitempty"`
+	ColumnAlternate       string   `json:"alternativeColumn,omitempty"`
+	ColumnAlleleFrequency string   `json:"afColumn,omitempty"`
+	MaxDifference         float64  `json:"max_difference,omitempty" validate:"gte=0,lte=1"`
+	Tags                  []string `json:"tags,omitempty" validate:"dive,required"`
+}
+
+// AlleleFrequencyReport holds the comparison of every tag with the reference panel.
+// SkippedReferenceRows counts the rows of the panel on chromosomes that cannot be
+// read, such as unplaced contigs.
+type AlleleFrequencyReport struct {
+	Tags                 []TagAlleleFrequency `json:"tags"`
+	SkippedReferenceRows int                  `json:"skipped_reference_rows"`
+}
+
+// TagAlleleFrequency compares the allele frequencies of one tag with the reference
+// panel over the Shared variants found in both, with the same or swapped alleles.
+// Flipped counts the variants with swapped alleles whose frequency matches the
+// reference one of the other allele, and the outliers whose frequency does.
+// SuspectedFlip is set when they are more than half of the shared variants, as when
+// a file reports the frequency of the reference allele. Correlation is null below
+// three shared variants or without variation.
+type TagAlleleFrequency struct {
+	Tag           string                   `json:"tag"`
+	Shared        int                      `json:"shared"`
+	Correlation   *float64                 `json:"correlation"`
+	Flipped       int                      `json:"flipped"`
+	SuspectedFlip bool                     `json:"suspected_flip"`
+	Outliers      []AlleleFrequencyOutlier `json:"outliers"`
+}
+
+// AlleleFrequencyOutlier is a variant whose allele frequency differs from the
+// reference one of its alternate allele by more than the maximum difference.
+type AlleleFrequencyOutlier struct {
+	Variant     *Variant `json:"variant"`
+	AF          float64  `json:"af"`
+	ReferenceAF float64  `json:"reference_af"`
+	Flipped     bool     `json:"flipped"`
+}
+
+// alleleFrequencyPair is the allele frequency of a tag for a shared variant and the
+// reference one.
+type alleleFrequencyPair struct {
+	af, referenceAF float64
+}
+
+// alleleFrequencyCorrelation is the Pearson correlation of the allele frequencies of
+// a tag with the reference ones, NaN without variation.
+func alleleFrequencyCorrelation(pairs []alleleFrequencyPair) float64 {
+	var meanAF, meanReference float64
+	for _, pair := range pairs {
+		meanAF += pair.af
+		meanReference += pair.referenceAF
+	}
+	meanAF /= float64(len(pairs))
+	meanReference /= float64(len(pairs))
+	var sxx, syy, sxy float64
+	for _, pair := range pairs {
+		dx, dy := pair.referenceAF-meanReference, pair.af-meanAF
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// referencePanel maps variant keys to their reference allele frequency.
+type referencePanel map[string]float64
+
+func (configuration ReferencePanelConfiguration) maxDifference() float64 {
+	if configuration.MaxDifference == 0 {
+		return defaultReferenceMaxDifference
+	}
+	return configuration.MaxDifference
+}
+
+// parseReferencePanel reads the reference table, keyed like the blocks, and counts
+// the rows skipped for their chromosome. Variants without a valid allele frequency
+// are left out.
+func parseReferencePanel(buffer []byte, configuration ReferencePanelConfiguration) (referencePanel, int, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return nil, 0, err
+	}
+	delimiter := cmp.Or(configuration.ReferenceDelimiter, "\t")
+	headerLine, _, headerEnd, _ := splitPreamble(buffer, nil)
+	header := strings.Split(strings.TrimPrefix(strings.TrimSpace(string(headerLine)), "#"), delimiter)
+	find := func(name string, fallback string) (int, error) {
+		name = cmp.Or(name, fallback)
+		for i, column := range header {
+			if strings.TrimSpace(column) == name {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("reference column %q not found in header", name)
+	}
+	var columns [5]int
+	for i, column := range []struct{ name, fallback string }{
+		{configuration.ColumnChromosome, cpraHeader[0]},
+		{configuration.ColumnPosition, cpraHeader[1]},
+		{configuration.ColumnReference, cpraHeader[2]},
+		{configuration.ColumnAlternate, cpraHeader[3]},
+		{configuration.ColumnAlleleFrequency, defaultReferenceAFColumn},
+	} {
+		index, err := find(column.name, column.fallback)
+		if err != nil {
+			return nil, 0, err
+		}
+		columns[i] = index
+	}
+	index := FileColumnsIndex{ColumnChromosome: columns[0], ColumnPosition: columns[1], ColumnReference: columns[2], ColumnAlternate: columns[3]}
+
+	tableReader := csv.NewReader(bytes.NewReader(buffer[headerEnd:]))
+	tableReader.Comma = rune(delimiter[0])
+	tableReader.FieldsPerRecord = -1
+	tableReader.Comment = '#'
+	requiredLen := slices.Max(columns[:]) + 1
+	panel := make(referencePanel)
+	skipped := 0
+	for line := 1; ; line++ {
+		row, err := tableReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("reference line %d: %w", line, err)
+		}
+		if len(row) < requiredLen {
+			return nil, 0, fmt.Errorf("reference line %d: insufficient columns: expected at least %d, got %d", line, requiredLen, len(row))
+		}
+		if _, err := parseChromosome(row[index.ColumnChromosome]); err != nil {
+			skipped++
+			continue
+		}
+		variant, err := parseVariant(row, index)
+		if err != nil {
+			return nil, 0, fmt.Errorf("reference line %d: %w", line, err)
+		}
+		af, err := strconv.ParseFloat(strings.TrimSpace(row[columns[4]]), 64)
+		if err != nil || !(af >= 0 && af <= 1) {
+			continue
+		}
+		panel[variantKey(variant, configuration.Delimiter)] = af
+	}
+	return panel, skipped, nil
+}
+
+// lookup returns the key the panel lists a variant under, that of the variant with
+// swapped alleles when it only has that one.
+func (panel referencePanel) lookup(variant string, delimiter string) (string, bool) {
+	if _, ok := panel[variant]; ok {
+		return variant, true
+	}
+	parsed, err := parseVariantKey(variant, delimiter)
+	if err != nil {
+		return "", false
+	}
+	parsed.Ref, parsed.Alt = parsed.Alt, parsed.Ref
+	key := variantKey(parsed, delimiter)
+	_, ok := panel[key]
+	return key, ok
+}
+
+// compare returns the allele frequency of a tag for a variant and the reference
+// one of its alternate allele, whether the panel swaps its alleles and whether the
+// variant has both.
+func (panel referencePanel) compare(rows []*SummaryRows, tag summaryTag, variant string, delimiter string) (float64, float64, bool, bool) {
+	key, ok := panel.lookup(variant, delimiter)
+	if !ok {
+		return 0, 0, false, false
+	}
+	referenceAF, swapped := panel[key], key != variant
+	if swapped {
+		referenceAF = 1 - referenceAF
+	}
+	af, ok := tag.float(rows, variant, StatisticAlleleFrequency)
+	return af, referenceAF, swapped, ok
+}
+
+// AlleleFrequencyCheck compares the allele frequencies of every tag with a reference
+// panel over the blocks of all partitions, partitions[partition][source] as given to
+// SummaryBytesString per partition.
+func AlleleFrequencyCheck(partitions [][][]byte, reference []byte, configuration ReferencePanelConfiguration) (AlleleFrequencyReport, error) {
+	panel, skipped, err := parseReferencePanel(reference, configuration)
+	if err != nil {
+		return AlleleFrequencyReport{}, err
+	}
+	blockSets, err := summaryBlockSets(partitions)
+	if err != nil {
+		return AlleleFrequencyReport{}, err
+	}
+	maxDifference := configuration.maxDifference()
+
+	report := AlleleFrequencyReport{Tags: []TagAlleleFrequency{}, SkippedReferenceRows: skipped}
+	// tags are reported in the order they are first seen, a partition may lack some
+	index := make(map[string]int)
+	pairs := make(map[string][]alleleFrequencyPair)
+	for _, rows := range blockSets {
+		tags, err := selectTags(rows, configuration.Tags)
+		if err != nil {
+			return AlleleFrequencyReport{}, err
+		}
+		for _, tag := range tags {
+			if _, ok := index[tag.Tag]; !ok {
+				index[tag.Tag] = len(report.Tags)
+				report.Tags = append(report.Tags, TagAlleleFrequency{Tag: tag.Tag, Outliers: []AlleleFrequencyOutlier{}})
+			}
+		}
+		for _, variant := range summaryVariants(rows) {
+			for _, tag := range tags {
+				result := &report.Tags[index[tag.Tag]]
+				af, referenceAF, swapped, ok := panel.compare(rows, tag, variant, configuration.Delimiter)
+				if !ok {
+					continue
+				}
+				result.Shared++
+				pairs[tag.Tag] = append(pairs[tag.Tag], alleleFrequencyPair{af: af, referenceAF: referenceAF})
+				if math.Abs(af-referenceAF) <= maxDifference {
+					if swapped {
+						result.Flipped++
+					}
+					continue
+				}
+				parsed, err := parseVariantKey(variant, configuration.Delimiter)
+				if err != nil {
+					return AlleleFrequencyReport{}, err
+				}
+				outlier := AlleleFrequencyOutlier{
+					Variant:     parsed,
+					AF:          af,
+					ReferenceAF: referenceAF,
+					Flipped:     math.Abs(1-af-referenceAF) <= maxDifference,
+				}
+				if outlier.Flipped {
+					result.Flipped++
+				}
+				result.Outliers = append(result.Outliers, outlier)
+			}
+		}
+	}
+
+	for i := range report.Tags {
+		result := &report.Tags[i]
+		if tagPairs := pairs[result.Tag]; len(tagPairs) >= 3 {
+			result.Correlation = finite(alleleFrequencyCorrelation(tagPairs))
+		}
+		result.SuspectedFlip = 2*result.Flipped > result.Shared
+		slices.SortFunc(result.Outliers, func(a, b AlleleFrequencyOutlier) int { return compareVariants(a.Variant, b.Variant) })
+	}
+	return report, nil
+}
+
+// BufferReferencePanel reads the reference table once and returns, for every
+// partition, a block of the reference allele frequencies of its variants to give to
+// DropAlleleFrequencyOutliers with the blocks of that partition. Variants listed
+// with swapped alleles are kept under the key of the table.
+func BufferReferencePanel(reference []byte, configuration ReferencePanelConfiguration, partitions VariantPartitions) ([][]byte, error) {
+	panel, _, err := parseReferencePanel(reference, configuration)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]byte, len(partitions))
+	for i, partition := range partitions {
+		block := &SummaryRows{Header: []string{StatisticAlleleFrequency}, Rows: make(map[string]*SummaryValues)}
+		for _, variant := range partition {
+			if key, ok := panel.lookup(variant, configuration.Delimiter); ok {
+				block.Rows[key] = &SummaryValues{Values: []string{strconv.FormatFloat(panel[key], 'g', -1, 64)}}
+			}
+		}
+		if result[i], err = proto.Marshal(block); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// unmarshalReferencePanel reads a block of BufferReferencePanel.
+func unmarshalReferencePanel(data []byte) (referencePanel, error) {
+	var block SummaryRows
+	if err := proto.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	panel := make(referencePanel, len(block.Rows))
+	for key, values := range block.Rows {
+		af, err := strconv.ParseFloat(values.GetValues()[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("reference variant %q: %w", key, err)
+		}
+		panel[key] = af
+	}
+	return panel, nil
+}
+
+// DropAlleleFrequencyOutliers replaces the statistics of every tag whose allele
+// frequency is an outlier of the reference panel with NA, in the blocks of one
+// partition as given to SummaryBytesString, so they are left out of the merged
+// output and the analyses made from it. panel is the block of the partition from
+// BufferReferencePanel.
+func DropAlleleFrequencyOutliers(buffer [][]byte, panel []byte, configuration ReferencePanelConfiguration) ([][]byte, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return nil, err
+	}
+	reference, err := unmarshalReferencePanel(panel)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return buffer, nil
+	}
+	tags, err := selectTags(rows, configuration.Tags)
+	if err != nil {
+		return nil, err
+	}
+	maxDifference := configuration.maxDifference()
+	for _, variant := range summaryVariants(rows) {
+		for _, tag := range tags {
+			af, referenceAF, _, ok := reference.compare(rows, tag, variant, configuration.Delimiter)
+			if !ok || math.Abs(af-referenceAF) <= maxDifference {
+				continue
+			}
+			values := rows[tag.Block].Rows[variant].GetValues()
+			for _, column := range tag.Columns {
+				if column < len(values) {
+					values[column] = missingValue
+				}
+			}
+		}
+	}
+
+	result := make([][]byte, len(rows))
+	for i, block := range rows {
+		if result[i], err = proto.Marshal(block); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,269 @@
+package lib
+
+import (
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// testReferencePanel holds the reference allele frequencies of 1:100 to 1:600, 1:600
+// without one.
+const testReferencePanel = "#chromosome\tposition\treference\talternative\taf\n" +
+	"1\t100\tA\tT\t0.1\n" +
+	"1\t200\tA\tT\t0.3\n" +
+	"1\t300\tA\tT\t0.2\n" +
+	"1\t400\tA\tT\t0.5\n" +
+	"1\t500\tA\tT\t0.05\n" +
+	"1\t600\tA\tT\tNA\n"
+
+// testReferencePartitions returns two partitions of the tags a, matching the panel
+// but for 1:500, and b, reporting the frequency of the reference allele.
+func testReferencePartitions(t *testing.T) [][][]byte {
+	t.Helper()
+	block := func(tag string, rows map[string]*SummaryValues) []byte {
+		data, err := proto.Marshal(&SummaryRows{Header: CreateHeader(tag, nil), Rows: rows})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return data
+	}
+	values := func(af string) *SummaryValues { return &SummaryValues{Values: []string{"0.01", "0.1", "0.02", af}} }
+	return [][][]byte{
+		{
+			block("a", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("0.12"),
+				"1\t200\tA\tT": values("0.28"),
+				"1\t300\tA\tT": values("0.2"),
+				"1\t600\tA\tT": values("0.4"),
+			}),
+			block("b", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("0.9"),
+				"1\t200\tA\tT": values("0.7"),
+				"1\t300\tA\tT": values("NA"),
+			}),
+		},
+		{
+			block("a", map[string]*SummaryValues{
+				"1\t400\tA\tT": values("0.45"),
+				"1\t500\tA\tT": values("0.6"),
+			}),
+			block("b", map[string]*SummaryValues{
+				"1\t400\tA\tT": values("0.5"),
+				"1\t500\tA\tT": values("0.95"),
+			}),
+		},
+	}
+}
+
+func TestAlleleFrequencyCheck(t *testing.T) {
+	configuration := ReferencePanelConfiguration{Delimiter: "\t"}
+	report, err := AlleleFrequencyCheck(testReferencePartitions(t), []byte(testReferencePanel), configuration)
+	if err != nil {
+		t.Fatalf("AlleleFrequencyCheck() unexpected error: %v", err)
+	}
+	if len(report.Tags) != 2 {
+		t.Fatalf("AlleleFrequencyCheck() = %+v, want 2 tags", report)
+	}
+
+	a := report.Tags[0]
+	if a.Tag != "a" || a.Shared != 5 || a.Flipped != 0 || a.SuspectedFlip {
+		t.Errorf("tag a = %+v", a)
+	}
+	if len(a.Outliers) != 1 || a.Outliers[0].Variant.Position != 500 || a.Outliers[0].AF != 0.6 ||
+		a.Outliers[0].ReferenceAF != 0.05 || a.Outliers[0].Flipped {
+		t.Errorf("tag a outliers = %+v", a.Outliers)
+	}
+	if a.Correlation == nil || *a.Correlation <= 0 {
+		t.Errorf("tag a correlation = %v, want positive", a.Correlation)
+	}
+
+	b := report.Tags[1]
+	if b.Tag != "b" || b.Shared != 4 || b.Flipped != 3 || !b.SuspectedFlip {
+		t.Errorf("tag b = %+v", b)
+	}
+	positions := []uint64{100, 200, 500}
+	if len(b.Outliers) != len(positions) {
+		t.Fatalf("tag b outliers = %+v", b.Outliers)
+	}
+	for i, position := range positions {
+		if outlier := b.Outliers[i]; outlier.Variant.Position != position || !outlier.Flipped {
+			t.Errorf("tag b outlier %d = %+v, want flipped %d", i, outlier, position)
+		}
+	}
+	if b.Correlation == nil || math.Abs(*b.Correlation+1) > 0.1 {
+		t.Errorf("tag b correlation = %v, want close to -1", b.Correlation)
+	}
+}
+
+func TestAlleleFrequencyCheckSwappedAlleles(t *testing.T) {
+	// The panel lists 1:100 and 1:200 as T>A, 1:300 as A>T
+	reference := "chromosome\tposition\treference\talternative\taf\n" +
+		"1\t100\tT\tA\t0.9\n" +
+		"1\t200\tT\tA\t0.3\n" +
+		"1\t300\tA\tT\t0.2\n"
+	configuration := ReferencePanelConfiguration{Delimiter: "\t", Tags: []string{"a"}}
+	report, err := AlleleFrequencyCheck(testReferencePartitions(t), []byte(reference), configuration)
+	if err != nil {
+		t.Fatalf("AlleleFrequencyCheck() unexpected error: %v", err)
+	}
+	a := report.Tags[0]
+	// The match of 1:100 and the outlier 1:200 are both flips
+	if a.Shared != 3 || a.Flipped != 2 || !a.SuspectedFlip {
+		t.Errorf("tag a = %+v, want 3 shared variants with 2 flips", a)
+	}
+	// 0.28 of 1:200 is far from the 0.7 of T once swapped
+	if len(a.Outliers) != 1 || a.Outliers[0].Variant.Position != 200 ||
+		math.Abs(a.Outliers[0].ReferenceAF-0.7) > 1e-12 || !a.Outliers[0].Flipped {
+		t.Errorf("tag a outliers = %+v, want flipped 1:200 against 0.7", a.Outliers)
+	}
+
+	panels, err := BufferReferencePanel([]byte(reference), configuration, VariantPartitions{{"1\t100\tA\tT", "1\t200\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferReferencePanel() unexpected error: %v", err)
+	}
+	result, err := DropAlleleFrequencyOutliers(testReferencePartitions(t)[0], panels[0], configuration)
+	if err != nil {
+		t.Fatalf("DropAlleleFrequencyOutliers() unexpected error: %v", err)
+	}
+	rows, err := unmarshalSummaryRows(result)
+	if err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if values := rows[0].Rows["1\t100\tA\tT"].GetValues(); values[3] != "0.12" {
+		t.Errorf("swapped 1:100 values = %v, want kept", values)
+	}
+	if values := rows[0].Rows["1\t200\tA\tT"].GetValues(); values[3] != missingValue {
+		t.Errorf("swapped 1:200 values = %v, want dropped", values)
+	}
+}
+
+func TestAlleleFrequencyCheckTagInLaterPartition(t *testing.T) {
+	partitions := testReferencePartitions(t)
+	// c only has blocks in the second partition
+	data, err := proto.Marshal(&SummaryRows{Header: CreateHeader("c", nil), Rows: map[string]*SummaryValues{
+		"1\t400\tA\tT": {Values: []string{"0.01", "0.1", "0.02", "0.5"}},
+		"1\t500\tA\tT": {Values: []string{"0.01", "0.1", "0.02", "0.5"}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	partitions[1] = append(partitions[1], data)
+
+	report, err := AlleleFrequencyCheck(partitions, []byte(testReferencePanel), ReferencePanelConfiguration{Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("AlleleFrequencyCheck() unexpected error: %v", err)
+	}
+	if len(report.Tags) != 3 {
+		t.Fatalf("AlleleFrequencyCheck() = %+v, want 3 tags", report)
+	}
+	if c := report.Tags[2]; c.Tag != "c" || c.Shared != 2 || len(c.Outliers) != 1 || c.Outliers[0].Variant.Position != 500 {
+		t.Errorf("tag c = %+v, want 2 shared variants with the outlier 1:500", c)
+	}
+}
+
+func TestAlleleFrequencyCheckConfiguration(t *testing.T) {
+	reference := "CHR,POS,REF,ALT,AF_nfe\n1,100,A,T,0.1\n1,200,A,T,0.3\n"
+	configuration := ReferencePanelConfiguration{
+		Delimiter:             "\t",
+		ReferenceDelimiter:    ",",
+		ColumnChromosome:      "CHR",
+		ColumnPosition:        "POS",
+		ColumnReference:       "REF",
+		ColumnAlternate:       "ALT",
+		ColumnAlleleFrequency: "AF_nfe",
+		MaxDifference:         0.01,
+		Tags:                  []string{"a"},
+	}
+	report, err := AlleleFrequencyCheck(testReferencePartitions(t), []byte(reference), configuration)
+	if err != nil {
+		t.Fatalf("AlleleFrequencyCheck() unexpected error: %v", err)
+	}
+	if len(report.Tags) != 1 {
+		t.Fatalf("AlleleFrequencyCheck() = %+v, want tag a", report)
+	}
+	if a := report.Tags[0]; a.Shared != 2 || len(a.Outliers) != 2 || a.Correlation != nil {
+		t.Errorf("tag a = %+v, want 2 outliers of 2 shared variants without correlation", a)
+	}
+}
+
+func TestAlleleFrequencyCheckSkippedChromosomes(t *testing.T) {
+	reference := testReferencePanel + "chrUn_KI270302v1\t100\tA\tT\t0.1\nHLA-A*01:01\t200\tA\tT\t0.2\n"
+	report, err := AlleleFrequencyCheck(testReferencePartitions(t), []byte(reference), ReferencePanelConfiguration{Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("AlleleFrequencyCheck() unexpected error: %v", err)
+	}
+	if report.SkippedReferenceRows != 2 || report.Tags[0].Shared != 5 {
+		t.Errorf("AlleleFrequencyCheck() = %+v, want 2 skipped rows and 5 shared variants of a", report)
+	}
+}
+
+func TestAlleleFrequencyCheckErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		reference     string
+		configuration ReferencePanelConfiguration
+	}{
+		{"missing delimiter", testReferencePanel, ReferencePanelConfiguration{}},
+		{"invalid max difference", testReferencePanel, ReferencePanelConfiguration{Delimiter: "\t", MaxDifference: 2}},
+		{"unknown tag", testReferencePanel, ReferencePanelConfiguration{Delimiter: "\t", Tags: []string{"x"}}},
+		{"missing column", "chromosome\tposition\treference\talternative\n1\t100\tA\tT\n", ReferencePanelConfiguration{Delimiter: "\t"}},
+		{"invalid position", "chromosome\tposition\treference\talternative\taf\n1\tabc\tA\tT\t0.1\n", ReferencePanelConfiguration{Delimiter: "\t"}},
+		{"insufficient columns", "chromosome\tposition\treference\talternative\taf\n1\t100\tA\n", ReferencePanelConfiguration{Delimiter: "\t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := AlleleFrequencyCheck(testReferencePartitions(t), []byte(tt.reference), tt.configuration); err == nil {
+				t.Error("AlleleFrequencyCheck() expected error, got none")
+			}
+		})
+	}
+}
+
+func TestDropAlleleFrequencyOutliers(t *testing.T) {
+	configuration := ReferencePanelConfiguration{Delimiter: "\t"}
+	partitions := VariantPartitions{{"1\t100\tA\tT", "1\t200\tA\tT", "1\t300\tA\tT", "1\t600\tA\tT"}, {"1\t400\tA\tT", "1\t500\tA\tT"}}
+	panels, err := BufferReferencePanel([]byte(testReferencePanel), configuration, partitions)
+	if err != nil {
+		t.Fatalf("BufferReferencePanel() unexpected error: %v", err)
+	}
+	var panel SummaryRows
+	if err := proto.Unmarshal(panels[1], &panel); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if len(panels) != 2 || len(panel.Rows) != 2 || panel.Rows["1\t500\tA\tT"].GetValues()[0] != "0.05" {
+		t.Errorf("BufferReferencePanel() second partition = %v, want 1:400 and 1:500", panel.Rows)
+	}
+
+	result, err := DropAlleleFrequencyOutliers(testReferencePartitions(t)[0], panels[0], configuration)
+	if err != nil {
+		t.Fatalf("DropAlleleFrequencyOutliers() unexpected error: %v", err)
+	}
+	rows, err := unmarshalSummaryRows(result)
+	if err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	tests := []struct {
+		block   int
+		variant string
+		dropped bool
+	}{
+		{0, "1\t100\tA\tT", false},
+		{0, "1\t600\tA\tT", false},
+		{1, "1\t100\tA\tT", true},
+		{1, "1\t200\tA\tT", true},
+		{1, "1\t300\tA\tT", false},
+	}
+	for _, tt := range tests {
+		values := rows[tt.block].Rows[tt.variant].GetValues()
+		dropped := true
+		for _, value := range values {
+			if value != missingValue {
+				dropped = false
+			}
+		}
+		if dropped != tt.dropped {
+			t.Errorf("block %d variant %q values = %v, want dropped %v", tt.block, tt.variant, values, tt.dropped)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Multiple testing corrections of the replication threshold.
+const (
+	CorrectionBonferroni = "bonferroni"
+	CorrectionFDR        = "fdr"
+)
+
+// Values of the "<tag>_replicated" columns of the merged output.
+const (
+	ReplicationReplicated    = "replicated"
+	ReplicationNotReplicated = "not_replicated"
+)
+
+// statisticReplicated names the replication column of a replication tag.
+const statisticReplicated = "replicated"
+
+// Replication calls the variants of the Discovery tag replicated in each of the
+// Replication tags, every other tag when empty. A variant is tested in a tag when
+// the discovery beta is non zero with a p-value below DiscoveryThreshold, any
+// p-value when it is zero, and the tag has a p-value and a beta for it. It is
+// replicated when the betas have the same sign and the replication p-value is at
+// most the Bonferroni threshold Alpha/m or, with CorrectionFDR, the
+// Benjamini-Hochberg threshold at false discovery rate Alpha, m being Tests or the
+// number of variants tested in the tag when Tests is zero. Alpha defaults to 0.05.
+// Thresholds, as returned by ReplicationThresholds, replaces the thresholds computed
+// over the blocks at hand, so that blocks written one partition at a time are called
+// against the thresholds of all partitions.
+type Replication struct {
+	Discovery          string   `json:"discovery" validate:"required"`
+	Replication        []string `json:"replication,omitempty" validate:"dive,required"`
+	DiscoveryThreshold float64  `json:"discovery_threshold,omitempty" validate:"gte=0,lte=1"`
+	Correction         string   `json:"correction,omitempty" validate:"omitempty,oneof=bonferroni fdr"`
+	Alpha              float64  `json:"alpha,omitempty" validate:"gte=0,lt=1"`
+	Tests              int      `json:"tests,omitempty" validate:"gte=0"`
+	// Thresholds maps the replication tags to their p-value threshold
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+}
+
+// ReplicationReport holds the replication rate of every replication tag.
+type ReplicationReport struct {
+	Discovery string           `json:"discovery"`
+	Tags      []TagReplication `json:"tags"`
+}
+
+// TagReplication counts the variants tested and replicated in one tag. Threshold
+// is the p-value threshold of the tag, null when no variant can replicate.
+type TagReplication struct {
+	Tag        string   `json:"tag"`
+	Tested     int      `json:"tested"`
+	Replicated int      `json:"replicated"`
+	Rate       *float64 `json:"rate"`
+	Threshold  *float64 `json:"threshold"`
+}
+
+// replicationTags resolves the discovery and replication tags of a block set.
+func (replication *Replication) replicationTags(rows []*SummaryRows) (summaryTag, []summaryTag, error) {
+	if err := validate.Struct(replication); err != nil {
+		return summaryTag{}, nil, err
+	}
+	discovery, err := selectTags(rows, []string{replication.Discovery})
+	if err != nil {
+		return summaryTag{}, nil, fmt.Errorf("discovery %w", err)
+	}
+	if len(replication.Replication) > 0 {
+		tags, err := selectTags(rows, replication.Replication)
+		return discovery[0], tags, err
+	}
+	var tags []summaryTag
+	for _, tag := range summaryTags(rows) {
+		if tag.Tag != replication.Discovery {
+			tags = append(tags, tag)
+		}
+	}
+	return discovery[0], tags, nil
+}
+
+// columns names the replication columns following naming.
+func (replication *Replication) columns(tags []summaryTag, naming *ColumnNaming) []string {
+	columns := make([]string, len(tags))
+	for i, tag := range tags {
+		columns[i] = naming.column(tag.Tag, statisticReplicated)
+	}
+	return columns
+}
+
+// test returns whether a variant is tested in a replication tag, and then the
+// replication p-value and whether the betas have the same sign.
+func (replication *Replication) test(rows []*SummaryRows, discovery summaryTag, tag summaryTag, variant string) (float64, bool, bool) {
+	discoveryBeta, ok := discovery.float(rows, variant, StatisticBeta)
+	if !ok || discoveryBeta == 0 {
+		return 0, false, false
+	}
+	if replication.DiscoveryThreshold > 0 {
+		pval, ok := discovery.float(rows, variant, StatisticPValue)
+		if !ok || pval >= replication.DiscoveryThreshold {
+			return 0, false, false
+		}
+	}
+	pval, ok := tag.float(rows, variant, StatisticPValue)
+	if !ok {
+		return 0, false, false
+	}
+	beta, ok := tag.float(rows, variant, StatisticBeta)
+	if !ok {
+		return 0, false, false
+	}
+	return pval, (beta > 0) == (discoveryBeta > 0) && beta != 0, true
+}
+
+// thresholds computes the p-value threshold of every replication tag over the
+// variants of all block sets, -1 when no variant can replicate. Given Thresholds are
+// returned as they are.
+func (replication *Replication) thresholds(blockSets [][]*SummaryRows) (map[string]float64, error) {
+	if replication.Thresholds != nil {
+		return replication.Thresholds, nil
+	}
+	pvalues := make(map[string][]float64)
+	for _, rows := range blockSets {
+		discovery, tags, err := replication.replicationTags(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range summaryVariants(rows) {
+			for _, tag := range tags {
+				if pval, _, ok := replication.test(rows, discovery, tag, variant); ok {
+					pvalues[tag.Tag] = append(pvalues[tag.Tag], pval)
+				}
+			}
+		}
+	}
+	alpha := replication.Alpha
+	if alpha == 0 {
+		alpha = 0.05
+	}
+	result := make(map[string]float64, len(pvalues))
+	for tag, tested := range pvalues {
+		m := float64(len(tested))
+		if replication.Tests > 0 {
+			m = float64(replication.Tests)
+		}
+		if replication.Correction != CorrectionFDR {
+			result[tag] = alpha / m
+			continue
+		}
+		slices.Sort(tested)
+		result[tag] = -1
+		for k := len(tested); k > 0; k-- {
+			if tested[k-1] <= float64(k)*alpha/m {
+				result[tag] = tested[k-1]
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// replicationThresholds computes the thresholds of options.Replication, nil without it.
+func (options SummaryOptions) replicationThresholds(blockSets [][]*SummaryRows) (map[string]float64, error) {
+	if options.Replication == nil {
+		return nil, nil
+	}
+	return options.Replication.thresholds(blockSets)
+}
+
+// partitionThresholds checks that the thresholds of options.Replication do not depend
+// on the variants of the partition being written: they are given, or Bonferroni
+// thresholds over a fixed number of tests.
+func (options SummaryOptions) partitionThresholds() error {
+	replication := options.Replication
+	if replication == nil || replication.Thresholds != nil || replication.Tests > 0 && replication.Correction != CorrectionFDR {
+		return nil
+	}
+	return fmt.Errorf("replication over one partition needs the thresholds of ReplicationThresholds or a Bonferroni number of tests")
+}
+
+// replicationStatus calls a variant in a replication tag, the missing value marker
+// when it is not tested.
+func (replication *Replication) replicationStatus(rows []*SummaryRows, discovery summaryTag, tag summaryTag, variant string, thresholds map[string]float64, marker string) string {
+	pval, sameSign, ok := replication.test(rows, discovery, tag, variant)
+	if !ok {
+		return marker
+	}
+	if sameSign && pval <= thresholds[tag.Tag] {
+		return ReplicationReplicated
+	}
+	return ReplicationNotReplicated
+}
+
+// summaryBlockSets unmarshals the blocks of every partition, skipping empty ones.
+func summaryBlockSets(partitions [][][]byte) ([][]*SummaryRows, error) {
+	var blockSets [][]*SummaryRows
+	for i, buffer := range partitions {
+		rows, err := unmarshalSummaryRows(buffer)
+		if err != nil {
+			return nil, fmt.Errorf("partition %d: %w", i, err)
+		}
+		if len(rows) > 0 {
+			blockSets = append(blockSets, rows)
+		}
+	}
+	return blockSets, nil
+}
+
+// ReplicationThresholds computes the p-value threshold of every replication tag over
+// the blocks of all partitions, partitions[partition][source] as given to
+// SummaryBytesString per partition, to be set as Replication.Thresholds.
+func ReplicationThresholds(partitions [][][]byte, replication Replication) (map[string]float64, error) {
+	blockSets, err := summaryBlockSets(partitions)
+	if err != nil {
+		return nil, err
+	}
+	return replication.thresholds(blockSets)
+}
+
+// ReplicationSummary counts the variants tested and replicated in every replication
+// tag over the blocks of all partitions, partitions[partition][source] as given to
+// SummaryBytesString per partition.
+func ReplicationSummary(partitions [][][]byte, replication Replication) (ReplicationReport, error) {
+	blockSets, err := summaryBlockSets(partitions)
+	if err != nil {
+		return ReplicationReport{}, err
+	}
+	if len(blockSets) == 0 {
+		return ReplicationReport{}, fmt.Errorf("no blocks")
+	}
+	thresholds, err := replication.thresholds(blockSets)
+	if err != nil {
+		return ReplicationReport{}, err
+	}
+
+	report := ReplicationReport{Discovery: replication.Discovery}
+	// tags are reported in the order they are first seen, a partition may lack some
+	index := make(map[string]int)
+	for _, rows := range blockSets {
+		discovery, tags, err := replication.replicationTags(rows)
+		if err != nil {
+			return ReplicationReport{}, err
+		}
+		for _, tag := range tags {
+			if _, ok := index[tag.Tag]; !ok {
+				index[tag.Tag] = len(report.Tags)
+				report.Tags = append(report.Tags, TagReplication{Tag: tag.Tag})
+			}
+		}
+		for _, variant := range summaryVariants(rows) {
+			for _, tag := range tags {
+				counts := &report.Tags[index[tag.Tag]]
+				switch replication.replicationStatus(rows, discovery, tag, variant, thresholds, missingValue) {
+				case ReplicationReplicated:
+					counts.Replicated++
+					counts.Tested++
+				case ReplicationNotReplicated:
+					counts.Tested++
+				}
+			}
+		}
+	}
+	for i := range report.Tags {
+		tag := &report.Tags[i]
+		if tag.Tested > 0 {
+			tag.Rate = finite(float64(tag.Replicated) / float64(tag.Tested))
+		}
+		if threshold, ok := thresholds[tag.Tag]; ok && threshold >= 0 {
+			tag.Threshold = finite(threshold)
+		}
+	}
+	return report, nil
+}
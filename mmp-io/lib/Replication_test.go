@@ -0,0 +1,256 @@
+package lib
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// testReplicationPartitions returns two partitions of the discovery tag d and the
+// replication tags r1 and r2. 1:200 has opposite signs in d and r1, 1:300 is not a
+// discovery at 1e-5 and 1:400 has no discovery beta.
+func testReplicationPartitions(t *testing.T) [][][]byte {
+	t.Helper()
+	block := func(tag string, rows map[string]*SummaryValues) []byte {
+		data, err := proto.Marshal(&SummaryRows{Header: CreateHeader(tag, nil), Rows: rows})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return data
+	}
+	values := func(values ...string) *SummaryValues { return &SummaryValues{Values: values} }
+	return [][][]byte{
+		{
+			block("d", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("1e-8", "0.2", "0.03", "0.1"),
+				"1\t200\tA\tT": values("1e-6", "-0.1", "0.02", "0.1"),
+				"1\t300\tA\tT": values("0.2", "0.1", "0.08", "0.1"),
+				"1\t400\tA\tT": values("1e-7", "NA", "0.02", "0.1"),
+			}),
+			block("r1", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("0.001", "0.1", "0.03", "0.1"),
+				"1\t200\tA\tT": values("1e-5", "0.05", "0.01", "0.1"),
+				"1\t300\tA\tT": values("0.01", "0.1", "0.04", "0.1"),
+				"1\t400\tA\tT": values("1e-9", "0.1", "0.01", "0.1"),
+			}),
+			block("r2", map[string]*SummaryValues{
+				"1\t100\tA\tT": values("0.03", "0.1", "0.05", "0.1"),
+				"1\t200\tA\tT": values("0.5", "NA", "0.05", "0.1"),
+			}),
+		},
+		{
+			block("d", map[string]*SummaryValues{
+				"1\t500\tA\tT": values("1e-10", "0.3", "0.04", "0.1"),
+			}),
+			block("r1", map[string]*SummaryValues{
+				"1\t500\tA\tT": values("0.02", "0.2", "0.09", "0.1"),
+			}),
+			block("r2", map[string]*SummaryValues{
+				"1\t500\tA\tT": values("0.02", "0.2", "0.09", "0.1"),
+			}),
+		},
+	}
+}
+
+func TestReplicationSummary(t *testing.T) {
+	tests := []struct {
+		name        string
+		replication Replication
+		// tested, replicated and threshold per tag
+		expected []TagReplication
+	}{
+		{
+			name:        "bonferroni",
+			replication: Replication{Discovery: "d"},
+			expected: []TagReplication{
+				{Tag: "r1", Tested: 4, Replicated: 2, Threshold: finite(0.0125)},
+				{Tag: "r2", Tested: 2, Replicated: 1, Threshold: finite(0.025)},
+			},
+		},
+		{
+			name:        "fdr",
+			replication: Replication{Discovery: "d", Replication: []string{"r1"}, Correction: CorrectionFDR},
+			expected:    []TagReplication{{Tag: "r1", Tested: 4, Replicated: 3, Threshold: finite(0.02)}},
+		},
+		{
+			name:        "fdr without discoveries",
+			replication: Replication{Discovery: "d", Replication: []string{"r2"}, Correction: CorrectionFDR, Alpha: 0.01},
+			expected:    []TagReplication{{Tag: "r2", Tested: 2}},
+		},
+		{
+			name:        "discovery threshold",
+			replication: Replication{Discovery: "d", Replication: []string{"r1"}, DiscoveryThreshold: 1e-5},
+			expected:    []TagReplication{{Tag: "r1", Tested: 3, Replicated: 1, Threshold: finite(0.05 / 3)}},
+		},
+		{
+			name:        "number of tests",
+			replication: Replication{Discovery: "d", Replication: []string{"r1"}, Tests: 20},
+			expected:    []TagReplication{{Tag: "r1", Tested: 4, Replicated: 1, Threshold: finite(0.0025)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ReplicationSummary(testReplicationPartitions(t), tt.replication)
+			if err != nil {
+				t.Fatalf("ReplicationSummary() unexpected error: %v", err)
+			}
+			if report.Discovery != "d" || len(report.Tags) != len(tt.expected) {
+				t.Fatalf("ReplicationSummary() = %+v, want %d tags", report, len(tt.expected))
+			}
+			for i, expected := range tt.expected {
+				got := report.Tags[i]
+				if got.Tag != expected.Tag || got.Tested != expected.Tested || got.Replicated != expected.Replicated {
+					t.Errorf("tag %d = %+v, want %+v", i, got, expected)
+				}
+				if (got.Threshold == nil) != (expected.Threshold == nil) ||
+					got.Threshold != nil && math.Abs(*got.Threshold-*expected.Threshold) > 1e-15 {
+					t.Errorf("tag %s threshold = %v, want %v", got.Tag, got.Threshold, expected.Threshold)
+				}
+				if got.Rate == nil || *got.Rate != float64(expected.Replicated)/float64(expected.Tested) {
+					t.Errorf("tag %s rate = %v", got.Tag, got.Rate)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicationSummaryTagInLaterPartition(t *testing.T) {
+	partitions := testReplicationPartitions(t)
+	// r3 only has blocks in the second partition
+	data, err := proto.Marshal(&SummaryRows{Header: CreateHeader("r3", nil), Rows: map[string]*SummaryValues{
+		"1\t500\tA\tT": {Values: []string{"0.001", "0.2", "0.09", "0.1"}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	partitions[1] = append(partitions[1], data)
+
+	report, err := ReplicationSummary(partitions, Replication{Discovery: "d"})
+	if err != nil {
+		t.Fatalf("ReplicationSummary() unexpected error: %v", err)
+	}
+	expected := []TagReplication{{Tag: "r1", Tested: 4}, {Tag: "r2", Tested: 2}, {Tag: "r3", Tested: 1, Replicated: 1}}
+	if len(report.Tags) != len(expected) {
+		t.Fatalf("ReplicationSummary() = %+v, want %d tags", report, len(expected))
+	}
+	for i, expected := range expected {
+		if got := report.Tags[i]; got.Tag != expected.Tag || got.Tested != expected.Tested {
+			t.Errorf("tag %d = %+v, want %+v", i, got, expected)
+		}
+	}
+	if r3 := report.Tags[2]; r3.Replicated != 1 || r3.Threshold == nil || *r3.Threshold != 0.05 {
+		t.Errorf("tag r3 = %+v, want replicated at threshold 0.05", r3)
+	}
+}
+
+func TestReplicationSummaryErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		replication Replication
+	}{
+		{"missing discovery", Replication{}},
+		{"unknown discovery", Replication{Discovery: "x"}},
+		{"unknown replication", Replication{Discovery: "d", Replication: []string{"x"}}},
+		{"unknown correction", Replication{Discovery: "d", Correction: "holm"}},
+		{"invalid alpha", Replication{Discovery: "d", Alpha: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReplicationSummary(testReplicationPartitions(t), tt.replication); err == nil {
+				t.Error("ReplicationSummary() expected error, got none")
+			}
+		})
+	}
+}
+
+func TestReplicationColumns(t *testing.T) {
+	partitions := testReplicationPartitions(t)
+	options := SummaryOptions{Delimiter: "\t", Replication: &Replication{Discovery: "d", Replication: []string{"r1"}}}
+	header, err := HeaderBytesStringWithOptions(partitions[0], options)
+	if err != nil {
+		t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(header, "\tr2_af\tr1_replicated") {
+		t.Errorf("header = %q, want r1_replicated last", header)
+	}
+
+	// One partition alone would set the threshold to 0.05/3, not to the 0.0125 of all
+	if _, err := SummaryBytesStringWithOptions(partitions[0], options); err == nil {
+		t.Error("SummaryBytesStringWithOptions() expected error without thresholds, got none")
+	}
+	thresholds, err := ReplicationThresholds(partitions, *options.Replication)
+	if err != nil || thresholds["r1"] != 0.0125 {
+		t.Fatalf("ReplicationThresholds() = %v, %v, want r1 at 0.0125", thresholds, err)
+	}
+	options.Replication.Thresholds = thresholds
+	lines, err := SummaryBytesStringWithOptions(partitions[0], options)
+	if err != nil {
+		t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+	}
+	expected := []string{ReplicationReplicated, ReplicationNotReplicated, ReplicationReplicated, missingValue}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if got := fields[len(fields)-1]; got != expected[i] {
+			t.Errorf("line %d replication = %q, want %q", i, got, expected[i])
+		}
+	}
+
+	// Across the passes the four tested variants set it to 0.0125
+	passes := make([][][]byte, 3)
+	for _, partition := range partitions {
+		for source, block := range partition {
+			passes[source] = append(passes[source], block)
+		}
+	}
+	options.Replication.Replication = nil
+	options.Replication.Thresholds = nil
+	lines, err = SummaryPassesStringWithOptions(passes, options)
+	if err != nil {
+		t.Fatalf("SummaryPassesStringWithOptions() unexpected error: %v", err)
+	}
+	expectedPairs := []string{
+		"replicated\tnot_replicated",
+		"not_replicated\tNA",
+		"replicated\tNA",
+		"NA\tNA",
+		"not_replicated\treplicated",
+	}
+	if len(lines) != len(expectedPairs) {
+		t.Fatalf("SummaryPassesStringWithOptions() returned %d lines, want %d", len(lines), len(expectedPairs))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, "\t"+expectedPairs[i]) {
+			t.Errorf("line %d = %q, want suffix %q", i, line, expectedPairs[i])
+		}
+	}
+}
+
+func TestReplicationColumnsFixedTests(t *testing.T) {
+	partitions := testReplicationPartitions(t)
+	// 0.05/20 does not depend on the partition; 1:100 (p=0.001) replicates in r1
+	options := SummaryOptions{Delimiter: "\t", Replication: &Replication{Discovery: "d", Replication: []string{"r1"}, Tests: 20}}
+	lines, err := SummaryBytesStringWithOptions(partitions[0], options)
+	if err != nil {
+		t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(lines[0], "\t"+ReplicationReplicated) || !strings.HasSuffix(lines[2], "\t"+ReplicationNotReplicated) {
+		t.Errorf("lines = %q, want 1:100 replicated and 1:300 not", lines)
+	}
+	options.Replication.Correction = CorrectionFDR
+	if _, err := SummaryBytesStringWithOptions(partitions[0], options); err == nil {
+		t.Error("SummaryBytesStringWithOptions() expected error for FDR over one partition, got none")
+	}
+}
+
+func TestMergedColumnsIndexSkipsReplication(t *testing.T) {
+	header := []byte("chromosome\tposition\treference\talternative\td_pval\td_beta\td_sebeta\td_af\tr1_pval\tr1_beta\tr1_sebeta\tr1_af\tr1_replicated\n")
+	index, err := CreateMergedColumnsIndex(header, MergedConfiguration{PvalThreshold: 1, Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(index) != 2 || index[1].Tag != "r1" {
+		t.Errorf("CreateMergedColumnsIndex() = %+v, want blocks for d and r1", index)
+	}
+}
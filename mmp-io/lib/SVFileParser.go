@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -30,6 +32,100 @@ func parseChromosome(s string) (uint32, error) {
 	}
 }
 
+// metadataPrefix marks "##key=value" metadata lines, which are never data.
+const metadataPrefix = "##"
+
+func isCommentLine(line []byte, commentPrefixes []string) bool {
+	if bytes.HasPrefix(line, []byte(metadataPrefix)) {
+		return true
+	}
+	for _, prefix := range commentPrefixes {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMetadataLine adds one "##key=value" line to the file metadata.
+func parseMetadataLine(line string, metadata *FileMetadata) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, metadataPrefix))
+	if line == "" {
+		return
+	}
+	key, value, _ := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if metadata.Fields == nil {
+		metadata.Fields = make(map[string][]string)
+	}
+	metadata.Fields[key] = append(metadata.Fields[key], value)
+
+	switch strings.ToLower(key) {
+	case "genome_build", "genome_assembly", "genomebuild", "build", "assembly":
+		metadata.GenomeBuild = value
+	case "sample_size", "samplesize", "n", "n_samples", "total_samples":
+		if n, err := parseUint64(value); err == nil {
+			metadata.SampleSize = n
+		}
+	}
+}
+
+// splitPreamble skips the metadata and comment lines at the start of buffer and
+// returns the first remaining line with its start and end (past the newline) offsets.
+func splitPreamble(buffer []byte, commentPrefixes []string) ([]byte, int, int, FileMetadata) {
+	var metadata FileMetadata
+	offset := 0
+	for offset < len(buffer) {
+		end := len(buffer)
+		if i := bytes.IndexByte(buffer[offset:], '\n'); i >= 0 {
+			end = offset + i + 1
+		}
+		line := bytes.TrimRight(buffer[offset:end], "\r\n")
+		if bytes.HasPrefix(line, []byte(metadataPrefix)) {
+			parseMetadataLine(string(line), &metadata)
+		} else if !isCommentLine(line, commentPrefixes) && len(bytes.TrimSpace(line)) > 0 {
+			return line, offset, end, metadata
+		}
+		offset = end
+	}
+	return nil, offset, offset, metadata
+}
+
+// stripCommentLines drops metadata and comment lines from buffer.
+// The buffer is returned unchanged when it has none.
+func stripCommentLines(buffer []byte, commentPrefixes []string) []byte {
+	var result []byte
+	offset := 0
+	for offset < len(buffer) {
+		end := len(buffer)
+		if i := bytes.IndexByte(buffer[offset:], '\n'); i >= 0 {
+			end = offset + i + 1
+		}
+		if isCommentLine(buffer[offset:end], commentPrefixes) {
+			if result == nil {
+				result = make([]byte, 0, len(buffer))
+				result = append(result, buffer[:offset]...)
+			}
+		} else if result != nil {
+			result = append(result, buffer[offset:end]...)
+		}
+		offset = end
+	}
+	if result == nil {
+		return buffer
+	}
+	return result
+}
+
+// newTableReader returns a csv reader over the data lines of buffer.
+func newTableReader(buffer []byte, metadata BlockMetadata) *csv.Reader {
+	dataReader := bytes.NewReader(stripCommentLines(buffer, metadata.CommentPrefixes))
+	tableReader := csv.NewReader(dataReader)
+	tableReader.Comma = rune(metadata.Delimiter[0])
+	return tableReader
+}
+
 func parseUint32(s string) (uint32, error) {
 	v, err := strconv.ParseUint(s, 10, 32)
 	return uint32(v), err
@@ -78,14 +174,140 @@ func parseAssociationStatistic(buffer []string, indexHeader FileColumnsIndex) (*
 	return assoc, nil
 }
 
-func serializeAssociationStatistic(assocStat *AssociationStatistic) []string {
-	// Pre-allocate slice with exact capacity to avoid reallocation
-	result := make([]string, 4)
-	result[0] = fmt.Sprintf("%e", assocStat.PValue)
-	result[1] = fmt.Sprintf("%f", assocStat.Beta)
-	result[2] = fmt.Sprintf("%f", assocStat.Sebeta)
-	result[3] = fmt.Sprintf("%f", assocStat.Af)
-	return result
+func isMissingValue(value string, metadata BlockMetadata) bool {
+	return slices.Contains(metadata.MissingValues, value)
+}
+
+// parseSummaryValues parses the statistics of a row and returns them as read, to be
+// formatted by the writers. Statistics holding one of the metadata missing values are
+// reported as NA and the p-value and standard error are corrected by the genomic
+// control lambda.
+func parseSummaryValues(row []string, metadata BlockMetadata, lambda float64) ([]string, error) {
+	index := metadata.FileColumnsIndex
+	columns := []int{index.ColumnPValue, index.ColumnBeta, index.ColumnSEBeta, index.ColumnAlleleFrequency}
+	var missing []int
+	fields := row
+	for i, column := range columns {
+		if isMissingValue(row[column], metadata) {
+			if missing == nil {
+				fields = slices.Clone(row)
+			}
+			fields[column] = "0"
+			missing = append(missing, i)
+		}
+	}
+	assoc, err := parseAssociationStatistic(fields, index)
+	if err != nil {
+		return nil, err
+	}
+	statistics := make([]string, len(columns))
+	for i, column := range columns {
+		statistics[i] = row[column]
+	}
+	if correctAssociationStatistic(assoc, lambda) {
+		statistics[0] = blockStatistic(assoc.PValue)
+		statistics[2] = blockStatistic(assoc.Sebeta)
+	}
+	for _, i := range missing {
+		statistics[i] = missingValue
+	}
+	if hasSampleSize(metadata) {
+		n, err := parseSampleSize(row, metadata)
+		if err != nil {
+			return nil, err
+		}
+		statistics = append(statistics, n)
+	}
+	return statistics, nil
+}
+
+// hasSampleSize reports whether the blocks of the file carry a sample size column.
+func hasSampleSize(metadata BlockMetadata) bool {
+	return metadata.ColumnSampleSize != nil || metadata.SampleSize > 0 || metadata.VCFSampleSize
+}
+
+// parseSampleSize returns the sample size of a row, the column when there is one
+// and the file constant otherwise.
+func parseSampleSize(row []string, metadata BlockMetadata) (string, error) {
+	if metadata.ColumnSampleSize == nil {
+		return strconv.FormatUint(metadata.SampleSize, 10), nil
+	}
+	value := row[*metadata.ColumnSampleSize]
+	if isMissingValue(value, metadata) {
+		return missingValue, nil
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid sample size %q", value)
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+// blockHeader is the header of the blocks of a tag, with the sample size last when
+// the file has one.
+func blockHeader(tag string, metadata BlockMetadata) []string {
+	header := CreateHeader(tag, nil)
+	if hasSampleSize(metadata) {
+		header = append(header, tag+"_"+StatisticSampleSize)
+	}
+	return header
+}
+
+// blockStatistic writes a derived statistic to a block in its shortest representation,
+// so that the writers can format it without loss.
+func blockStatistic(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// formatted reports whether the format changes how values are written, rather than
+// only whether the values of the blocks are preserved.
+func (format NumberFormat) formatted() bool {
+	return format.SignificantDigits > 0 || format.Shortest || format.ScientificThreshold > 0
+}
+
+// formatStatistic writes one value following the format. Statistics are float32,
+// so the shortest representation is that of the 32 bit value.
+func (format NumberFormat) formatStatistic(v float32) string {
+	precision := -1
+	if format.SignificantDigits > 0 {
+		precision = format.SignificantDigits
+	}
+	value := float64(v)
+	if format.ScientificThreshold == 0 {
+		return strconv.FormatFloat(value, 'g', precision, 32)
+	}
+	if value != 0 && math.Abs(value) < format.ScientificThreshold {
+		if precision > 0 {
+			precision--
+		}
+		return strconv.FormatFloat(value, 'e', precision, 32)
+	}
+	if precision > 0 {
+		value, _ = strconv.ParseFloat(strconv.FormatFloat(value, 'e', precision-1, 32), 64)
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 32)
+}
+
+// formatValue writes a value of a block following the format. Only the standard
+// statistics are formatted; missing values, other statistics such as the sample size
+// and values that do not parse are written as stored.
+func (format NumberFormat) formatValue(statistic string, value string) string {
+	if format.PreserveOriginal || value == missingValue || !slices.Contains(mergedStatistics, statistic) {
+		return value
+	}
+	v, err := parseFloat32(value)
+	if err != nil {
+		return value
+	}
+	switch {
+	case format.formatted():
+		return format.formatStatistic(v)
+	case statistic == StatisticPValue:
+		return fmt.Sprintf("%e", v)
+	default:
+		return fmt.Sprintf("%f", v)
+	}
 }
 
 func parseVariant(buffer []string, indexHeader FileColumnsIndex) (*Variant, error) {
@@ -148,13 +370,20 @@ func marshalSummaryRows(result []SummaryRows) ([][]byte, error) {
 	return blockBytes, nil
 }
 
-func CreateHeader(tag string) []string {
-	return []string{
-		fmt.Sprintf("%s_pval", tag),
-		fmt.Sprintf("%s_beta", tag),
-		fmt.Sprintf("%s_sebeta", tag),
-		fmt.Sprintf("%s_af", tag),
+// subTag names the tag of one trait of a file holding several traits.
+func subTag(tag string, name string) string {
+	return fmt.Sprintf("%s_%s", tag, name)
+}
+
+// CreateHeader names the statistic columns of a tag following naming, nil naming
+// gives "<tag>_<statistic>". Blocks are always created with the default names and
+// carry their naming alongside, applied when the header is written out.
+func CreateHeader(tag string, naming *ColumnNaming) []string {
+	header := make([]string, len(mergedStatistics))
+	for i, statistic := range mergedStatistics {
+		header[i] = naming.column(tag, statistic)
 	}
+	return header
 }
 
 // CreateEmptyBlock creates an empty SummaryRows block with the same header as the reference block
@@ -170,6 +399,7 @@ func CreateEmptyBlock(referenceBlock []byte) ([]byte, error) {
 	emptyBlock := &SummaryRows{
 		Header: reference.Header,
 		Rows:   make(map[string]*SummaryValues),
+		Naming: reference.Naming,
 	}
 
 	// Marshal and return
@@ -181,23 +411,38 @@ func CreateEmptyBlock(referenceBlock []byte) ([]byte, error) {
 	return result, nil
 }
 
+// BufferSummaryPasses collects the statistics of the partitioned variants into one
+// SummaryRows block per partition. For files with a phenotype column the block holds
+// the columns of every phenotype in metadata.Phenotypes one after the other, as the
+// blocks of multi-sample VCF files do, with NA for the phenotypes a variant lacks.
 func BufferSummaryPasses(buffer []byte, metadata BlockMetadata, partitions VariantPartitions) ([][]byte, error) {
-	dataReader := bytes.NewReader(buffer)
-	tableReader := csv.NewReader(dataReader)
-	tableReader.Comma = rune(metadata.Delimiter[0])
-	result := make([]SummaryRows, len(partitions))
+	if metadata.FileFormat == FileFormatGWASVCF {
+		return bufferVCFSummaryPasses(buffer, metadata, partitions)
+	}
+	tableReader := newTableReader(buffer, metadata)
+
+	tags, err := metadataTags(metadata)
+	if err != nil {
+		return nil, err
+	}
+	phenotypes := phenotypeSet(metadata)
+	var header []string
+	for _, tag := range tags {
+		header = append(header, blockHeader(tag, metadata)...)
+	}
+	tagColumns := len(header) / len(tags)
 
+	result := make([]SummaryRows, len(partitions))
 	variantSet := make(map[string]int)
 	for i, group := range partitions {
-		for _, v := range group {
-			variantSet[v] = i
-		}
 		result[i].Rows = make(map[string]*SummaryValues)
-		result[i].Header = CreateHeader(metadata.Tag)
+		result[i].Header = header
+		result[i].Naming = metadata.ColumnNaming
 
 		// Pre-populate all variants in this partition with nil values
 		// This ensures they exist in the map even if not found in the buffer
 		for _, variant := range group {
+			variantSet[variant] = i
 			result[i].Rows[variant] = nil
 		}
 	}
@@ -205,7 +450,8 @@ func BufferSummaryPasses(buffer []byte, metadata BlockMetadata, partitions Varia
 	requiredLen := max(metadata.FileColumnsIndex.ColumnChromosome, metadata.FileColumnsIndex.ColumnPosition,
 		metadata.FileColumnsIndex.ColumnReference, metadata.FileColumnsIndex.ColumnAlternate,
 		metadata.FileColumnsIndex.ColumnBeta, metadata.FileColumnsIndex.ColumnSEBeta,
-		metadata.FileColumnsIndex.ColumnPValue, metadata.FileColumnsIndex.ColumnAlleleFrequency) + 1
+		metadata.FileColumnsIndex.ColumnPValue, metadata.FileColumnsIndex.ColumnAlleleFrequency,
+		phenotypeColumn(metadata), sampleSizeColumn(metadata)) + 1
 	firstRow := true
 
 	for {
@@ -236,12 +482,34 @@ func BufferSummaryPasses(buffer []byte, metadata BlockMetadata, partitions Varia
 		key := variantKey(parsedVariant, metadata.Delimiter)
 
 		if index, ok := variantSet[key]; ok {
-			assoc, err := parseAssociationStatistic(row, metadata.FileColumnsIndex)
+			phenotype := 0
+			if phenotypes != nil {
+				if phenotype, ok = phenotypes[row[*metadata.ColumnPhenotype]]; !ok {
+					continue
+				}
+			}
+			statistics, err := parseSummaryValues(row, metadata, metadata.GenomicControl[tags[phenotype]])
 			if err != nil {
 				return nil, err
 			}
-			statistics := serializeAssociationStatistic(assoc)
-			result[index].Rows[key] = &SummaryValues{Values: statistics}
+			// A row without any statistic, as merged output writes variants not in a
+			// source, is left out; one missing only some keeps them as NA
+			if !slices.ContainsFunc(statistics[:len(mergedStatistics)], func(value string) bool { return value != missingValue }) {
+				continue
+			}
+			if len(tags) == 1 {
+				result[index].Rows[key] = &SummaryValues{Values: statistics}
+				continue
+			}
+			values := result[index].Rows[key]
+			if values == nil {
+				values = &SummaryValues{Values: make([]string, len(header))}
+				for i := range values.Values {
+					values.Values[i] = absentValue
+				}
+				result[index].Rows[key] = values
+			}
+			copy(values.Values[phenotype*tagColumns:], statistics)
 		}
 	}
 	marshaledRows, err := marshalSummaryRows(result)
@@ -252,17 +520,92 @@ func BufferSummaryPasses(buffer []byte, metadata BlockMetadata, partitions Varia
 	return marshaledRows, nil
 }
 
+// phenotypeColumn returns the phenotype column index, -1 for single phenotype files.
+func phenotypeColumn(metadata BlockMetadata) int {
+	if metadata.ColumnPhenotype == nil {
+		return -1
+	}
+	return *metadata.ColumnPhenotype
+}
+
+// sampleSizeColumn returns the sample size column index, -1 when there is none.
+func sampleSizeColumn(metadata BlockMetadata) int {
+	if metadata.ColumnSampleSize == nil {
+		return -1
+	}
+	return *metadata.ColumnSampleSize
+}
+
+// phenotypeSet maps the selected phenotypes to their position in metadata.Phenotypes.
+// It is nil when every row is selected.
+func phenotypeSet(metadata BlockMetadata) map[string]int {
+	if metadata.ColumnPhenotype == nil || len(metadata.Phenotypes) == 0 {
+		return nil
+	}
+	result := make(map[string]int, len(metadata.Phenotypes))
+	for i, phenotype := range metadata.Phenotypes {
+		result[phenotype] = i
+	}
+	return result
+}
+
+// BufferPhenotypes returns the sorted distinct values of the phenotype column.
+// Callers merge the values of all buffers into metadata.Phenotypes before
+// BufferSummaryPasses when the configuration does not list the phenotypes.
+func BufferPhenotypes(buffer []byte, metadata BlockMetadata) ([]string, error) {
+	if metadata.ColumnPhenotype == nil {
+		return nil, fmt.Errorf("metadata has no phenotype column")
+	}
+	tableReader := newTableReader(buffer, metadata)
+	phenotypes := phenotypeSet(metadata)
+	column := *metadata.ColumnPhenotype
+
+	seen := make(map[string]bool)
+	for {
+		row, err := tableReader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, csv.ErrFieldCount) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(row) <= column {
+			return nil, fmt.Errorf("insufficient columns: expected at least %d, got %d", column+1, len(row))
+		}
+		if _, ok := phenotypes[row[column]]; ok || phenotypes == nil {
+			seen[row[column]] = true
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for phenotype := range seen {
+		result = append(result, phenotype)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
 // VariantsBytesWithIndex
 func BufferVariants(buffer []byte, metadata BlockMetadata) ([]string, error) {
-	dataReader := bytes.NewReader(buffer)
-	tableReader := csv.NewReader(dataReader)
-	tableReader.Comma = rune(metadata.Delimiter[0])
+	if metadata.FileFormat == FileFormatGWASVCF {
+		return bufferVCFVariants(buffer, metadata)
+	}
+	tableReader := newTableReader(buffer, metadata)
 
 	var result []string
+	// Variants are reported once even when several phenotypes pass the threshold
+	var seen map[string]bool
+	phenotypes := phenotypeSet(metadata)
+	if metadata.ColumnPhenotype != nil {
+		seen = make(map[string]bool)
+	}
 	// Note: ColumnAlleleFrequency not included as it's not used in this function
 	requiredLen := max(metadata.FileColumnsIndex.ColumnChromosome, metadata.FileColumnsIndex.ColumnPosition,
 		metadata.FileColumnsIndex.ColumnReference, metadata.FileColumnsIndex.ColumnAlternate,
-		metadata.FileColumnsIndex.ColumnPValue) + 1
+		metadata.FileColumnsIndex.ColumnPValue, phenotypeColumn(metadata)) + 1
 	firstRow := true
 
 	for {
@@ -284,10 +627,27 @@ func BufferVariants(buffer []byte, metadata BlockMetadata) ([]string, error) {
 			firstRow = false
 		}
 
+		if phenotypes != nil {
+			if _, ok := phenotypes[row[*metadata.ColumnPhenotype]]; !ok {
+				continue
+			}
+		}
+
+		if isMissingValue(row[metadata.ColumnPValue], metadata) {
+			continue
+		}
+
 		pvalue, err := parsePValue(row, metadata.FileColumnsIndex)
 		if err != nil {
 			return nil, err
 		}
+		if len(metadata.GenomicControl) > 0 {
+			tag := metadata.Tag
+			if metadata.ColumnPhenotype != nil {
+				tag = subTag(metadata.Tag, row[*metadata.ColumnPhenotype])
+			}
+			pvalue = float32(correctPValue(float64(pvalue), metadata.GenomicControl[tag]))
+		}
 
 		// Only add variant if pvalue is less than threshold
 		if pvalue < metadata.PvalThreshold {
@@ -296,13 +656,27 @@ func BufferVariants(buffer []byte, metadata BlockMetadata) ([]string, error) {
 				return nil, err
 			}
 			key := variantKey(parsedVariant, metadata.Delimiter)
+			if seen != nil {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
 			result = append(result, key)
 		}
 	}
 	return result, nil
 }
 
+// cpraHeader names the variant columns written before the statistics.
+var cpraHeader = []string{"chromosome", "position", "reference", "alternative"}
+
 func HeaderBytesString(buffer [][]byte, delimiter string, cpra bool) (string, error) {
+	return HeaderBytesStringWithOptions(buffer, SummaryOptions{Delimiter: delimiter, CPRA: cpra})
+}
+
+// HeaderBytesStringWithOptions returns the header matching SummaryBytesStringWithOptions.
+func HeaderBytesStringWithOptions(buffer [][]byte, options SummaryOptions) (string, error) {
 	rows, err := unmarshalSummaryRows(buffer)
 	if err != nil {
 		return "", err
@@ -314,86 +688,173 @@ func HeaderBytesString(buffer [][]byte, delimiter string, cpra bool) (string, er
 	result := make([]string, 0)
 
 	// Add CPRA columns if requested
-	if cpra {
-		result = append(result, "chromosome", "position", "reference", "alternative")
+	if options.CPRA {
+		result = append(result, cpraHeader...)
 	}
 
 	// Add headers from all blocks
 	for i := range rows {
-		result = append(result, rows[i].Header...)
+		naming := rows[i].Naming
+		if options.Naming != nil {
+			naming = options.Naming
+		}
+		for _, span := range blockTagSpans(rows[i].Header) {
+			for _, column := range rows[i].Header[span.start:span.end] {
+				result = append(result, naming.outputColumn(column))
+			}
+			if options.StatusColumn {
+				result = append(result, statusColumn(span.tag, naming))
+			}
+		}
+	}
+	if options.Meta != nil {
+		if _, err := options.Meta.metaTags(rows); err != nil {
+			return "", err
+		}
+		result = append(result, options.Meta.columns(options.Naming)...)
+	}
+	if options.Direction != nil {
+		if _, err := options.Direction.directionTags(rows); err != nil {
+			return "", err
+		}
+		result = append(result, options.Naming.column(metaTag, statisticDirection))
+	}
+	if options.Replication != nil {
+		_, tags, err := options.Replication.replicationTags(rows)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, options.Replication.columns(tags, options.Naming)...)
 	}
 
-	return strings.Join(result, delimiter), nil
+	return strings.Join(result, options.Delimiter), nil
 }
 
-func SummaryBytesString(buffer [][]byte, delimiter string, cpra bool) ([]string, error) {
-	rows, err := unmarshalSummaryRows(buffer)
-	if err != nil {
-		return nil, err
+// summaryLines joins the values of every variant of the blocks, in genomic order,
+// and returns the lines with their parsed variants. thresholds are the replication
+// thresholds of options.Replication.
+func summaryLines(rows []*SummaryRows, options SummaryOptions, thresholds map[string]float64) ([]string, error) {
+	totalValues := 0
+	spans := make([][]tagSpan, len(rows))
+	for i := range rows {
+		totalValues += len(rows[i].Header)
+		spans[i] = blockTagSpans(rows[i].Header)
 	}
-
-	if len(rows) == 0 {
-		return []string{}, nil
+	markers := options.markers()
+	var metaTags []summaryTag
+	if options.Meta != nil {
+		tags, err := options.Meta.metaTags(rows)
+		if err != nil {
+			return nil, err
+		}
+		metaTags = tags
+	}
+	var directionTags []summaryTag
+	if options.Direction != nil {
+		tags, err := options.Direction.directionTags(rows)
+		if err != nil {
+			return nil, err
+		}
+		directionTags = tags
+	}
+	var discovery summaryTag
+	var replicationTags []summaryTag
+	if options.Replication != nil {
+		tag, tags, err := options.Replication.replicationTags(rows)
+		if err != nil {
+			return nil, err
+		}
+		discovery, replicationTags = tag, tags
 	}
 
-	rowLen := make([]int, len(rows))
-
-	variantSet := make(map[string]bool)
-	for i := range rows {
-		rowLen[i] = len(rows[i].Header)
+	keys := summaryVariants(rows)
+	if _, err := sortVariantKeys(keys, options.Delimiter); err != nil {
+		return nil, err
+	}
 
-		for variant := range rows[i].Rows {
-			variantSet[variant] = true
+	result := make([]string, len(keys))
+	for i, variant := range keys {
+		values := make([]string, 0, totalValues)
+		if options.CPRA {
+			cpraFields := variantCPRA(variant, options.Delimiter)
+			values = append(values, cpraFields...)
 		}
+		for j := range rows {
+			values = appendBlockValues(values, rows[j], spans[j], variant, markers, options.StatusColumn, options.NumberFormat)
+		}
+		if options.Meta != nil {
+			values = options.Meta.appendMetaValues(values, rows, metaTags, variant, markers.missingValue)
+		}
+		if options.Direction != nil {
+			values = append(values, options.Direction.directionString(rows, directionTags, variant))
+		}
+		for _, tag := range replicationTags {
+			values = append(values, options.Replication.replicationStatus(rows, discovery, tag, variant, thresholds, markers.missingValue))
+		}
+		result[i] = strings.Join(values, options.Delimiter)
 	}
+	return result, nil
+}
 
-	for i := range rows {
-		for variant := range rows[i].Rows {
-			variantSet[variant] = true
-		}
+// SummaryBytesString joins the blocks of every source for one partition into rows,
+// one per variant, sorted by chromosome, position, reference and alternate allele.
+func SummaryBytesString(buffer [][]byte, delimiter string, cpra bool) ([]string, error) {
+	return SummaryBytesStringWithOptions(buffer, SummaryOptions{Delimiter: delimiter, CPRA: cpra})
+}
+
+// SummaryBytesStringWithOptions is SummaryBytesString with the output configured by options.
+func SummaryBytesStringWithOptions(buffer [][]byte, options SummaryOptions) ([]string, error) {
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert set to slice
-	variants := make([]string, 0, len(variantSet))
-	for variant := range variantSet {
-		variants = append(variants, variant)
+	if len(rows) == 0 {
+		return []string{}, nil
 	}
 
-	totalValues := 0
-	for j := range rows {
-		totalValues += rowLen[j]
+	if err := options.partitionThresholds(); err != nil {
+		return nil, err
+	}
+	thresholds, err := options.replicationThresholds([][]*SummaryRows{rows})
+	if err != nil {
+		return nil, err
 	}
+	return summaryLines(rows, options, thresholds)
+}
 
-	result := make([]string, len(variants))
+// SummaryPassesString is SummaryBytesString over every block of every source,
+// passes[source][block] as accumulated by the pipeline. The blocks of each source are
+// merged by variant like MergeSummaryBlocks, so a variant read from several chunks or
+// partitions of a file is written once, and the rows are sorted in genomic order.
+func SummaryPassesString(passes [][][]byte, delimiter string, cpra bool) ([]string, error) {
+	return SummaryPassesStringWithOptions(passes, SummaryOptions{Delimiter: delimiter, CPRA: cpra})
+}
 
-	for i, variant := range variants {
-		values := make([]string, 0, totalValues)
-		if cpra {
-			cpraFields := variantCPRA(variant, delimiter)
-			values = append(values, cpraFields...)
+// SummaryPassesStringWithOptions is SummaryPassesString with the output configured by options.
+func SummaryPassesStringWithOptions(passes [][][]byte, options SummaryOptions) ([]string, error) {
+	var rows []*SummaryRows
+	for i, pass := range passes {
+		if len(pass) == 0 {
+			continue
 		}
-		for j := range rows {
-			if summaryValues, ok := rows[j].Rows[variant]; ok {
-				if summaryValues != nil {
-					values = append(values, summaryValues.Values...)
-					if len(summaryValues.Values) < rowLen[j] {
-						for k := len(summaryValues.Values); k < rowLen[j]; k++ {
-							values = append(values, "NA")
-						}
-					}
-				} else {
-					for k := 0; k < rowLen[j]; k++ {
-						values = append(values, "NA")
-					}
-				}
-			} else {
-				for k := 0; k < rowLen[j]; k++ {
-					values = append(values, "NA")
-				}
-			}
+		blocks, err := unmarshalSummaryRows(pass)
+		if err != nil {
+			return nil, fmt.Errorf("pass %d: %w", i, err)
 		}
-		result[i] = strings.Join(values, delimiter)
+		merged, err := mergeSummaryRows(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("pass %d: %w", i, err)
+		}
+		rows = append(rows, merged)
+	}
+	if len(rows) == 0 {
+		return []string{}, nil
 	}
 
-	return result, nil
+	thresholds, err := options.replicationThresholds([][]*SummaryRows{rows})
+	if err != nil {
+		return nil, err
+	}
+	return summaryLines(rows, options, thresholds)
 }
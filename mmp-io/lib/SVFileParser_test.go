@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"maps"
+	"slices"
 	"strings"
 	"testing"
 
@@ -351,88 +353,178 @@ func TestParseAssociationStatistic(t *testing.T) {
 	}
 }
 
-func TestSerializeAssociationStatistic(t *testing.T) {
+func TestNumberFormatFormatValue(t *testing.T) {
+	values := []string{"3.2e-12", "0.00000012", "0.0456789", "0.50"}
 	tests := []struct {
 		name     string
-		assoc    *AssociationStatistic
+		format   NumberFormat
 		expected []string
 	}{
-		{
-			name: "standard values",
-			assoc: &AssociationStatistic{
-				PValue: 0.001,
-				Beta:   0.5,
-				Sebeta: 0.1,
-				Af:     0.3,
-			},
-			expected: []string{"1.000000e-03", "0.500000", "0.100000", "0.300000"},
-		},
-		{
-			name: "scientific notation pvalue",
-			assoc: &AssociationStatistic{
-				PValue: 1.5e-8,
-				Beta:   0.25,
-				Sebeta: 0.05,
-				Af:     0.45,
-			},
-			expected: []string{"1.500000e-08", "0.250000", "0.050000", "0.450000"},
-		},
-		{
-			name: "negative beta",
-			assoc: &AssociationStatistic{
-				PValue: 0.005,
-				Beta:   -0.3,
-				Sebeta: 0.08,
-				Af:     0.2,
-			},
-			expected: []string{"5.000000e-03", "-0.300000", "0.080000", "0.200000"},
-		},
-		{
-			name: "zero values",
-			assoc: &AssociationStatistic{
-				PValue: 0,
-				Beta:   0,
-				Sebeta: 0,
-				Af:     0,
-			},
-			expected: []string{"0.000000e+00", "0.000000", "0.000000", "0.000000"},
-		},
-		{
-			name: "large pvalue",
-			assoc: &AssociationStatistic{
-				PValue: 0.99999,
-				Beta:   1.5,
-				Sebeta: 0.5,
-				Af:     0.75,
-			},
-			expected: []string{"9.999900e-01", "1.500000", "0.500000", "0.750000"},
-		},
-		{
-			name: "very small pvalue",
-			assoc: &AssociationStatistic{
-				PValue: 1e-30,
-				Beta:   0.1,
-				Sebeta: 0.01,
-				Af:     0.05,
-			},
-			expected: []string{"1.000000e-30", "0.100000", "0.010000", "0.050000"},
+		{"zero value keeps legacy format", NumberFormat{}, []string{"3.200000e-12", "0.000000", "0.045679", "0.500000"}},
+		{"preserve original", NumberFormat{PreserveOriginal: true}, values},
+		{"shortest", NumberFormat{Shortest: true}, []string{"3.2e-12", "1.2e-07", "0.0456789", "0.5"}},
+		{"significant digits", NumberFormat{SignificantDigits: 2}, []string{"3.2e-12", "1.2e-07", "0.046", "0.5"}},
+		{"scientific threshold", NumberFormat{Shortest: true, ScientificThreshold: 1e-3}, []string{"3.2e-12", "1.2e-07", "0.0456789", "0.5"}},
+		{"scientific threshold above values", NumberFormat{Shortest: true, ScientificThreshold: 0.1}, []string{"3.2e-12", "1.2e-07", "4.56789e-02", "0.5"}},
+		{"significant digits with threshold", NumberFormat{SignificantDigits: 3, ScientificThreshold: 1e-4}, []string{"3.20e-12", "1.20e-07", "0.0457", "0.5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := make([]string, len(values))
+			for i, statistic := range mergedStatistics {
+				result[i] = tt.format.formatValue(statistic, values[i])
+			}
+			if strings.Join(result, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("formatValue() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+	if got := (NumberFormat{Shortest: true}).formatStatistic(0); got != "0" {
+		t.Errorf("formatStatistic(0) = %q, want \"0\"", got)
+	}
+	// Missing values and statistics other than the standard ones are written as stored
+	for _, tt := range []struct{ statistic, value string }{{StatisticPValue, "NA"}, {StatisticSampleSize, "1200"}} {
+		if got := (NumberFormat{}).formatValue(tt.statistic, tt.value); got != tt.value {
+			t.Errorf("formatValue(%q, %q) = %q, want it unchanged", tt.statistic, tt.value, got)
+		}
+	}
+}
+
+func TestSummaryBytesStringNumberFormat(t *testing.T) {
+	buffer := []byte("1\t100\tA\tT\t1.50E-08\t0.00000012\t0.0300\tNA\n")
+	metadata := BlockMetadata{
+		Tag:           "study",
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+		MissingValues: []string{"NA"},
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome: 0, ColumnPosition: 1, ColumnReference: 2, ColumnAlternate: 3,
+			ColumnPValue: 4, ColumnBeta: 5, ColumnSEBeta: 6, ColumnAlleleFrequency: 7,
 		},
 	}
+	// Blocks keep the values as read, the writers format them
+	blocks, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{{"1\t100\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var rows SummaryRows
+	if err := proto.Unmarshal(blocks[0], &rows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if got := strings.Join(rows.Rows["1\t100\tA\tT"].GetValues(), " "); got != "1.50E-08 0.00000012 0.0300 NA" {
+		t.Errorf("block values = %q, want the values of the file", got)
+	}
 
+	tests := []struct {
+		name     string
+		format   NumberFormat
+		expected string
+	}{
+		{"legacy", NumberFormat{}, "1.500000e-08\t0.000000\t0.030000\tNA"},
+		{"shortest", NumberFormat{Shortest: true}, "1.5e-08\t1.2e-07\t0.03\tNA"},
+		{"preserve original", NumberFormat{PreserveOriginal: true}, "1.50E-08\t0.00000012\t0.0300\tNA"},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := serializeAssociationStatistic(tt.assoc)
-			if len(result) != 4 {
-				t.Errorf("serializeAssociationStatistic() returned %d values, want 4", len(result))
-				return
+			result, err := SummaryBytesStringWithOptions(blocks, SummaryOptions{Delimiter: "\t", NumberFormat: tt.format})
+			if err != nil {
+				t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+			}
+			if len(result) != 1 || result[0] != tt.expected {
+				t.Errorf("SummaryBytesStringWithOptions() = %q, want %q", result, tt.expected)
 			}
-			for i := 0; i < 4; i++ {
-				if result[i] != tt.expected[i] {
-					t.Errorf("serializeAssociationStatistic()[%d] = %q, want %q", i, result[i], tt.expected[i])
+		})
+	}
+}
+
+func TestBufferSummaryPassesSampleSize(t *testing.T) {
+	buffer := []byte("1\t100\tA\tT\t0.001\t0.5\t0.1\t0.3\t1200.0\n2\t200\tG\tC\t0.01\t0.2\t0.05\t0.4\tNA\n")
+	column := 8
+	metadata := BlockMetadata{
+		Tag:           "study",
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+		MissingValues: []string{"NA"},
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome: 0, ColumnPosition: 1, ColumnReference: 2, ColumnAlternate: 3,
+			ColumnPValue: 4, ColumnBeta: 5, ColumnSEBeta: 6, ColumnAlleleFrequency: 7,
+		},
+	}
+	tests := []struct {
+		name       string
+		column     *int
+		sampleSize uint64
+		expected   map[string]string
+	}{
+		{"column", &column, 0, map[string]string{"1\t100\tA\tT": "1200", "2\t200\tG\tC": "NA"}},
+		{"file constant", nil, 5000, map[string]string{"1\t100\tA\tT": "5000", "2\t200\tG\tC": "5000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata.ColumnSampleSize, metadata.SampleSize = tt.column, tt.sampleSize
+			blocks, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{{"1\t100\tA\tT", "2\t200\tG\tC"}})
+			if err != nil {
+				t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+			}
+			var rows SummaryRows
+			if err := proto.Unmarshal(blocks[0], &rows); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if want := append(CreateHeader("study", nil), "study_n"); !slices.Equal(rows.Header, want) {
+				t.Errorf("block header = %q, want %q", rows.Header, want)
+			}
+			for variant, n := range tt.expected {
+				if values := rows.Rows[variant].GetValues(); len(values) != 5 || values[4] != n {
+					t.Errorf("variant %q values = %q, want sample size %s", variant, values, n)
 				}
 			}
 		})
 	}
+
+	metadata.ColumnSampleSize, metadata.SampleSize = &column, 0
+	if _, err := BufferSummaryPasses([]byte("1\t100\tA\tT\t0.001\t0.5\t0.1\t0.3\tmany\n"), metadata, VariantPartitions{{"1\t100\tA\tT"}}); err == nil {
+		t.Error("BufferSummaryPasses() expected error for invalid sample size, got none")
+	}
+}
+
+func TestBufferSummaryPassesColumnNaming(t *testing.T) {
+	metadata := BlockMetadata{
+		Tag:           "study",
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome: 0, ColumnPosition: 1, ColumnReference: 2, ColumnAlternate: 3,
+			ColumnPValue: 4, ColumnBeta: 5, ColumnSEBeta: 6, ColumnAlleleFrequency: 7,
+		},
+		ColumnNaming: &ColumnNaming{Separator: ".", Statistics: map[string]string{"pval": "P"}},
+	}
+	blocks, err := BufferSummaryPasses([]byte("1\t100\tA\tT\t0.001\t0.5\t0.1\t0.3\n"), metadata, VariantPartitions{{"1\t100\tA\tT"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var rows SummaryRows
+	if err := proto.Unmarshal(blocks[0], &rows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if want := CreateHeader("study", nil); !slices.Equal(rows.Header, want) {
+		t.Errorf("block header = %q, want %q", rows.Header, want)
+	}
+
+	// The naming travels with the block, including the blocks padding a pass
+	empty, err := CreateEmptyBlock(blocks[0])
+	if err != nil {
+		t.Fatalf("CreateEmptyBlock() unexpected error: %v", err)
+	}
+	want := "chromosome,position,reference,alternative,study.P,study.beta,study.sebeta,study.af"
+	for _, block := range [][]byte{blocks[0], empty} {
+		header, err := HeaderBytesString([][]byte{block}, ",", true)
+		if err != nil {
+			t.Fatalf("HeaderBytesString() unexpected error: %v", err)
+		}
+		if header != want {
+			t.Errorf("HeaderBytesString() = %q, want %q", header, want)
+		}
+	}
 }
 
 func TestMarshalSummaryRows(t *testing.T) {
@@ -1282,9 +1374,12 @@ func TestBufferSummaryPasses(t *testing.T) {
 				if len(summaryRows.Header) != 4 {
 					t.Errorf("expected 4 header columns, got %d", len(summaryRows.Header))
 				}
-				// Only 1 variant should be in the result (the missing one is not an error)
-				if len(summaryRows.Rows) != 1 {
-					t.Errorf("expected 1 variant, got %d", len(summaryRows.Rows))
+				// The missing variant is not an error, it is kept without values as not in file
+				if len(summaryRows.Rows) != 2 {
+					t.Errorf("expected 2 variants, got %d", len(summaryRows.Rows))
+				}
+				if values := summaryRows.Rows["2\t67890\tG\tC"]; values == nil || len(values.Values) != 0 {
+					t.Errorf("expected variant not in file without values, got %v", values)
 				}
 			},
 		},
@@ -1410,8 +1505,9 @@ func TestBufferSummaryPasses(t *testing.T) {
 						t.Errorf("partition 1 header[%d] = %q, want %q", i, summaryRows2.Header[i], h)
 					}
 				}
-				if len(summaryRows2.Rows) != 0 {
-					t.Errorf("partition 1: expected 0 variants, got %d", len(summaryRows2.Rows))
+				// The requested variant is kept without values as not in file
+				if len(summaryRows2.Rows) != 1 || len(summaryRows2.Rows["3\t99999\tT\tA"].GetValues()) != 0 {
+					t.Errorf("partition 1: expected 1 variant without values, got %v", summaryRows2.Rows)
 				}
 			},
 		},
@@ -1697,8 +1793,8 @@ func TestSummaryBytesString(t *testing.T) {
 			}(),
 			delimiter: "\t",
 			expected: map[string]string{
-				"1\t100\tA\tT": "0.001\t0.5\t0.1\t0.3\tNA\tNA\tNA\tNA",
-				"1\t200\tG\tC": "NA\tNA\tNA\tNA\t0.002\t0.6\t0.2\t0.4",
+				"1\t100\tA\tT": "1.000000e-03\t0.500000\t0.100000\t0.300000\tNA\tNA\tNA\tNA",
+				"1\t200\tG\tC": "NA\tNA\tNA\tNA\t2.000000e-03\t0.600000\t0.200000\t0.400000",
 			},
 			wantErr: false,
 		},
@@ -1725,8 +1821,8 @@ func TestSummaryBytesString(t *testing.T) {
 			}(),
 			delimiter: "\t",
 			expected: map[string]string{
-				"1\t100\tA\tT": "0.001\t0.5\t0.1\t0.3\t0.003\t0.7\t0.3\t0.5",
-				"1\t200\tG\tC": "0.002\t0.6\t0.2\t0.4\t0.004\t0.8\t0.4\t0.6",
+				"1\t100\tA\tT": "1.000000e-03\t0.500000\t0.100000\t0.300000\t3.000000e-03\t0.700000\t0.300000\t0.500000",
+				"1\t200\tG\tC": "2.000000e-03\t0.600000\t0.200000\t0.400000\t4.000000e-03\t0.800000\t0.400000\t0.600000",
 			},
 			wantErr: false,
 		},
@@ -1744,7 +1840,7 @@ func TestSummaryBytesString(t *testing.T) {
 			}(),
 			delimiter: ",",
 			expected: map[string]string{
-				"1,12345,A,T": "0.001,0.5,0.1,0.3",
+				"1,12345,A,T": "1.000000e-03,0.500000,0.100000,0.300000",
 			},
 			wantErr: false,
 		},
@@ -1841,6 +1937,7 @@ func TestCreateHeader(t *testing.T) {
 	tests := []struct {
 		name     string
 		tag      string
+		naming   *ColumnNaming
 		expected []string
 	}{
 		{
@@ -1858,11 +1955,29 @@ func TestCreateHeader(t *testing.T) {
 			tag:      "study-v2.1",
 			expected: []string{"study-v2.1_pval", "study-v2.1_beta", "study-v2.1_sebeta", "study-v2.1_af"},
 		},
+		{
+			name:     "statistic first",
+			tag:      "finngen",
+			naming:   &ColumnNaming{StatisticFirst: true},
+			expected: []string{"pval_finngen", "beta_finngen", "sebeta_finngen", "af_finngen"},
+		},
+		{
+			name:     "separator and statistic names",
+			tag:      "finngen",
+			naming:   &ColumnNaming{Separator: ".", Statistics: map[string]string{"pval": "P", "sebeta": "SE"}},
+			expected: []string{"finngen.P", "finngen.beta", "finngen.SE", "finngen.af"},
+		},
+		{
+			name:     "prefix and suffix",
+			tag:      "finngen",
+			naming:   &ColumnNaming{Prefix: "gwas_", Suffix: "_r12"},
+			expected: []string{"gwas_finngen_pval_r12", "gwas_finngen_beta_r12", "gwas_finngen_sebeta_r12", "gwas_finngen_af_r12"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := CreateHeader(tt.tag)
+			result := CreateHeader(tt.tag, tt.naming)
 			if len(result) != len(tt.expected) {
 				t.Fatalf("CreateHeader() length = %d, want %d", len(result), len(tt.expected))
 			}
@@ -1967,7 +2082,7 @@ func TestHeaderBytesString(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			buffer := tt.setup()
-			result, err := HeaderBytesString(buffer, tt.delimiter)
+			result, err := HeaderBytesString(buffer, tt.delimiter, false)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("HeaderBytesString() expected error, got none")
@@ -1987,6 +2102,139 @@ func TestHeaderBytesString(t *testing.T) {
 	}
 }
 
+func TestSummaryBytesStringOrder(t *testing.T) {
+	rows := &SummaryRows{
+		Header: []string{"s_pval"},
+		Rows: map[string]*SummaryValues{
+			"10\t5\tA\tT":  {Values: []string{"0.1"}},
+			"2\t300\tG\tC": {Values: []string{"0.2"}},
+			"2\t300\tA\tT": {Values: []string{"0.3"}},
+			"X\t1\tC\tG":   {Values: []string{"0.4"}},
+			"2\t40\tC\tG":  {Values: []string{"0.5"}},
+			"1\t999\tT\tA": {Values: []string{"0.6"}},
+		},
+	}
+	data, err := proto.Marshal(rows)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	want := []string{
+		"1\t999\tT\tA\t6.000000e-01",
+		"2\t40\tC\tG\t5.000000e-01",
+		"2\t300\tA\tT\t3.000000e-01",
+		"2\t300\tG\tC\t2.000000e-01",
+		"10\t5\tA\tT\t1.000000e-01",
+		"X\t1\tC\tG\t4.000000e-01",
+	}
+	for run := 0; run < 5; run++ {
+		result, err := SummaryBytesString([][]byte{data}, "\t", true)
+		if err != nil {
+			t.Fatalf("SummaryBytesString() unexpected error: %v", err)
+		}
+		if strings.Join(result, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("SummaryBytesString() = %q, want %q", result, want)
+		}
+	}
+}
+
+func TestSummaryPassesString(t *testing.T) {
+	block := func(t *testing.T, tag string, rows map[string]*SummaryValues) []byte {
+		data, err := proto.Marshal(&SummaryRows{Header: []string{tag + "_pval"}, Rows: rows})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return data
+	}
+	// Partitions are not ranges: block 0 and block 1 interleave in genomic order
+	passes := [][][]byte{
+		{
+			block(t, "a", map[string]*SummaryValues{"1\t100\tA\tT": {Values: []string{"0.1"}}, "3\t5\tA\tT": {Values: []string{"0.3"}}}),
+			block(t, "a", map[string]*SummaryValues{"2\t7\tG\tC": {Values: []string{"0.2"}}, "1\t50\tC\tG": nil}),
+		},
+		{
+			block(t, "b", map[string]*SummaryValues{"3\t5\tA\tT": {Values: []string{"0.03"}}}),
+		},
+		{},
+	}
+	result, err := SummaryPassesString(passes, "\t", true)
+	if err != nil {
+		t.Fatalf("SummaryPassesString() unexpected error: %v", err)
+	}
+	want := []string{
+		"1\t50\tC\tG\tNA\tNA",
+		"1\t100\tA\tT\t1.000000e-01\tNA",
+		"2\t7\tG\tC\t2.000000e-01\tNA",
+		"3\t5\tA\tT\t3.000000e-01\t3.000000e-02",
+	}
+	if strings.Join(result, "\n") != strings.Join(want, "\n") {
+		t.Errorf("SummaryPassesString() = %q, want %q", result, want)
+	}
+
+	if result, err := SummaryPassesString(nil, "\t", true); err != nil || len(result) != 0 {
+		t.Errorf("SummaryPassesString(nil) = %q, %v, want no rows", result, err)
+	}
+	if _, err := SummaryPassesString([][][]byte{{[]byte("invalid")}}, "\t", true); err == nil {
+		t.Errorf("SummaryPassesString() expected error for invalid block, got none")
+	}
+}
+
+// chunkTestMetadata reads "chrom pos ref alt pval beta sebeta af" rows tagged tag.
+func chunkTestMetadata(tag string) BlockMetadata {
+	return BlockMetadata{
+		Tag: tag,
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome: 0, ColumnPosition: 1, ColumnReference: 2, ColumnAlternate: 3,
+			ColumnPValue: 4, ColumnBeta: 5, ColumnSEBeta: 6, ColumnAlleleFrequency: 7,
+		},
+		PvalThreshold: 0.05,
+		Delimiter:     "\t",
+	}
+}
+
+// chunkTestPasses reads each source chunk by chunk with every partition, as the pipeline does.
+func chunkTestPasses(t *testing.T, partitions VariantPartitions, sources map[string][]string) [][][]byte {
+	t.Helper()
+	var passes [][][]byte
+	for _, tag := range slices.Sorted(maps.Keys(sources)) {
+		var pass [][]byte
+		for _, chunk := range sources[tag] {
+			blocks, err := BufferSummaryPasses([]byte(chunk), chunkTestMetadata(tag), partitions)
+			if err != nil {
+				t.Fatalf("BufferSummaryPasses(%s) unexpected error: %v", tag, err)
+			}
+			pass = append(pass, blocks...)
+		}
+		passes = append(passes, pass)
+	}
+	return passes
+}
+
+func TestSummaryPassesStringChunks(t *testing.T) {
+	partitions := VariantPartitions{{"1\t100\tA\tT", "2\t7\tG\tC"}, {"1\t50\tC\tG"}}
+	passes := chunkTestPasses(t, partitions, map[string][]string{
+		// Every chunk holds a block per partition with each variant of it, found or not
+		"a": {"1\t50\tC\tG\t0.1\t0.1\t0.1\t0.1\n", "1\t100\tA\tT\t0.2\t0.2\t0.2\t0.2\n", "2\t7\tG\tC\t0.3\t0.3\t0.3\t0.3\n"},
+		"b": {"1\t100\tA\tT\t0.4\t0.4\t0.4\t0.4\n"},
+	})
+	result, err := SummaryPassesString(passes, "\t", true)
+	if err != nil {
+		t.Fatalf("SummaryPassesString() unexpected error: %v", err)
+	}
+	want := []string{
+		"1\t50\tC\tG\t1.000000e-01\t0.100000\t0.100000\t0.100000\tNA\tNA\tNA\tNA",
+		"1\t100\tA\tT\t2.000000e-01\t0.200000\t0.200000\t0.200000\t4.000000e-01\t0.400000\t0.400000\t0.400000",
+		"2\t7\tG\tC\t3.000000e-01\t0.300000\t0.300000\t0.300000\tNA\tNA\tNA\tNA",
+	}
+	if strings.Join(result, "\n") != strings.Join(want, "\n") {
+		t.Errorf("SummaryPassesString() = %q, want one row per variant %q", result, want)
+	}
+
+	mismatched := [][][]byte{{passes[0][0], passes[1][0]}}
+	if _, err := SummaryPassesString(mismatched, "\t", true); err == nil {
+		t.Error("SummaryPassesString() expected error for blocks of one source with different headers, got none")
+	}
+}
+
 func TestSummaryBytesStringWithMissingValues(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -2024,7 +2272,7 @@ func TestSummaryBytesStringWithMissingValues(t *testing.T) {
 				// Find the result for variant "1\t200\tG\tC"
 				var foundMissingVariant bool
 				for _, line := range result {
-					if strings.Contains(line, "0.002\t0.6\t0.2\t0.4\tNA\tNA\tNA\tNA") {
+					if strings.Contains(line, "2.000000e-03\t0.600000\t0.200000\t0.400000\tNA\tNA\tNA\tNA") {
 						foundMissingVariant = true
 						break
 					}
@@ -2064,7 +2312,7 @@ func TestSummaryBytesStringWithMissingValues(t *testing.T) {
 				// Find the result for variant "1\t200\tG\tC"
 				var foundMissingVariant bool
 				for _, line := range result {
-					if strings.Contains(line, "NA\tNA\tNA\tNA\t0.004\t0.8\t0.4\t0.6") {
+					if strings.Contains(line, "NA\tNA\tNA\tNA\t4.000000e-03\t0.800000\t0.400000\t0.600000") {
 						foundMissingVariant = true
 						break
 					}
@@ -2106,7 +2354,7 @@ func TestSummaryBytesStringWithMissingValues(t *testing.T) {
 				if len(result) != 1 {
 					t.Fatalf("expected 1 variant, got %d", len(result))
 				}
-				expected := "0.001\t0.5\tNA\tNA\t0.003\t0.7"
+				expected := "1.000000e-03\t0.500000\tNA\tNA\t3.000000e-03\t0.700000"
 				if result[0] != expected {
 					t.Errorf("result = %q, want %q", result[0], expected)
 				}
@@ -2321,7 +2569,7 @@ func TestSummaryBytesStringWithCPRA(t *testing.T) {
 					t.Fatalf("expected 1 result, got %d", len(result))
 				}
 				// CPRA + s1 values + s2 values
-				expected := "1\t100\tA\tT\t0.001\t0.5\t0.003\t0.7"
+				expected := "1\t100\tA\tT\t1.000000e-03\t0.500000\t3.000000e-03\t0.700000"
 				if result[0] != expected {
 					t.Errorf("result = %q, want %q", result[0], expected)
 				}
@@ -2354,7 +2602,7 @@ func TestSummaryBytesStringWithCPRA(t *testing.T) {
 					t.Fatalf("expected 1 result, got %d", len(result))
 				}
 				// CPRA + s1 values + NA for s2
-				expected := "1\t100\tA\tT\t0.001\t0.5\tNA\tNA"
+				expected := "1\t100\tA\tT\t1.000000e-03\t0.500000\tNA\tNA"
 				if result[0] != expected {
 					t.Errorf("result = %q, want %q", result[0], expected)
 				}
@@ -2379,7 +2627,7 @@ func TestSummaryBytesStringWithCPRA(t *testing.T) {
 				if len(result) != 1 {
 					t.Fatalf("expected 1 result, got %d", len(result))
 				}
-				expected := "1,12345,A,T,0.001,0.5"
+				expected := "1,12345,A,T,1.000000e-03,0.500000"
 				if result[0] != expected {
 					t.Errorf("result = %q, want %q", result[0], expected)
 				}
@@ -2596,3 +2844,235 @@ func TestSummaryBytesStringTotalValues(t *testing.T) {
 		})
 	}
 }
+
+func TestBufferVariantsSkipsComments(t *testing.T) {
+	metadata := BlockMetadata{
+		Tag: "test",
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome:      0,
+			ColumnPosition:        1,
+			ColumnReference:       2,
+			ColumnAlternate:       3,
+			ColumnPValue:          4,
+			ColumnBeta:            5,
+			ColumnSEBeta:          6,
+			ColumnAlleleFrequency: 7,
+		},
+		PvalThreshold:   0.05,
+		Delimiter:       "\t",
+		CommentPrefixes: []string{"#"},
+	}
+
+	buffer := []byte("##build=GRCh38\n" +
+		"1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3\n" +
+		"# interleaved \"quoted\" comment\n" +
+		"2\t67890\tG\tC\t0.01\t0.2\t0.05\t0.4\n")
+
+	result, err := BufferVariants(buffer, metadata)
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	expected := []string{"1\t12345\tA\tT", "2\t67890\tG\tC"}
+	if len(result) != len(expected) {
+		t.Fatalf("BufferVariants() returned %d variants, want %d", len(result), len(expected))
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("BufferVariants()[%d] = %q, want %q", i, result[i], expected[i])
+		}
+	}
+
+	passes, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{expected})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(passes[0], &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	for _, variant := range expected {
+		if values := summaryRows.Rows[variant]; values == nil || len(values.Values) != 4 {
+			t.Errorf("variant %q: expected 4 values, got %v", variant, values)
+		}
+	}
+}
+
+func TestStripCommentLines(t *testing.T) {
+	buffer := []byte("1\ta\n2\tb\n")
+	if result := stripCommentLines(buffer, nil); &result[0] != &buffer[0] {
+		t.Error("stripCommentLines() copied a buffer without comments")
+	}
+	result := stripCommentLines([]byte("##x=1\n1\ta\n%c\n2\tb"), []string{"%"})
+	if string(result) != "1\ta\n2\tb" {
+		t.Errorf("stripCommentLines() = %q, want %q", result, "1\ta\n2\tb")
+	}
+}
+
+func phenotypeTestMetadata(phenotypes []string) BlockMetadata {
+	column := 8
+	return BlockMetadata{
+		Tag: "phewas",
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome:      0,
+			ColumnPosition:        1,
+			ColumnReference:       2,
+			ColumnAlternate:       3,
+			ColumnPValue:          4,
+			ColumnBeta:            5,
+			ColumnSEBeta:          6,
+			ColumnAlleleFrequency: 7,
+		},
+		PvalThreshold:   0.05,
+		Delimiter:       "\t",
+		ColumnPhenotype: &column,
+		Phenotypes:      phenotypes,
+	}
+}
+
+const phenotypeTestBuffer = "1\t12345\tA\tT\t0.001\t0.5\t0.1\t0.3\tT2D\n" +
+	"1\t12345\tA\tT\t0.01\t0.2\t0.1\t0.3\tBMI\n" +
+	"1\t12345\tA\tT\t0.02\t0.1\t0.1\t0.3\tLDL\n" +
+	"2\t67890\tG\tC\t0.5\t0.2\t0.05\t0.4\tT2D\n" +
+	"2\t67890\tG\tC\t0.001\t0.3\t0.05\t0.4\tBMI\n"
+
+func TestBufferPhenotypes(t *testing.T) {
+	result, err := BufferPhenotypes([]byte(phenotypeTestBuffer), phenotypeTestMetadata(nil))
+	if err != nil {
+		t.Fatalf("BufferPhenotypes() unexpected error: %v", err)
+	}
+	if strings.Join(result, ",") != "BMI,LDL,T2D" {
+		t.Errorf("BufferPhenotypes() = %v, want [BMI LDL T2D]", result)
+	}
+
+	result, err = BufferPhenotypes([]byte(phenotypeTestBuffer), phenotypeTestMetadata([]string{"T2D", "HDL"}))
+	if err != nil {
+		t.Fatalf("BufferPhenotypes() unexpected error: %v", err)
+	}
+	if strings.Join(result, ",") != "T2D" {
+		t.Errorf("BufferPhenotypes() with filter = %v, want [T2D]", result)
+	}
+
+	metadata := phenotypeTestMetadata(nil)
+	metadata.ColumnPhenotype = nil
+	if _, err := BufferPhenotypes([]byte(phenotypeTestBuffer), metadata); err == nil {
+		t.Error("BufferPhenotypes() expected error without phenotype column, got none")
+	}
+}
+
+func TestBufferVariantsPhenotypes(t *testing.T) {
+	result, err := BufferVariants([]byte(phenotypeTestBuffer), phenotypeTestMetadata(nil))
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	if strings.Join(result, "|") != "1\t12345\tA\tT|2\t67890\tG\tC" {
+		t.Errorf("BufferVariants() = %q, want each variant once", result)
+	}
+
+	result, err = BufferVariants([]byte(phenotypeTestBuffer), phenotypeTestMetadata([]string{"T2D"}))
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	if strings.Join(result, "|") != "1\t12345\tA\tT" {
+		t.Errorf("BufferVariants() with filter = %q, want [1\\t12345\\tA\\tT]", result)
+	}
+}
+
+func TestBufferSummaryPassesPhenotypes(t *testing.T) {
+	partitions := VariantPartitions{{"1\t12345\tA\tT"}, {"2\t67890\tG\tC", "3\t100\tC\tT"}}
+	buffer := phenotypeTestBuffer + "3\t100\tC\tT\t0.04\t0.1\t0.1\t0.2\tT2D\n"
+	result, err := BufferSummaryPasses([]byte(buffer), phenotypeTestMetadata([]string{"BMI", "T2D"}), partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected one block per partition, got %d", len(result))
+	}
+
+	expectedHeader := append(CreateHeader("phewas_BMI", nil), CreateHeader("phewas_T2D", nil)...)
+	expected := []struct {
+		block   int
+		variant string
+		values  []string
+	}{
+		{0, "1\t12345\tA\tT", []string{"0.01", "0.2", "0.1", "0.3", "0.001", "0.5", "0.1", "0.3"}},
+		{1, "2\t67890\tG\tC", []string{"0.001", "0.3", "0.05", "0.4", "0.5", "0.2", "0.05", "0.4"}},
+		{1, "3\t100\tC\tT", []string{absentValue, absentValue, absentValue, absentValue, "0.04", "0.1", "0.1", "0.2"}},
+	}
+	for _, e := range expected {
+		var summaryRows SummaryRows
+		if err := proto.Unmarshal(result[e.block], &summaryRows); err != nil {
+			t.Fatalf("failed to unmarshal block %d: %v", e.block, err)
+		}
+		if !slices.Equal(summaryRows.Header, expectedHeader) {
+			t.Errorf("block %d: header = %v, want %v", e.block, summaryRows.Header, expectedHeader)
+		}
+		if values := summaryRows.Rows[e.variant].GetValues(); !slices.Equal(values, e.values) {
+			t.Errorf("block %d: values of %q = %v, want %v", e.block, e.variant, values, e.values)
+		}
+	}
+
+	if _, err := BufferSummaryPasses([]byte(phenotypeTestBuffer), phenotypeTestMetadata(nil), partitions); err == nil {
+		t.Error("BufferSummaryPasses() expected error without phenotypes, got none")
+	}
+}
+
+func TestSummaryPassesStringPhenotypes(t *testing.T) {
+	partitions := VariantPartitions{{"1\t12345\tA\tT"}, {"2\t67890\tG\tC", "3\t100\tC\tT"}}
+	buffer := phenotypeTestBuffer + "3\t100\tC\tT\t0.04\t0.1\t0.1\t0.2\tT2D\n"
+	pass, err := BufferSummaryPasses([]byte(buffer), phenotypeTestMetadata([]string{"BMI", "T2D"}), partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	// The pipeline builds the header from the first block of the source
+	header, err := HeaderBytesString(pass[:1], "\t", true)
+	if err != nil {
+		t.Fatalf("HeaderBytesString() unexpected error: %v", err)
+	}
+	expectedHeader := "chromosome\tposition\treference\talternative\t" +
+		"phewas_BMI_pval\tphewas_BMI_beta\tphewas_BMI_sebeta\tphewas_BMI_af\t" +
+		"phewas_T2D_pval\tphewas_T2D_beta\tphewas_T2D_sebeta\tphewas_T2D_af"
+	if header != expectedHeader {
+		t.Errorf("HeaderBytesString() = %q, want %q", header, expectedHeader)
+	}
+
+	rows, err := SummaryPassesString([][][]byte{pass}, "\t", true)
+	if err != nil {
+		t.Fatalf("SummaryPassesString() unexpected error: %v", err)
+	}
+	want := []string{
+		"1\t12345\tA\tT\t1.000000e-02\t0.200000\t0.100000\t0.300000\t1.000000e-03\t0.500000\t0.100000\t0.300000",
+		"2\t67890\tG\tC\t1.000000e-03\t0.300000\t0.050000\t0.400000\t5.000000e-01\t0.200000\t0.050000\t0.400000",
+		"3\t100\tC\tT\tNA\tNA\tNA\tNA\t4.000000e-02\t0.100000\t0.100000\t0.200000",
+	}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("SummaryPassesString() = %q, want %q", rows, want)
+	}
+
+	rows, err = SummaryPassesStringWithOptions([][][]byte{pass}, SummaryOptions{Delimiter: "\t", NotInFile: "-", StatusColumn: true})
+	if err != nil {
+		t.Fatalf("SummaryPassesStringWithOptions() unexpected error: %v", err)
+	}
+	if rows[2] != "-\t-\t-\t-\tnot_in_file\t4.000000e-02\t0.100000\t0.100000\t0.200000\tfound" {
+		t.Errorf("SummaryPassesStringWithOptions() = %q, want BMI not_in_file for the T2D only variant", rows[2])
+	}
+}
+
+func TestSummaryPassesStringMissingPValue(t *testing.T) {
+	partitions := VariantPartitions{{"1\t12345\tA\tT"}, {"2\t67890\tG\tC", "3\t100\tC\tT"}}
+	buffer := phenotypeTestBuffer + "3\t100\tC\tT\tNA\t0.3\t0.1\t0.2\tBMI\n" + "3\t100\tC\tT\t0.04\t0.1\t0.1\t0.2\tT2D\n"
+	metadata := phenotypeTestMetadata([]string{"BMI", "T2D"})
+	metadata.MissingValues = []string{"NA"}
+	pass, err := BufferSummaryPasses([]byte(buffer), metadata, partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	// The row without a p-value is kept, its other statistics are written
+	rows, err := SummaryPassesStringWithOptions([][][]byte{pass}, SummaryOptions{Delimiter: "\t", NotInFile: "-", StatusColumn: true})
+	if err != nil {
+		t.Fatalf("SummaryPassesStringWithOptions() unexpected error: %v", err)
+	}
+	want := "NA\t0.300000\t0.100000\t0.200000\tmissing_value\t4.000000e-02\t0.100000\t0.100000\t0.200000\tfound"
+	if rows[2] != want {
+		t.Errorf("SummaryPassesStringWithOptions() = %q, want %q", rows[2], want)
+	}
+}
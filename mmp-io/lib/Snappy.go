@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"encoding/binary"
+)
+
+// Raw (unframed) snappy compression as used for Parquet pages. The encoder finds
+// matches with a hash of the next four bytes and works on 64KiB blocks so that
+// every copy fits a two byte offset.
+
+const (
+	snappyBlockSize = 1 << 16
+	snappyTableBits = 14
+	snappyTagCopy2  = 2
+)
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func snappyLiteral(dst []byte, literal []byte) []byte {
+	n := len(literal) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, literal...)
+}
+
+func snappyCopy(dst []byte, offset int, length int) []byte {
+	copy2 := func(dst []byte, length int) []byte {
+		return append(dst, byte(length-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+	}
+	for length >= 68 {
+		dst = copy2(dst, 64)
+		length -= 64
+	}
+	if length > 64 {
+		dst = copy2(dst, 60)
+		length -= 60
+	}
+	return copy2(dst, length)
+}
+
+func snappyEncodeBlock(dst []byte, block []byte) []byte {
+	var table [1 << snappyTableBits]int32
+	literal := 0
+	for i := 0; i+4 <= len(block); {
+		u := binary.LittleEndian.Uint32(block[i:])
+		h := snappyHash(u)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || binary.LittleEndian.Uint32(block[candidate:]) != u {
+			i++
+			continue
+		}
+		if literal < i {
+			dst = snappyLiteral(dst, block[literal:i])
+		}
+		length := 4
+		for i+length < len(block) && block[candidate+length] == block[i+length] {
+			length++
+		}
+		dst = snappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	if literal < len(block) {
+		dst = snappyLiteral(dst, block[literal:])
+	}
+	return dst
+}
+
+// snappyEncode compresses src in the raw snappy format.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	for len(src) > 0 {
+		block := src[:min(len(src), snappyBlockSize)]
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+	return dst
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// snappyDecode decodes the literal and two byte offset copy elements written by snappyEncode.
+func snappyDecode(t *testing.T, src []byte) []byte {
+	t.Helper()
+	length, n := binary.Uvarint(src)
+	src = src[n:]
+	var dst []byte
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			literal := int(tag >> 2)
+			src = src[1:]
+			if literal >= 60 {
+				size := literal - 59
+				literal = 0
+				for i := size - 1; i >= 0; i-- {
+					literal = literal<<8 | int(src[i])
+				}
+				src = src[size:]
+			}
+			dst = append(dst, src[:literal+1]...)
+			src = src[literal+1:]
+		case snappyTagCopy2:
+			copyLength := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint16(src[1:]))
+			if offset == 0 || offset > len(dst) {
+				t.Fatalf("invalid copy offset %d at output %d", offset, len(dst))
+			}
+			for i := 0; i < copyLength; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+			src = src[3:]
+		default:
+			t.Fatalf("unexpected element tag %#x", tag)
+		}
+	}
+	if uint64(len(dst)) != length {
+		t.Fatalf("decoded %d bytes, header says %d", len(dst), length)
+	}
+	return dst
+}
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 3*snappyBlockSize)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"empty", nil},
+		{"single byte", []byte("a")},
+		{"short repeat", []byte("hello hello hello hello world")},
+		{"long repeat across blocks", bytes.Repeat([]byte("0.050000\t"), 50000)},
+		{"incompressible", random},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := snappyEncode(tt.input)
+			if got := snappyDecode(t, encoded); !bytes.Equal(got, tt.input) {
+				t.Errorf("snappyEncode() does not round trip")
+			}
+		})
+	}
+	if encoded := snappyEncode(bytes.Repeat([]byte("abcd"), 10000)); len(encoded) > 4000 {
+		t.Errorf("snappyEncode() of a repeated pattern is %d bytes, expected compression", len(encoded))
+	}
+}
@@ -0,0 +1,288 @@
+package lib
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Statistic names used in block headers, which name their columns "<tag>_<statistic>".
+const (
+	StatisticPValue          = "pval"
+	StatisticBeta            = "beta"
+	StatisticSEBeta          = "sebeta"
+	StatisticAlleleFrequency = "af"
+	StatisticSampleSize      = "n"
+)
+
+// missingValue is written for statistics that have no value.
+const missingValue = "NA"
+
+// absentValue fills the columns of a tag of a wide block, a phenotype or a VCF
+// sample, that has no row for the variant, unlike missingValue for the statistics
+// missing from a row.
+const absentValue = ""
+
+// summaryTag locates the columns of one tag within a list of SummaryRows blocks.
+type summaryTag struct {
+	Tag        string
+	Block      int
+	Statistics []string       // statistic names in header order
+	Columns    map[string]int // statistic name to position in the block values
+}
+
+// splitHeaderColumn splits a "<tag>_<statistic>" column name at its last underscore.
+func splitHeaderColumn(column string) (string, string) {
+	if i := strings.LastIndex(column, "_"); i >= 0 {
+		return column[:i], column[i+1:]
+	}
+	return "", column
+}
+
+// column names the statistic column of a tag, "<tag>_<statistic>" for nil naming.
+func (naming *ColumnNaming) column(tag string, statistic string) string {
+	name := statistic
+	if renamed := naming.GetStatistics()[statistic]; renamed != "" {
+		name = renamed
+	}
+	separator := naming.GetSeparator()
+	if separator == "" {
+		separator = "_"
+	}
+	if naming.GetStatisticFirst() {
+		return naming.GetPrefix() + name + separator + tag + naming.GetSuffix()
+	}
+	return naming.GetPrefix() + tag + separator + name + naming.GetSuffix()
+}
+
+// parseColumn is the inverse of column: it returns the tag and statistic of a header
+// column written with naming for one of statistics, ok is false when the column is
+// none of them. The longest match wins, so renamed statistics may share suffixes.
+func (naming *ColumnNaming) parseColumn(column string, statistics []string) (tag string, statistic string, ok bool) {
+	matched := -1
+	for _, candidate := range statistics {
+		before, after, _ := strings.Cut(naming.column("\x00", candidate), "\x00")
+		if len(column) <= len(before)+len(after) || len(before)+len(after) <= matched ||
+			!strings.HasPrefix(column, before) || !strings.HasSuffix(column, after) {
+			continue
+		}
+		matched = len(before) + len(after)
+		tag, statistic, ok = column[len(before):len(column)-len(after)], candidate, true
+	}
+	return tag, statistic, ok
+}
+
+// outputColumn renames a "<tag>_<statistic>" header column with naming, columns
+// without a tag are kept as they are.
+func (naming *ColumnNaming) outputColumn(column string) string {
+	tag, statistic := splitHeaderColumn(column)
+	if tag == "" {
+		return column
+	}
+	return naming.column(tag, statistic)
+}
+
+// summaryTags lists the tags found in the block headers in output order.
+func summaryTags(rows []*SummaryRows) []summaryTag {
+	var result []summaryTag
+	for block := range rows {
+		for i, column := range rows[block].Header {
+			tag, statistic := splitHeaderColumn(column)
+			last := len(result) - 1
+			if last < 0 || result[last].Block != block || result[last].Tag != tag {
+				result = append(result, summaryTag{Tag: tag, Block: block, Columns: make(map[string]int)})
+				last++
+			}
+			result[last].Statistics = append(result[last].Statistics, statistic)
+			result[last].Columns[statistic] = i
+		}
+	}
+	return result
+}
+
+// value returns the statistic of a variant for this tag and whether it is present.
+func (tag summaryTag) value(rows []*SummaryRows, variant string, statistic string) (string, bool) {
+	column, ok := tag.Columns[statistic]
+	if !ok {
+		return "", false
+	}
+	summaryValues := rows[tag.Block].Rows[variant]
+	if summaryValues == nil || column >= len(summaryValues.Values) {
+		return "", false
+	}
+	value := summaryValues.Values[column]
+	if value == "" || value == missingValue {
+		return "", false
+	}
+	return value, true
+}
+
+// present reports whether the block of this tag holds values for the variant.
+func (tag summaryTag) present(rows []*SummaryRows, variant string) bool {
+	for _, statistic := range tag.Statistics {
+		if _, ok := tag.value(rows, variant, statistic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVariantKey is the inverse of variantKey.
+func parseVariantKey(key string, delimiter string) (*Variant, error) {
+	fields := variantCPRA(key, delimiter)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid variant key %q", key)
+	}
+	chrom, err := parseChromosome(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid chromosome in variant key %q: %w", key, err)
+	}
+	pos, err := parseUint64(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid position in variant key %q: %w", key, err)
+	}
+	return &Variant{Chromosome: chrom, Position: pos, Ref: fields[2], Alt: fields[3]}, nil
+}
+
+// compareVariants orders variants by chromosome, position, reference and alternate allele.
+func compareVariants(a, b *Variant) int {
+	return cmp.Or(
+		cmp.Compare(a.Chromosome, b.Chromosome),
+		cmp.Compare(a.Position, b.Position),
+		strings.Compare(a.Ref, b.Ref),
+		strings.Compare(a.Alt, b.Alt),
+	)
+}
+
+// sortVariantKeys sorts variant keys in genomic order and returns them parsed in the same order.
+func sortVariantKeys(keys []string, delimiter string) ([]*Variant, error) {
+	type keyedVariant struct {
+		key     string
+		variant *Variant
+	}
+	sorted := make([]keyedVariant, len(keys))
+	for i, key := range keys {
+		variant, err := parseVariantKey(key, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		sorted[i] = keyedVariant{key, variant}
+	}
+	slices.SortFunc(sorted, func(a, b keyedVariant) int {
+		return compareVariants(a.variant, b.variant)
+	})
+	variants := make([]*Variant, len(keys))
+	for i := range sorted {
+		keys[i], variants[i] = sorted[i].key, sorted[i].variant
+	}
+	return variants, nil
+}
+
+// summaryVariants returns the union of the variants of the blocks.
+func summaryVariants(rows []*SummaryRows) []string {
+	variantSet := make(map[string]bool)
+	var variants []string
+	for i := range rows {
+		for variant := range rows[i].Rows {
+			if !variantSet[variant] {
+				variantSet[variant] = true
+				variants = append(variants, variant)
+			}
+		}
+	}
+	return variants
+}
+
+// typedSummaryTable is a merged block set with typed columns, used by the binary writers.
+// Columns holds one float column per statistic column of the block headers and
+// Valid marks the values that are present.
+type typedSummaryTable struct {
+	Chromosomes []uint32
+	Positions   []uint64
+	Refs        []string
+	Alts        []string
+	Columns     [][]float64
+	Valid       [][]bool
+}
+
+// summaryColumnNames lists the statistic columns of the tags in output order, named
+// following the naming of their block.
+func summaryColumnNames(rows []*SummaryRows, tags []summaryTag) []string {
+	var names []string
+	for _, tag := range tags {
+		for _, statistic := range tag.Statistics {
+			names = append(names, rows[tag.Block].Naming.outputColumn(rows[tag.Block].Header[tag.Columns[statistic]]))
+		}
+	}
+	return names
+}
+
+// newTypedSummaryTable builds the typed table of a block set with variants in genomic order.
+func newTypedSummaryTable(rows []*SummaryRows, tags []summaryTag, delimiter string) (*typedSummaryTable, error) {
+	keys := summaryVariants(rows)
+	table := &typedSummaryTable{
+		Chromosomes: make([]uint32, len(keys)),
+		Positions:   make([]uint64, len(keys)),
+		Refs:        make([]string, len(keys)),
+		Alts:        make([]string, len(keys)),
+	}
+	variants, err := sortVariantKeys(keys, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	for i, variant := range variants {
+		table.Chromosomes[i] = variant.Chromosome
+		table.Positions[i] = variant.Position
+		table.Refs[i] = variant.Ref
+		table.Alts[i] = variant.Alt
+	}
+	for _, tag := range tags {
+		for _, statistic := range tag.Statistics {
+			column := make([]float64, len(keys))
+			valid := make([]bool, len(keys))
+			for i, key := range keys {
+				value, ok := tag.value(rows, key, statistic)
+				if !ok {
+					continue
+				}
+				v, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("tag %s %s of %q: %w", tag.Tag, statistic, key, err)
+				}
+				column[i], valid[i] = v, true
+			}
+			table.Columns = append(table.Columns, column)
+			table.Valid = append(table.Valid, valid)
+		}
+	}
+	return table, nil
+}
+
+// typedSummaryTables builds one typed table per partition, each partition holding the
+// blocks of every source, and checks that the partitions share their columns.
+func typedSummaryTables(partitions [][][]byte, delimiter string) ([]*typedSummaryTable, []string, error) {
+	if len(partitions) == 0 {
+		return nil, nil, fmt.Errorf("no partitions to write")
+	}
+	var names []string
+	tables := make([]*typedSummaryTable, len(partitions))
+	for i, buffer := range partitions {
+		rows, err := unmarshalSummaryRows(buffer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("partition %d: %w", i, err)
+		}
+		tags := summaryTags(rows)
+		partitionNames := summaryColumnNames(rows, tags)
+		if i == 0 {
+			names = partitionNames
+		} else if !slices.Equal(partitionNames, names) {
+			return nil, nil, fmt.Errorf("partition %d columns do not match partition 0", i)
+		}
+		if tables[i], err = newTypedSummaryTable(rows, tags, delimiter); err != nil {
+			return nil, nil, fmt.Errorf("partition %d: %w", i, err)
+		}
+	}
+	return tables, names, nil
+}
@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummaryTags(t *testing.T) {
+	rows := []*SummaryRows{
+		{Header: []string{"a_b_pval", "a_b_beta", "c_pval"}},
+		{Header: []string{"c_pval", "c_beta"}},
+	}
+	tags := summaryTags(rows)
+	expected := []struct {
+		tag   string
+		block int
+		stats string
+	}{
+		{"a_b", 0, "pval beta"},
+		{"c", 0, "pval"},
+		{"c", 1, "pval beta"},
+	}
+	if len(tags) != len(expected) {
+		t.Fatalf("summaryTags() returned %d tags, want %d", len(tags), len(expected))
+	}
+	for i, e := range expected {
+		if tags[i].Tag != e.tag || tags[i].Block != e.block || strings.Join(tags[i].Statistics, " ") != e.stats {
+			t.Errorf("summaryTags()[%d] = %+v, want %+v", i, tags[i], e)
+		}
+	}
+	if tags[1].Columns["pval"] != 2 {
+		t.Errorf("column of c_pval = %d, want 2", tags[1].Columns["pval"])
+	}
+}
+
+func TestSummaryTagValue(t *testing.T) {
+	rows := []*SummaryRows{{
+		Header: CreateHeader("t", nil),
+		Rows: map[string]*SummaryValues{
+			"v1": {Values: []string{"0.1", "NA", "0.2", "0.3"}},
+			"v2": nil,
+			"v3": {Values: []string{}},
+		},
+	}}
+	tag := summaryTags(rows)[0]
+	if value, ok := tag.value(rows, "v1", StatisticPValue); !ok || value != "0.1" {
+		t.Errorf("value(v1, pval) = %q, %v", value, ok)
+	}
+	if _, ok := tag.value(rows, "v1", StatisticBeta); ok {
+		t.Error("value(v1, beta) should be missing for NA")
+	}
+	for _, variant := range []string{"v2", "v3", "v4"} {
+		if tag.present(rows, variant) {
+			t.Errorf("present(%s) = true, want false", variant)
+		}
+	}
+}
+
+func TestSortVariantKeys(t *testing.T) {
+	keys := []string{"23\t5\tA\tT", "2\t100\tG\tC", "10\t1\tA\tT", "2\t100\tA\tT", "2\t99\tA\tT"}
+	variants, err := sortVariantKeys(keys, "\t")
+	if err != nil {
+		t.Fatalf("sortVariantKeys() unexpected error: %v", err)
+	}
+	expected := []string{"2\t99\tA\tT", "2\t100\tA\tT", "2\t100\tG\tC", "10\t1\tA\tT", "23\t5\tA\tT"}
+	if strings.Join(keys, "|") != strings.Join(expected, "|") {
+		t.Errorf("sortVariantKeys() order = %q, want %q", keys, expected)
+	}
+	for i := range keys {
+		if variantKey(variants[i], "\t") != keys[i] {
+			t.Errorf("variants[%d] = %v does not match key %q", i, variants[i], keys[i])
+		}
+	}
+
+	if _, err := sortVariantKeys([]string{"1\tx\tA\tT"}, "\t"); err == nil {
+		t.Error("sortVariantKeys() expected error for invalid position, got none")
+	}
+}
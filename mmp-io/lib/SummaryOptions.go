@@ -0,0 +1,148 @@
+package lib
+
+// Values of the "<tag>_status" column telling why statistics are missing.
+const (
+	StatusFound        = "found"
+	StatusMissingValue = "missing_value"
+	StatusNotInFile    = "not_in_file"
+	StatusNotRequested = "not_requested"
+)
+
+// statusStatistic names the status column of a tag.
+const statusStatistic = "status"
+
+// SummaryOptions configures the merged output of the *WithOptions functions.
+// Statistics without a value are written as NotInFile when the variant was requested
+// from the source but not found in it, as NotRequested when the source was not asked
+// for the variant, and as MissingValue when the variant was found without that
+// statistic; empty markers are written as NA. StatusColumn adds a "<tag>_status"
+// column after the statistics of every tag holding one of the Status values.
+// Naming renames the statistic columns of every block, which otherwise follow the
+// naming stored in the block. Meta appends a meta-analysis of the tags to every row
+// and Direction the direction of effect of the tags after it. Replication appends a
+// "<tag>_replicated" column per replication tag, its thresholds taken over every
+// block written by the call; as the blocks of SummaryBytesStringWithOptions are one
+// partition, it needs Replication.Thresholds or a Bonferroni number of tests.
+// NumberFormat formats the statistics of the blocks.
+type SummaryOptions struct {
+	Delimiter    string        `json:"delimiter" validate:"required"`
+	CPRA         bool          `json:"cpra"`
+	NotInFile    string        `json:"not_in_file,omitempty"`
+	NotRequested string        `json:"not_requested,omitempty"`
+	MissingValue string        `json:"missing_value,omitempty"`
+	StatusColumn bool          `json:"status_column,omitempty"`
+	Naming       *ColumnNaming `json:"naming,omitempty"`
+	Meta         *MetaAnalysis `json:"meta,omitempty"`
+	Direction    *Direction    `json:"direction,omitempty"`
+	Replication  *Replication  `json:"replication,omitempty"`
+	NumberFormat NumberFormat  `json:"number_format"`
+}
+
+// NumberFormat controls how the writers format the statistics of SummaryRows blocks,
+// which hold them as read from the file. The zero value keeps "%e" for p-values and
+// six decimals for the other statistics. SignificantDigits rounds every value to that
+// many significant digits and Shortest writes the shortest representation that reads
+// back to the same value; values smaller in magnitude than ScientificThreshold are
+// written in scientific notation, when it is zero the notation follows "%g".
+// PreserveOriginal writes the values as stored in the blocks: as read from the file,
+// and in their shortest representation for derived values such as corrected statistics.
+type NumberFormat struct {
+	SignificantDigits   int     `json:"significant_digits,omitempty" validate:"gte=0,lte=9"`
+	Shortest            bool    `json:"shortest,omitempty"`
+	ScientificThreshold float64 `json:"scientific_threshold,omitempty" validate:"gte=0"`
+	PreserveOriginal    bool    `json:"preserve_original,omitempty"`
+}
+
+// summaryMarkers are the markers of SummaryOptions with their defaults applied.
+type summaryMarkers struct {
+	notInFile, notRequested, missingValue string
+}
+
+func (options SummaryOptions) markers() summaryMarkers {
+	orMissing := func(marker string) string {
+		if marker == "" {
+			return missingValue
+		}
+		return marker
+	}
+	return summaryMarkers{
+		notInFile:    orMissing(options.NotInFile),
+		notRequested: orMissing(options.NotRequested),
+		missingValue: orMissing(options.MissingValue),
+	}
+}
+
+// tagSpan is the range of header columns belonging to one tag.
+type tagSpan struct {
+	tag        string
+	start, end int
+}
+
+// blockTagSpans splits a block header into runs of columns sharing their tag.
+func blockTagSpans(header []string) []tagSpan {
+	var spans []tagSpan
+	for i, column := range header {
+		tag, _ := splitHeaderColumn(column)
+		if last := len(spans) - 1; last >= 0 && spans[last].tag == tag {
+			spans[last].end = i + 1
+			continue
+		}
+		spans = append(spans, tagSpan{tag: tag, start: i, end: i + 1})
+	}
+	return spans
+}
+
+func statusColumn(tag string, naming *ColumnNaming) string {
+	if tag == "" {
+		return statusStatistic
+	}
+	return naming.column(tag, statusStatistic)
+}
+
+// appendBlockValues appends the values of a variant in one block, replacing the
+// missing ones with the marker for the reason they are missing, and the status
+// of every tag when requested. Values are written following format. A variant is in
+// the file when the block has a row for it, even one of missing values; a tag of a
+// wide block filled with absentValue was not found for it, like a phenotype or
+// sample absent from its rows.
+func appendBlockValues(values []string, block *SummaryRows, spans []tagSpan, variant string, markers summaryMarkers, withStatus bool, format NumberFormat) []string {
+	summaryValues, requested := block.Rows[variant]
+	found := summaryValues.GetValues()
+	if len(spans) == 0 {
+		// Blocks without a header have nothing to mark, their values are kept as they are.
+		return append(values, found...)
+	}
+	isAbsent := func(column int) bool {
+		return column >= len(found) || found[column] == absentValue
+	}
+	isMissing := func(column int) bool {
+		return isAbsent(column) || found[column] == missingValue
+	}
+	for _, span := range spans {
+		status := StatusFound
+		notInFile := true
+		for column := span.start; column < span.end && notInFile; column++ {
+			notInFile = isAbsent(column)
+		}
+		for column := span.start; column < span.end; column++ {
+			switch {
+			case !requested:
+				status = StatusNotRequested
+				values = append(values, markers.notRequested)
+			case notInFile:
+				status = StatusNotInFile
+				values = append(values, markers.notInFile)
+			case isMissing(column):
+				status = StatusMissingValue
+				values = append(values, markers.missingValue)
+			default:
+				_, statistic := splitHeaderColumn(block.Header[column])
+				values = append(values, format.formatValue(statistic, found[column]))
+			}
+		}
+		if withStatus {
+			values = append(values, status)
+		}
+	}
+	return values
+}
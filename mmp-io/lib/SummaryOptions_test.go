@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSummaryBytesStringWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  SummaryOptions
+		header   string
+		expected []string
+	}{
+		{
+			name:    "default markers",
+			options: SummaryOptions{Delimiter: "\t"},
+			header:  "study_1_pval\tstudy_1_beta\tstudy_1_sebeta\tstudy_1_af\tstudy2_pval\tstudy2_beta\tstudy2_sebeta\tstudy2_af",
+			expected: []string{
+				"NA\tNA\tNA\tNA\tNA\tNA\tNA\tNA",
+				"1.000000e-03\t-0.500000\t0.100000\tNA\t5.000000e-02\t0.100000\t0.050000\t0.300000",
+				"1.000000e-08\t0.200000\t0.050000\t0.400000\tNA\tNA\tNA\tNA",
+			},
+		},
+		{
+			name:    "distinct markers",
+			options: SummaryOptions{Delimiter: "\t", NotInFile: "NIF", NotRequested: "NR", MissingValue: "."},
+			header:  "study_1_pval\tstudy_1_beta\tstudy_1_sebeta\tstudy_1_af\tstudy2_pval\tstudy2_beta\tstudy2_sebeta\tstudy2_af",
+			expected: []string{
+				"NIF\tNIF\tNIF\tNIF\tNR\tNR\tNR\tNR",
+				"1.000000e-03\t-0.500000\t0.100000\t.\t5.000000e-02\t0.100000\t0.050000\t0.300000",
+				"1.000000e-08\t0.200000\t0.050000\t0.400000\tNR\tNR\tNR\tNR",
+			},
+		},
+		{
+			name:    "status column with cpra",
+			options: SummaryOptions{Delimiter: "\t", CPRA: true, StatusColumn: true},
+			header:  "chromosome\tposition\treference\talternative\tstudy_1_pval\tstudy_1_beta\tstudy_1_sebeta\tstudy_1_af\tstudy_1_status\tstudy2_pval\tstudy2_beta\tstudy2_sebeta\tstudy2_af\tstudy2_status",
+			expected: []string{
+				"1\t999\tC\tG\tNA\tNA\tNA\tNA\tnot_in_file\tNA\tNA\tNA\tNA\tnot_requested",
+				"1\t12345\tA\tT\t1.000000e-03\t-0.500000\t0.100000\tNA\tmissing_value\t5.000000e-02\t0.100000\t0.050000\t0.300000\tfound",
+				"2\t500\tG\tC\t1.000000e-08\t0.200000\t0.050000\t0.400000\tfound\tNA\tNA\tNA\tNA\tnot_requested",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := testMergedBlocks(t)
+			header, err := HeaderBytesStringWithOptions(buffer, tt.options)
+			if err != nil {
+				t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+			}
+			if header != tt.header {
+				t.Errorf("HeaderBytesStringWithOptions() = %q, want %q", header, tt.header)
+			}
+			rows, err := SummaryBytesStringWithOptions(buffer, tt.options)
+			if err != nil {
+				t.Fatalf("SummaryBytesStringWithOptions() unexpected error: %v", err)
+			}
+			if !slices.Equal(rows, tt.expected) {
+				t.Errorf("SummaryBytesStringWithOptions() = %q, want %q", rows, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeaderBytesStringWithOptionsNaming(t *testing.T) {
+	buffer := testMergedBlocks(t)
+	options := SummaryOptions{
+		Delimiter:    "\t",
+		StatusColumn: true,
+		Naming:       &ColumnNaming{StatisticFirst: true, Statistics: map[string]string{"pval": "p", "status": "state"}},
+	}
+	header, err := HeaderBytesStringWithOptions(buffer, options)
+	if err != nil {
+		t.Fatalf("HeaderBytesStringWithOptions() unexpected error: %v", err)
+	}
+	want := "p_study_1\tbeta_study_1\tsebeta_study_1\taf_study_1\tstate_study_1\tp_study2\tbeta_study2\tsebeta_study2\taf_study2\tstate_study2"
+	if header != want {
+		t.Errorf("HeaderBytesStringWithOptions() = %q, want %q", header, want)
+	}
+}
+
+func TestSummaryPassesStringWithOptions(t *testing.T) {
+	buffer := testMergedBlocks(t)
+	options := SummaryOptions{Delimiter: "\t", StatusColumn: true}
+	rows, err := SummaryPassesStringWithOptions([][][]byte{{buffer[0]}, {buffer[1]}}, options)
+	if err != nil {
+		t.Fatalf("SummaryPassesStringWithOptions() unexpected error: %v", err)
+	}
+	want := []string{
+		"NA\tNA\tNA\tNA\tnot_in_file\tNA\tNA\tNA\tNA\tnot_requested",
+		"1.000000e-03\t-0.500000\t0.100000\tNA\tmissing_value\t5.000000e-02\t0.100000\t0.050000\t0.300000\tfound",
+		"1.000000e-08\t0.200000\t0.050000\t0.400000\tfound\tNA\tNA\tNA\tNA\tnot_requested",
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("SummaryPassesStringWithOptions() = %q, want %q", rows, want)
+	}
+}
+
+func TestSummaryPassesStringWithOptionsChunks(t *testing.T) {
+	partitions := VariantPartitions{{"1\t100\tA\tT", "2\t7\tG\tC"}, {"1\t50\tC\tG", "3\t1\tA\tC"}}
+	passes := chunkTestPasses(t, partitions, map[string][]string{
+		"a": {"1\t50\tC\tG\t0.1\t0.1\t0.1\t0.1\n", "1\t100\tA\tT\t0.2\t0.2\t0.2\t0.2\n", "2\t7\tG\tC\t0.3\t0.3\t0.3\t0.3\n"},
+		"b": {"1\t100\tA\tT\t0.4\t0.4\t0.4\t0.4\n", "2\t8\tG\tC\t0.5\t0.5\t0.5\t0.5\n"},
+	})
+	options := SummaryOptions{Delimiter: "\t", CPRA: true, NotInFile: "-", StatusColumn: true}
+	rows, err := SummaryPassesStringWithOptions(passes, options)
+	if err != nil {
+		t.Fatalf("SummaryPassesStringWithOptions() unexpected error: %v", err)
+	}
+	// One row per variant: found in a later chunk is found, whatever the other chunks say
+	want := []string{
+		"1\t50\tC\tG\t1.000000e-01\t0.100000\t0.100000\t0.100000\tfound\t-\t-\t-\t-\tnot_in_file",
+		"1\t100\tA\tT\t2.000000e-01\t0.200000\t0.200000\t0.200000\tfound\t4.000000e-01\t0.400000\t0.400000\t0.400000\tfound",
+		"2\t7\tG\tC\t3.000000e-01\t0.300000\t0.300000\t0.300000\tfound\t-\t-\t-\t-\tnot_in_file",
+		"3\t1\tA\tC\t-\t-\t-\t-\tnot_in_file\t-\t-\t-\t-\tnot_in_file",
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("SummaryPassesStringWithOptions() = %q, want %q", rows, want)
+	}
+}
+
+func TestCreateMergedColumnsIndexStatusColumns(t *testing.T) {
+	header := []byte("chromosome\tposition\treference\talternative\tstudy_pval\tstudy_beta\tstudy_sebeta\tstudy_af\tstudy_status\n")
+	index, err := CreateMergedColumnsIndex(header, MergedConfiguration{PvalThreshold: 1, Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("CreateMergedColumnsIndex() unexpected error: %v", err)
+	}
+	if len(index) != 1 || index[0].Tag != "study" {
+		t.Errorf("CreateMergedColumnsIndex() = %+v, want one block for study", index)
+	}
+}
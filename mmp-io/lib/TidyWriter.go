@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"slices"
+	"strings"
+)
+
+// tidyHeader starts every tidy row, followed by the standard statistics and the extra columns.
+var tidyHeader = []string{"chromosome", "position", "ref", "alt", "tag"}
+
+// tidyColumns lists the statistic columns of the tidy layout: the standard statistics
+// followed by any other statistic of the tags, in order of appearance.
+func tidyColumns(tags []summaryTag) []string {
+	columns := slices.Clone(mergedStatistics)
+	for _, tag := range tags {
+		for _, statistic := range tag.Statistics {
+			if !slices.Contains(columns, statistic) {
+				columns = append(columns, statistic)
+			}
+		}
+	}
+	return columns
+}
+
+// TidyHeaderBytesString returns the header matching TidySummaryBytesString.
+func TidyHeaderBytesString(buffer [][]byte, delimiter string) (string, error) {
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	header := append(slices.Clone(tidyHeader), tidyColumns(summaryTags(rows))...)
+	return strings.Join(header, delimiter), nil
+}
+
+// TidySummaryBytesString is the long counterpart of SummaryBytesString: it returns one
+// row per variant and tag, in genomic order and then header order, with the variant,
+// the tag and its statistics. Tags without values for a variant are skipped and
+// statistics a tag does not have are NA.
+func TidySummaryBytesString(buffer [][]byte, delimiter string) ([]string, error) {
+	return TidySummaryBytesStringWithOptions(buffer, SummaryOptions{Delimiter: delimiter})
+}
+
+// TidySummaryBytesStringWithOptions is TidySummaryBytesString with the statistics
+// formatted by options.NumberFormat; the other options do not apply to the tidy layout.
+func TidySummaryBytesStringWithOptions(buffer [][]byte, options SummaryOptions) ([]string, error) {
+	delimiter := options.Delimiter
+	rows, err := unmarshalSummaryRows(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []string{}, nil
+	}
+	tags := summaryTags(rows)
+	columns := tidyColumns(tags)
+	variants := summaryVariants(rows)
+	if _, err := sortVariantKeys(variants, delimiter); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(variants))
+	values := make([]string, 0, len(tidyHeader)+len(columns))
+	for _, variant := range variants {
+		for _, tag := range tags {
+			if !tag.present(rows, variant) {
+				continue
+			}
+			values = append(values[:0], variantCPRA(variant, delimiter)...)
+			values = append(values, tag.Tag)
+			for _, statistic := range columns {
+				value, ok := tag.value(rows, variant, statistic)
+				if !ok {
+					value = missingValue
+				}
+				values = append(values, options.NumberFormat.formatValue(statistic, value))
+			}
+			result = append(result, strings.Join(values, delimiter))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTidyBytesString(t *testing.T) {
+	buffer := testMergedBlocks(t)
+	header, err := TidyHeaderBytesString(buffer, "\t")
+	if err != nil {
+		t.Fatalf("TidyHeaderBytesString() unexpected error: %v", err)
+	}
+	if want := "chromosome\tposition\tref\talt\ttag\tpval\tbeta\tsebeta\taf"; header != want {
+		t.Errorf("TidyHeaderBytesString() = %q, want %q", header, want)
+	}
+
+	rows, err := TidySummaryBytesString(buffer, "\t")
+	if err != nil {
+		t.Fatalf("TidySummaryBytesString() unexpected error: %v", err)
+	}
+	want := []string{
+		"1\t12345\tA\tT\tstudy_1\t1.000000e-03\t-0.500000\t0.100000\tNA",
+		"1\t12345\tA\tT\tstudy2\t5.000000e-02\t0.100000\t0.050000\t0.300000",
+		"2\t500\tG\tC\tstudy_1\t1.000000e-08\t0.200000\t0.050000\t0.400000",
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("TidySummaryBytesString() = %q, want %q", rows, want)
+	}
+}
+
+func TestTidyBytesStringExtraColumns(t *testing.T) {
+	data, err := proto.Marshal(&SummaryRows{
+		Header: []string{"study_pval", "study_beta", "study_n"},
+		Rows: map[string]*SummaryValues{
+			"3,7,A,G": {Values: []string{"0.5", "1.0", "1200"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	buffer := [][]byte{data}
+
+	header, err := TidyHeaderBytesString(buffer, ",")
+	if err != nil {
+		t.Fatalf("TidyHeaderBytesString() unexpected error: %v", err)
+	}
+	if want := "chromosome,position,ref,alt,tag,pval,beta,sebeta,af,n"; header != want {
+		t.Errorf("TidyHeaderBytesString() = %q, want %q", header, want)
+	}
+	rows, err := TidySummaryBytesString(buffer, ",")
+	if err != nil {
+		t.Fatalf("TidySummaryBytesString() unexpected error: %v", err)
+	}
+	if want := []string{"3,7,A,G,study,5.000000e-01,1.000000,NA,NA,1200"}; !slices.Equal(rows, want) {
+		t.Errorf("TidySummaryBytesString() = %q, want %q", rows, want)
+	}
+	rows, err = TidySummaryBytesStringWithOptions(buffer, SummaryOptions{Delimiter: ",", NumberFormat: NumberFormat{Shortest: true}})
+	if err != nil {
+		t.Fatalf("TidySummaryBytesStringWithOptions() unexpected error: %v", err)
+	}
+	if want := []string{"3,7,A,G,study,0.5,1,NA,NA,1200"}; !slices.Equal(rows, want) {
+		t.Errorf("TidySummaryBytesStringWithOptions() = %q, want %q", rows, want)
+	}
+
+	if rows, err := TidySummaryBytesString(nil, ","); err != nil || len(rows) != 0 {
+		t.Errorf("TidySummaryBytesString(nil) = %q, %v, want no rows", rows, err)
+	}
+}
@@ -0,0 +1,466 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FileFormatGWASVCF marks BlockMetadata created by CreateVCFColumnsIndex.
+const FileFormatGWASVCF = "gwas-vcf"
+
+// Fixed GWAS-VCF columns; samples start after FORMAT.
+const (
+	vcfColumnChromosome = 0
+	vcfColumnPosition   = 1
+	vcfColumnReference  = 3
+	vcfColumnAlternate  = 4
+	vcfColumnFormat     = 8
+)
+
+// VCFConfiguration describes how to read a GWAS-VCF file.
+// Samples restricts the file to the listed sample IDs, all samples are read when empty.
+type VCFConfiguration struct {
+	Tag           string        `json:"tag" validate:"required"`
+	PvalThreshold float32       `json:"pval_threshold" validate:"required"`
+	Samples       []string      `json:"samples,omitempty"`
+	ColumnNaming  *ColumnNaming `json:"column_naming,omitempty"`
+}
+
+// VCFSample is one trait column of a GWAS-VCF and the tag its statistics are reported under.
+type VCFSample struct {
+	Tag    string `json:"tag"`
+	Column int    `json:"column"`
+}
+
+// decompressBuffer inflates gzip and bgzip (multi-member gzip) buffers and returns other buffers unchanged.
+func decompressBuffer(buffer []byte) ([]byte, error) {
+	if len(buffer) < 2 || buffer[0] != 0x1f || buffer[1] != 0x8b {
+		return buffer, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(buffer))
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer reader.Close()
+	result, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	return result, nil
+}
+
+// CreateVCFColumnsIndex reads the meta-information and "#CHROM" header lines of a
+// GWAS-VCF (plain or bgzipped) and returns the metadata used by BufferVariants and
+// BufferSummaryPasses. A single sample is reported under the configured tag, with
+// several samples each becomes the tag "<tag>_<sample>".
+func CreateVCFColumnsIndex(header []byte, configuration VCFConfiguration) (BlockMetadata, error) {
+	if err := validate.Struct(configuration); err != nil {
+		return BlockMetadata{}, err
+	}
+	header, err := decompressBuffer(header)
+	if err != nil {
+		return BlockMetadata{}, err
+	}
+	headerLine, _, headerOffset, fileMetadata := splitPreamble(header, nil)
+	columns := strings.Split(strings.TrimSpace(string(headerLine)), "\t")
+	if len(columns) <= vcfColumnFormat || columns[0] != "#CHROM" || columns[vcfColumnFormat] != "FORMAT" {
+		return BlockMetadata{}, fmt.Errorf("not a GWAS-VCF header: %q", headerLine)
+	}
+	sampleIDs := columns[vcfColumnFormat+1:]
+	if len(sampleIDs) == 0 {
+		return BlockMetadata{}, fmt.Errorf("GWAS-VCF has no samples")
+	}
+
+	selected := make(map[string]bool)
+	for _, sample := range configuration.Samples {
+		selected[sample] = true
+	}
+	var samples []VCFSample
+	for i, sample := range sampleIDs {
+		if len(selected) > 0 && !selected[sample] {
+			continue
+		}
+		samples = append(samples, VCFSample{Tag: sample, Column: vcfColumnFormat + 1 + i})
+	}
+	for _, sample := range configuration.Samples {
+		if !slices.Contains(sampleIDs, sample) {
+			return BlockMetadata{}, fmt.Errorf("sample %q not found in header", sample)
+		}
+	}
+	// Blocks carry a sample size column when the header declares the SS FORMAT key
+	sampleSize := false
+	for _, field := range fileMetadata.Fields["FORMAT"] {
+		if strings.HasPrefix(field, "<ID=SS,") {
+			sampleSize = true
+		}
+	}
+	for i := range samples {
+		if len(sampleIDs) == 1 {
+			samples[i].Tag = configuration.Tag
+		} else {
+			samples[i].Tag = subTag(configuration.Tag, samples[i].Tag)
+		}
+	}
+
+	return BlockMetadata{
+		Tag:           configuration.Tag,
+		PvalThreshold: configuration.PvalThreshold,
+		Delimiter:     "\t",
+		HeaderOffset:  headerOffset,
+		FileMetadata:  fileMetadata,
+		FileFormat:    FileFormatGWASVCF,
+		VCFSamples:    samples,
+		VCFSampleSize: sampleSize,
+		ColumnNaming:  configuration.ColumnNaming,
+		FileColumnsIndex: FileColumnsIndex{
+			ColumnChromosome:      vcfColumnChromosome,
+			ColumnPosition:        vcfColumnPosition,
+			ColumnReference:       vcfColumnReference,
+			ColumnAlternate:       vcfColumnAlternate,
+			ColumnPValue:          -1,
+			ColumnBeta:            -1,
+			ColumnSEBeta:          -1,
+			ColumnAlleleFrequency: -1,
+		},
+	}, nil
+}
+
+// vcfFormat holds the position of the GWAS-VCF FORMAT keys in a sample field, -1 when absent.
+type vcfFormat struct {
+	es, se, lp, af, ss int
+}
+
+func parseVCFFormat(format string) (vcfFormat, error) {
+	result := vcfFormat{-1, -1, -1, -1, -1}
+	for i, key := range strings.Split(format, ":") {
+		switch key {
+		case "ES":
+			result.es = i
+		case "SE":
+			result.se = i
+		case "LP":
+			result.lp = i
+		case "AF":
+			result.af = i
+		case "SS":
+			result.ss = i
+		}
+	}
+	if result.es < 0 || result.se < 0 || result.lp < 0 {
+		return result, fmt.Errorf("FORMAT %q lacks ES, SE or LP", format)
+	}
+	return result, nil
+}
+
+func vcfField(fields []string, index int) string {
+	if index < 0 || index >= len(fields) {
+		return "."
+	}
+	return fields[index]
+}
+
+// parseVCFSample maps the FORMAT fields of one sample onto an AssociationStatistic.
+// It returns nil when the sample has no estimate for the variant. The second result
+// is false when the allele frequency is missing.
+func parseVCFSample(value string, format vcfFormat) (*AssociationStatistic, bool, error) {
+	fields := strings.Split(value, ":")
+	lp, es, se := vcfField(fields, format.lp), vcfField(fields, format.es), vcfField(fields, format.se)
+	if lp == "." || es == "." || se == "." {
+		return nil, false, nil
+	}
+	pvalue, err := vcfPValue(lp)
+	if err != nil {
+		return nil, false, err
+	}
+	beta, err := parseFloat32(es)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid ES: %w", err)
+	}
+	sebeta, err := parseFloat32(se)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid SE: %w", err)
+	}
+	assoc := &AssociationStatistic{
+		PValue: float32(pvalue),
+		Beta:   beta,
+		Sebeta: sebeta,
+	}
+	af := vcfField(fields, format.af)
+	if af == "." {
+		return assoc, false, nil
+	}
+	if assoc.Af, err = parseFloat32(af); err != nil {
+		return nil, false, fmt.Errorf("invalid AF: %w", err)
+	}
+	return assoc, true, nil
+}
+
+// vcfSampleStatistics returns the p-value, beta, standard error and allele frequency
+// of a sample for the blocks, NA for the missing ones, and nil when the sample has
+// none of LP, ES and SE for the variant. The p-value is derived from LP, the other
+// values are kept as read unless corrected by the genomic control lambda.
+func vcfSampleStatistics(fields []string, format vcfFormat, lambda float64) ([]string, error) {
+	lp, es, se, af := vcfField(fields, format.lp), vcfField(fields, format.es), vcfField(fields, format.se), vcfField(fields, format.af)
+	if lp == "." && es == "." && se == "." {
+		return nil, nil
+	}
+	statistics := []string{missingValue, missingValue, missingValue, missingValue}
+	if lp != "." {
+		pvalue, err := vcfPValue(lp)
+		if err != nil {
+			return nil, err
+		}
+		statistics[0] = strconv.FormatFloat(correctPValue(pvalue, lambda), 'g', -1, 64)
+	}
+	if es != "." {
+		if _, err := parseFloat32(es); err != nil {
+			return nil, fmt.Errorf("invalid ES: %w", err)
+		}
+		statistics[1] = es
+	}
+	if se != "." {
+		sebeta, err := parseFloat32(se)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SE: %w", err)
+		}
+		statistics[2] = se
+		if corrected := correctStandardError(sebeta, lambda); corrected != sebeta {
+			statistics[2] = blockStatistic(corrected)
+		}
+	}
+	if af != "." {
+		if _, err := parseFloat32(af); err != nil {
+			return nil, fmt.Errorf("invalid AF: %w", err)
+		}
+		statistics[3] = af
+	}
+	return statistics, nil
+}
+
+// vcfPValue converts LP to a p-value. It is kept in float64 for the blocks as the
+// float32 of AssociationStatistic underflows to 0 above LP 45; the integer part
+// goes through Pow10 so that whole LPs give exact powers of ten.
+func vcfPValue(lp string) (float64, error) {
+	negLog10P, err := strconv.ParseFloat(lp, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LP: %w", err)
+	}
+	if math.IsInf(negLog10P, 0) || math.IsNaN(negLog10P) {
+		return math.Pow(10, -negLog10P), nil
+	}
+	exponent, fraction := math.Modf(negLog10P)
+	return math.Pow10(-int(exponent)) * math.Pow(10, -fraction), nil
+}
+
+// vcfSampleSize formats the SS field of a sample, NA when it is missing.
+func vcfSampleSize(fields []string, format vcfFormat) (string, error) {
+	ss := vcfField(fields, format.ss)
+	if ss == "." {
+		return missingValue, nil
+	}
+	n, err := strconv.ParseFloat(ss, 64)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid SS %q", ss)
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+// vcfAlleleFields returns the fields of one alternate allele of a multi-allelic
+// record: ALT holds the allele and every sample value with one comma separated
+// value per allele (Number=A) holds the value of the allele.
+func vcfAlleleFields(fields []string, metadata BlockMetadata, alleles []string, allele int) []string {
+	result := slices.Clone(fields)
+	result[vcfColumnAlternate] = alleles[allele]
+	for _, sample := range metadata.VCFSamples {
+		values := strings.Split(fields[sample.Column], ":")
+		for i, value := range values {
+			if perAllele := strings.Split(value, ","); len(perAllele) == len(alleles) {
+				values[i] = perAllele[allele]
+			}
+		}
+		result[sample.Column] = strings.Join(values, ":")
+	}
+	return result
+}
+
+// forEachVCFRecord calls fn with the tab separated fields of every data line of a GWAS-VCF buffer.
+// Multi-allelic records are split into one record per alternate allele.
+func forEachVCFRecord(buffer []byte, metadata BlockMetadata, fn func(fields []string, format vcfFormat) error) error {
+	buffer, err := decompressBuffer(buffer)
+	if err != nil {
+		return err
+	}
+	requiredLen := vcfColumnFormat + 1
+	for _, sample := range metadata.VCFSamples {
+		requiredLen = max(requiredLen, sample.Column+1)
+	}
+
+	var format vcfFormat
+	lastFormat := ""
+	for len(buffer) > 0 {
+		line := buffer
+		if i := bytes.IndexByte(buffer, '\n'); i >= 0 {
+			line, buffer = buffer[:i], buffer[i+1:]
+		} else {
+			buffer = nil
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(string(line), "\t")
+		if len(fields) < requiredLen {
+			return fmt.Errorf("insufficient columns: expected at least %d, got %d", requiredLen, len(fields))
+		}
+		if fields[vcfColumnFormat] != lastFormat {
+			if format, err = parseVCFFormat(fields[vcfColumnFormat]); err != nil {
+				return err
+			}
+			lastFormat = fields[vcfColumnFormat]
+		}
+		alleles := strings.Split(fields[vcfColumnAlternate], ",")
+		if len(alleles) == 1 {
+			if err := fn(fields, format); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range alleles {
+			if err := fn(vcfAlleleFields(fields, metadata, alleles, i), format); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bufferVCFVariants returns the variants where any selected sample is below the p-value threshold.
+func bufferVCFVariants(buffer []byte, metadata BlockMetadata) ([]string, error) {
+	var result []string
+	err := forEachVCFRecord(buffer, metadata, func(fields []string, format vcfFormat) error {
+		for _, sample := range metadata.VCFSamples {
+			assoc, _, err := parseVCFSample(fields[sample.Column], format)
+			if err != nil {
+				return err
+			}
+			if assoc != nil && correctPValue(float64(assoc.PValue), metadata.GenomicControl[sample.Tag]) < float64(metadata.PvalThreshold) {
+				variant, err := parseVariant(fields, metadata.FileColumnsIndex)
+				if err != nil {
+					return err
+				}
+				result = append(result, variantKey(variant, metadata.Delimiter))
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// bufferVCFGenomicControl is BufferGenomicControl for GWAS-VCF, one count per sample.
+func bufferVCFGenomicControl(buffer []byte, metadata BlockMetadata, configuration GenomicControlConfiguration) ([]GenomicControlCounts, error) {
+	result := make([]GenomicControlCounts, len(metadata.VCFSamples))
+	for i, sample := range metadata.VCFSamples {
+		result[i] = newGenomicControlCounts(sample.Tag, configuration)
+	}
+	err := forEachVCFRecord(buffer, metadata, func(fields []string, format vcfFormat) error {
+		chromosome, err := parseChromosome(fields[vcfColumnChromosome])
+		if err != nil {
+			return fmt.Errorf("invalid chromosome: %w", err)
+		}
+		for i, sample := range metadata.VCFSamples {
+			assoc, hasAf, err := parseVCFSample(fields[sample.Column], format)
+			if err != nil {
+				return err
+			}
+			if assoc == nil {
+				continue
+			}
+			maf := math.NaN()
+			if hasAf {
+				maf = minorAlleleFrequency(float64(assoc.Af))
+			}
+			result[i].add(float64(assoc.PValue), chromosome, maf, configuration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// bufferVCFSummaryPasses is BufferSummaryPasses for GWAS-VCF. Each block carries the
+// columns of every selected sample side by side, samples without an estimate are NA.
+func bufferVCFSummaryPasses(buffer []byte, metadata BlockMetadata, partitions VariantPartitions) ([][]byte, error) {
+	var header []string
+	for _, sample := range metadata.VCFSamples {
+		header = append(header, blockHeader(sample.Tag, metadata)...)
+	}
+	sampleColumns := len(header) / max(len(metadata.VCFSamples), 1)
+
+	result := make([]SummaryRows, len(partitions))
+	variantSet := make(map[string]int)
+	for i, group := range partitions {
+		result[i].Rows = make(map[string]*SummaryValues)
+		result[i].Header = header
+		result[i].Naming = metadata.ColumnNaming
+		for _, variant := range group {
+			variantSet[variant] = i
+			result[i].Rows[variant] = nil
+		}
+	}
+
+	err := forEachVCFRecord(buffer, metadata, func(fields []string, format vcfFormat) error {
+		variant, err := parseVariant(fields, metadata.FileColumnsIndex)
+		if err != nil {
+			return err
+		}
+		key := variantKey(variant, metadata.Delimiter)
+		index, ok := variantSet[key]
+		if !ok {
+			return nil
+		}
+		values := make([]string, 0, len(header))
+		found := false
+		for _, sample := range metadata.VCFSamples {
+			sampleFields := strings.Split(fields[sample.Column], ":")
+			statistics, err := vcfSampleStatistics(sampleFields, format, metadata.GenomicControl[sample.Tag])
+			if err != nil {
+				return err
+			}
+			if statistics == nil {
+				for range sampleColumns {
+					values = append(values, absentValue)
+				}
+				continue
+			}
+			found = true
+			if metadata.VCFSampleSize {
+				n, err := vcfSampleSize(sampleFields, format)
+				if err != nil {
+					return err
+				}
+				statistics = append(statistics, n)
+			}
+			values = append(values, statistics...)
+		}
+		if found {
+			result[index].Rows[key] = &SummaryValues{Values: values}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return marshalSummaryRows(result)
+}
@@ -0,0 +1,300 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const testVCFHeader = "##fileformat=VCFv4.2\n" +
+	"##FORMAT=<ID=ES,Number=A,Type=Float,Description=\"Effect size estimate relative to the alternative allele\">\n" +
+	"##genome_build=GRCh37\n" +
+	"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tTRAIT1\tTRAIT2\n"
+
+const testVCFData = "1\t12345\trs1\tA\tT\t.\tPASS\t.\tES:SE:LP:AF:SS\t0.5:0.1:3:0.3:1000\t0.1:0.1:0.5:0.3:2000\n" +
+	"2\t67890\trs2\tG\tC\t.\tPASS\t.\tES:SE:LP:AF:SS\t0.2:0.05:1:0.4:1000\t.:.:.:.:.\n" +
+	"X\t11111\trs3\tC\tG\t.\tPASS\t.\tES:SE:LP\t-0.3:0.08:8\t0.01:0.02:0.1\n"
+
+func testVCFConfiguration() VCFConfiguration {
+	return VCFConfiguration{Tag: "ieu", PvalThreshold: 0.05}
+}
+
+func TestCreateVCFColumnsIndex(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader+testVCFData), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if metadata.FileFormat != FileFormatGWASVCF {
+		t.Errorf("FileFormat = %q, want %q", metadata.FileFormat, FileFormatGWASVCF)
+	}
+	if metadata.HeaderOffset != len(testVCFHeader) {
+		t.Errorf("HeaderOffset = %d, want %d", metadata.HeaderOffset, len(testVCFHeader))
+	}
+	if metadata.FileMetadata.GenomeBuild != "GRCh37" {
+		t.Errorf("GenomeBuild = %q, want GRCh37", metadata.FileMetadata.GenomeBuild)
+	}
+	expected := []VCFSample{{Tag: "ieu_TRAIT1", Column: 9}, {Tag: "ieu_TRAIT2", Column: 10}}
+	if len(metadata.VCFSamples) != len(expected) {
+		t.Fatalf("VCFSamples = %v, want %v", metadata.VCFSamples, expected)
+	}
+	for i := range expected {
+		if metadata.VCFSamples[i] != expected[i] {
+			t.Errorf("VCFSamples[%d] = %v, want %v", i, metadata.VCFSamples[i], expected[i])
+		}
+	}
+}
+
+func TestCreateVCFColumnsIndexSelectedSample(t *testing.T) {
+	configuration := testVCFConfiguration()
+	configuration.Samples = []string{"TRAIT2"}
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), configuration)
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if len(metadata.VCFSamples) != 1 || metadata.VCFSamples[0].Tag != "ieu_TRAIT2" || metadata.VCFSamples[0].Column != 10 {
+		t.Errorf("VCFSamples = %v, want [{ieu_TRAIT2 10}]", metadata.VCFSamples)
+	}
+
+	// Samples after the last selected one are left out as well
+	configuration.Samples = []string{"TRAIT1"}
+	metadata, err = CreateVCFColumnsIndex([]byte(testVCFHeader), configuration)
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if len(metadata.VCFSamples) != 1 || metadata.VCFSamples[0].Tag != "ieu_TRAIT1" || metadata.VCFSamples[0].Column != 9 {
+		t.Errorf("VCFSamples = %v, want [{ieu_TRAIT1 9}]", metadata.VCFSamples)
+	}
+
+	configuration.Samples = []string{"TRAIT3"}
+	if _, err := CreateVCFColumnsIndex([]byte(testVCFHeader), configuration); err == nil {
+		t.Error("CreateVCFColumnsIndex() expected error for unknown sample, got none")
+	}
+}
+
+func TestCreateVCFColumnsIndexErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		configuration VCFConfiguration
+	}{
+		{"missing tag", testVCFHeader, VCFConfiguration{PvalThreshold: 0.05}},
+		{"delimited header", "CHR\tPOS\tREF\tALT\n", testVCFConfiguration()},
+		{"no samples", "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\n", testVCFConfiguration()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreateVCFColumnsIndex([]byte(tt.header), tt.configuration); err == nil {
+				t.Errorf("CreateVCFColumnsIndex() expected error, got none")
+			}
+		})
+	}
+}
+
+func TestBufferVariantsVCF(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(testVCFHeader + testVCFData))
+	writer.Close()
+
+	tests := []struct {
+		name   string
+		buffer []byte
+	}{
+		{"plain", []byte(testVCFHeader + testVCFData)},
+		{"bgzipped", compressed.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := CreateVCFColumnsIndex(tt.buffer, testVCFConfiguration())
+			if err != nil {
+				t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+			}
+			result, err := BufferVariants(tt.buffer, metadata)
+			if err != nil {
+				t.Fatalf("BufferVariants() unexpected error: %v", err)
+			}
+			// rs2 has LP=1 (p=0.1) in TRAIT1 and no estimate in TRAIT2
+			expected := []string{"1\t12345\tA\tT", "23\t11111\tC\tG"}
+			if strings.Join(result, "|") != strings.Join(expected, "|") {
+				t.Errorf("BufferVariants() = %q, want %q", result, expected)
+			}
+		})
+	}
+}
+
+// TestBufferVariantsVCFReaderChunks reads a GWAS-VCF the way readFileInBlocks
+// passes it: the meta-information lines with the #CHROM line as the first chunk,
+// one data line per chunk after it.
+func TestBufferVariantsVCFReaderChunks(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if metadata.HeaderOffset != len(testVCFHeader) {
+		t.Errorf("HeaderOffset = %d, want %d", metadata.HeaderOffset, len(testVCFHeader))
+	}
+	var result []string
+	for _, line := range strings.SplitAfter(testVCFData, "\n") {
+		variants, err := BufferVariants([]byte(line), metadata)
+		if err != nil {
+			t.Fatalf("BufferVariants() unexpected error: %v", err)
+		}
+		result = append(result, variants...)
+	}
+	expected := []string{"1\t12345\tA\tT", "23\t11111\tC\tG"}
+	if strings.Join(result, "|") != strings.Join(expected, "|") {
+		t.Errorf("BufferVariants() = %q, want %q", result, expected)
+	}
+
+	// The first line alone holds no #CHROM line
+	if _, err := CreateVCFColumnsIndex([]byte("##fileformat=VCFv4.2\n"), testVCFConfiguration()); err == nil {
+		t.Error("CreateVCFColumnsIndex() expected error for a first chunk without the #CHROM line, got none")
+	}
+}
+
+func TestBufferSummaryPassesVCF(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	partitions := VariantPartitions{{"1\t12345\tA\tT", "2\t67890\tG\tC", "23\t11111\tC\tG"}}
+	result, err := BufferSummaryPasses([]byte(testVCFData), metadata, partitions)
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(result[0], &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	expectedHeader := "ieu_TRAIT1_pval ieu_TRAIT1_beta ieu_TRAIT1_sebeta ieu_TRAIT1_af ieu_TRAIT2_pval ieu_TRAIT2_beta ieu_TRAIT2_sebeta ieu_TRAIT2_af"
+	if strings.Join(summaryRows.Header, " ") != expectedHeader {
+		t.Errorf("header = %q, want %q", strings.Join(summaryRows.Header, " "), expectedHeader)
+	}
+
+	tests := []struct {
+		variant  string
+		expected []string
+	}{
+		{"1\t12345\tA\tT", []string{"0.001", "0.5", "0.1", "0.3", "0.31622776601683794", "0.1", "0.1", "0.3"}},
+		{"2\t67890\tG\tC", []string{"0.1", "0.2", "0.05", "0.4", absentValue, absentValue, absentValue, absentValue}},
+		{"23\t11111\tC\tG", []string{"1e-08", "-0.3", "0.08", "NA", "0.7943282347242815", "0.01", "0.02", "NA"}},
+	}
+	for _, tt := range tests {
+		values := summaryRows.Rows[tt.variant]
+		if values == nil {
+			t.Errorf("variant %q not found", tt.variant)
+			continue
+		}
+		if strings.Join(values.Values, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("variant %q values = %v, want %v", tt.variant, values.Values, tt.expected)
+		}
+	}
+}
+
+func TestBufferSummaryPassesVCFMissingFormatKey(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	buffer := []byte("1\t12345\trs1\tA\tT\t.\tPASS\t.\tES:SE\t0.5:0.1\t0.1:0.1\n")
+	if _, err := BufferSummaryPasses(buffer, metadata, VariantPartitions{{"1\t12345\tA\tT"}}); err == nil {
+		t.Error("BufferSummaryPasses() expected error for FORMAT without LP, got none")
+	}
+}
+
+func TestBufferSummaryPassesVCFSampleSize(t *testing.T) {
+	header := "##fileformat=VCFv4.2\n" +
+		"##FORMAT=<ID=SS,Number=A,Type=Float,Description=\"Sample size used to estimate genetic effect\">\n" +
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tTRAIT1\n"
+	data := "1\t12345\trs1\tA\tT\t.\tPASS\t.\tES:SE:LP:AF:SS\t0.5:0.1:3:0.3:1000\n" +
+		"2\t67890\trs2\tG\tC\t.\tPASS\t.\tES:SE:LP\t0.2:0.05:1\n"
+	metadata, err := CreateVCFColumnsIndex([]byte(header), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	if !metadata.VCFSampleSize {
+		t.Fatal("VCFSampleSize = false, want true for a header declaring SS")
+	}
+	result, err := BufferSummaryPasses([]byte(data), metadata, VariantPartitions{{"1\t12345\tA\tT", "2\t67890\tG\tC"}})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(result[0], &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if got := strings.Join(summaryRows.Header, " "); got != "ieu_pval ieu_beta ieu_sebeta ieu_af ieu_n" {
+		t.Errorf("header = %q", got)
+	}
+	tests := map[string]string{
+		"1\t12345\tA\tT": "0.001 0.5 0.1 0.3 1000",
+		"2\t67890\tG\tC": "0.1 0.2 0.05 NA NA",
+	}
+	for variant, expected := range tests {
+		if got := strings.Join(summaryRows.Rows[variant].GetValues(), " "); got != expected {
+			t.Errorf("variant %q values = %q, want %q", variant, got, expected)
+		}
+	}
+}
+
+func TestBufferSummaryPassesVCFStrongAssociation(t *testing.T) {
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	// p = 1e-300 is below the smallest float32
+	data := "1\t12345\trs1\tA\tT\t.\tPASS\t.\tES:SE:LP\t0.5:0.01:300\t.:.:.\n"
+	variants, err := BufferVariants([]byte(data), metadata)
+	if err != nil || len(variants) != 1 {
+		t.Fatalf("BufferVariants() = %q, %v, want rs1", variants, err)
+	}
+	result, err := BufferSummaryPasses([]byte(data), metadata, VariantPartitions{variants})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(result[0], &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if values := summaryRows.Rows[variants[0]].GetValues(); len(values) == 0 || values[0] != "1e-300" {
+		t.Errorf("values = %v, want p-value 1e-300", values)
+	}
+}
+
+func TestBufferSummaryPassesVCFMultiallelic(t *testing.T) {
+	data := "1\t12345\trs1\tA\tT,G\t.\tPASS\t.\tES:SE:LP:AF\t0.5,-0.2:0.1,0.05:3,1:0.3,0.1\t0.1,0.3:0.1,0.1:0.5,4:0.3,0.1\n"
+	metadata, err := CreateVCFColumnsIndex([]byte(testVCFHeader), testVCFConfiguration())
+	if err != nil {
+		t.Fatalf("CreateVCFColumnsIndex() unexpected error: %v", err)
+	}
+	variants, err := BufferVariants([]byte(data), metadata)
+	if err != nil {
+		t.Fatalf("BufferVariants() unexpected error: %v", err)
+	}
+	expected := []string{"1\t12345\tA\tT", "1\t12345\tA\tG"}
+	if strings.Join(variants, "|") != strings.Join(expected, "|") {
+		t.Errorf("BufferVariants() = %q, want %q", variants, expected)
+	}
+
+	result, err := BufferSummaryPasses([]byte(data), metadata, VariantPartitions{expected})
+	if err != nil {
+		t.Fatalf("BufferSummaryPasses() unexpected error: %v", err)
+	}
+	var summaryRows SummaryRows
+	if err := proto.Unmarshal(result[0], &summaryRows); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	tests := map[string]string{
+		"1\t12345\tA\tT": "0.001 0.5 0.1 0.3 0.31622776601683794 0.1 0.1 0.3",
+		"1\t12345\tA\tG": "0.1 -0.2 0.05 0.1 0.0001 0.3 0.1 0.1",
+	}
+	for variant, expected := range tests {
+		if got := strings.Join(summaryRows.Rows[variant].GetValues(), " "); got != expected {
+			t.Errorf("variant %q values = %q, want %q", variant, got, expected)
+		}
+	}
+}
@@ -116,6 +116,7 @@ type SummaryRows struct {
 	state         protoimpl.MessageState    `protogen:"open.v1"`
 	Header        []string                  `protobuf:"bytes,1,rep,name=header,proto3" json:"header,omitempty"`
 	Rows          map[string]*SummaryValues `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // key is VariantKey: "chrom\tpos\tref\talt"
+	Naming        *ColumnNaming             `protobuf:"bytes,3,opt,name=naming,proto3" json:"naming,omitempty"`                                                                       // naming of the statistic columns when the block is written out
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -164,6 +165,13 @@ func (x *SummaryRows) GetRows() map[string]*SummaryValues {
 	return nil
 }
 
+func (x *SummaryRows) GetNaming() *ColumnNaming {
+	if x != nil {
+		return x.Naming
+	}
+	return nil
+}
+
 // SummaryFile represents the complete summary file structure
 type SummaryFile struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -209,6 +217,85 @@ func (x *SummaryFile) GetRows() []*SummaryRows {
 	return nil
 }
 
+// ColumnNaming is the template of the statistic column names of a tag in the
+// output header: prefix, tag, separator, statistic name and suffix, with the
+// statistic name before the tag when statistic_first is set
+type ColumnNaming struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Prefix         string                 `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Suffix         string                 `protobuf:"bytes,2,opt,name=suffix,proto3" json:"suffix,omitempty"`
+	Separator      string                 `protobuf:"bytes,3,opt,name=separator,proto3" json:"separator,omitempty"` // defaults to "_"
+	StatisticFirst bool                   `protobuf:"varint,4,opt,name=statistic_first,json=statisticFirst,proto3" json:"statistic_first,omitempty"`
+	Statistics     map[string]string      `protobuf:"bytes,5,rep,name=statistics,proto3" json:"statistics,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // statistic (pval, beta, sebeta, af, status) to its name in the header
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ColumnNaming) Reset() {
+	*x = ColumnNaming{}
+	mi := &file_summaryfile_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnNaming) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnNaming) ProtoMessage() {}
+
+func (x *ColumnNaming) ProtoReflect() protoreflect.Message {
+	mi := &file_summaryfile_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnNaming.ProtoReflect.Descriptor instead.
+func (*ColumnNaming) Descriptor() ([]byte, []int) {
+	return file_summaryfile_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ColumnNaming) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ColumnNaming) GetSuffix() string {
+	if x != nil {
+		return x.Suffix
+	}
+	return ""
+}
+
+func (x *ColumnNaming) GetSeparator() string {
+	if x != nil {
+		return x.Separator
+	}
+	return ""
+}
+
+func (x *ColumnNaming) GetStatisticFirst() bool {
+	if x != nil {
+		return x.StatisticFirst
+	}
+	return false
+}
+
+func (x *ColumnNaming) GetStatistics() map[string]string {
+	if x != nil {
+		return x.Statistics
+	}
+	return nil
+}
+
 var File_summaryfile_proto protoreflect.FileDescriptor
 
 const file_summaryfile_proto_rawDesc = "" +
@@ -217,15 +304,27 @@ const file_summaryfile_proto_rawDesc = "" +
 	"\rSummaryHeader\x12\x18\n" +
 	"\acolumns\x18\x01 \x03(\tR\acolumns\"'\n" +
 	"\rSummaryValues\x12\x16\n" +
-	"\x06values\x18\x01 \x03(\tR\x06values\"\xb2\x01\n" +
+	"\x06values\x18\x01 \x03(\tR\x06values\"\xe5\x01\n" +
 	"\vSummaryRows\x12\x16\n" +
 	"\x06header\x18\x01 \x03(\tR\x06header\x126\n" +
-	"\x04rows\x18\x02 \x03(\v2\".summaryfile.SummaryRows.RowsEntryR\x04rows\x1aS\n" +
+	"\x04rows\x18\x02 \x03(\v2\".summaryfile.SummaryRows.RowsEntryR\x04rows\x121\n" +
+	"\x06naming\x18\x03 \x01(\v2\x19.summaryfile.ColumnNamingR\x06naming\x1aS\n" +
 	"\tRowsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
 	"\x05value\x18\x02 \x01(\v2\x1a.summaryfile.SummaryValuesR\x05value:\x028\x01\";\n" +
 	"\vSummaryFile\x12,\n" +
-	"\x04rows\x18\x01 \x03(\v2\x18.summaryfile.SummaryRowsR\x04rowsB,Z*github.com/majorseitan/MMP_2024/mmp-io;libb\x06proto3"
+	"\x04rows\x18\x01 \x03(\v2\x18.summaryfile.SummaryRowsR\x04rows\"\x8f\x02\n" +
+	"\fColumnNaming\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x16\n" +
+	"\x06suffix\x18\x02 \x01(\tR\x06suffix\x12\x1c\n" +
+	"\tseparator\x18\x03 \x01(\tR\tseparator\x12'\n" +
+	"\x0fstatistic_first\x18\x04 \x01(\bR\x0estatisticFirst\x12I\n" +
+	"\n" +
+	"statistics\x18\x05 \x03(\v2).summaryfile.ColumnNaming.StatisticsEntryR\n" +
+	"statistics\x1a=\n" +
+	"\x0fStatisticsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B,Z*github.com/majorseitan/MMP_2024/mmp-io;libb\x06proto3"
 
 var (
 	file_summaryfile_proto_rawDescOnce sync.Once
@@ -239,23 +338,27 @@ func file_summaryfile_proto_rawDescGZIP() []byte {
 	return file_summaryfile_proto_rawDescData
 }
 
-var file_summaryfile_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_summaryfile_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_summaryfile_proto_goTypes = []any{
 	(*SummaryHeader)(nil), // 0: summaryfile.SummaryHeader
 	(*SummaryValues)(nil), // 1: summaryfile.SummaryValues
 	(*SummaryRows)(nil),   // 2: summaryfile.SummaryRows
 	(*SummaryFile)(nil),   // 3: summaryfile.SummaryFile
-	nil,                   // 4: summaryfile.SummaryRows.RowsEntry
+	(*ColumnNaming)(nil),  // 4: summaryfile.ColumnNaming
+	nil,                   // 5: summaryfile.SummaryRows.RowsEntry
+	nil,                   // 6: summaryfile.ColumnNaming.StatisticsEntry
 }
 var file_summaryfile_proto_depIdxs = []int32{
-	4, // 0: summaryfile.SummaryRows.rows:type_name -> summaryfile.SummaryRows.RowsEntry
-	2, // 1: summaryfile.SummaryFile.rows:type_name -> summaryfile.SummaryRows
-	1, // 2: summaryfile.SummaryRows.RowsEntry.value:type_name -> summaryfile.SummaryValues
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	5, // 0: summaryfile.SummaryRows.rows:type_name -> summaryfile.SummaryRows.RowsEntry
+	4, // 1: summaryfile.SummaryRows.naming:type_name -> summaryfile.ColumnNaming
+	2, // 2: summaryfile.SummaryFile.rows:type_name -> summaryfile.SummaryRows
+	6, // 3: summaryfile.ColumnNaming.statistics:type_name -> summaryfile.ColumnNaming.StatisticsEntry
+	1, // 4: summaryfile.SummaryRows.RowsEntry.value:type_name -> summaryfile.SummaryValues
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_summaryfile_proto_init() }
@@ -269,7 +372,7 @@ func file_summaryfile_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_summaryfile_proto_rawDesc), len(file_summaryfile_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
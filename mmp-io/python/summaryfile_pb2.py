@@ -24,7 +24,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x11summaryfile.proto\x12\x0bsummaryfile\")\n\rSummaryHeader\x12\x18\n\x07\x63olumns\x18\x01 \x03(\tR\x07\x63olumns\"\'\n\rSummaryValues\x12\x16\n\x06values\x18\x01 \x03(\tR\x06values\"\xb2\x01\n\x0bSummaryRows\x12\x16\n\x06header\x18\x01 \x03(\tR\x06header\x12\x36\n\x04rows\x18\x02 \x03(\x0b\x32\".summaryfile.SummaryRows.RowsEntryR\x04rows\x1aS\n\tRowsEntry\x12\x10\n\x03key\x18\x01 \x01(\tR\x03key\x12\x30\n\x05value\x18\x02 \x01(\x0b\x32\x1a.summaryfile.SummaryValuesR\x05value:\x02\x38\x01\";\n\x0bSummaryFile\x12,\n\x04rows\x18\x01 \x03(\x0b\x32\x18.summaryfile.SummaryRowsR\x04rowsB,Z*github.com/majorseitan/MMP_2024/mmp-io;libb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x11summaryfile.proto\x12\x0bsummaryfile\")\n\rSummaryHeader\x12\x18\n\x07\x63olumns\x18\x01 \x03(\tR\x07\x63olumns\"\'\n\rSummaryValues\x12\x16\n\x06values\x18\x01 \x03(\tR\x06values\"\xe5\x01\n\x0bSummaryRows\x12\x16\n\x06header\x18\x01 \x03(\tR\x06header\x12\x36\n\x04rows\x18\x02 \x03(\x0b\x32\".summaryfile.SummaryRows.RowsEntryR\x04rows\x12\x31\n\x06naming\x18\x03 \x01(\x0b\x32\x19.summaryfile.ColumnNamingR\x06naming\x1aS\n\tRowsEntry\x12\x10\n\x03key\x18\x01 \x01(\tR\x03key\x12\x30\n\x05value\x18\x02 \x01(\x0b\x32\x1a.summaryfile.SummaryValuesR\x05value:\x02\x38\x01\";\n\x0bSummaryFile\x12,\n\x04rows\x18\x01 \x03(\x0b\x32\x18.summaryfile.SummaryRowsR\x04rows\"\x8f\x02\n\x0c\x43olumnNaming\x12\x16\n\x06prefix\x18\x01 \x01(\tR\x06prefix\x12\x16\n\x06suffix\x18\x02 \x01(\tR\x06suffix\x12\x1c\n\tseparator\x18\x03 \x01(\tR\tseparator\x12\'\n\x0fstatistic_first\x18\x04 \x01(\x08R\x0estatisticFirst\x12I\n\nstatistics\x18\x05 \x03(\x0b\x32).summaryfile.ColumnNaming.StatisticsEntryR\nstatistics\x1a=\n\x0fStatisticsEntry\x12\x10\n\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n\x05value\x18\x02 \x01(\tR\x05value:\x02\x38\x01\x42,Z*github.com/majorseitan/MMP_2024/mmp-io;libb\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -34,14 +34,20 @@ if not _descriptor._USE_C_DESCRIPTORS:
   _globals['DESCRIPTOR']._serialized_options = b'Z*github.com/majorseitan/MMP_2024/mmp-io;lib'
   _globals['_SUMMARYROWS_ROWSENTRY']._loaded_options = None
   _globals['_SUMMARYROWS_ROWSENTRY']._serialized_options = b'8\001'
+  _globals['_COLUMNNAMING_STATISTICSENTRY']._loaded_options = None
+  _globals['_COLUMNNAMING_STATISTICSENTRY']._serialized_options = b'8\001'
   _globals['_SUMMARYHEADER']._serialized_start=34
   _globals['_SUMMARYHEADER']._serialized_end=75
   _globals['_SUMMARYVALUES']._serialized_start=77
   _globals['_SUMMARYVALUES']._serialized_end=116
   _globals['_SUMMARYROWS']._serialized_start=119
-  _globals['_SUMMARYROWS']._serialized_end=297
-  _globals['_SUMMARYROWS_ROWSENTRY']._serialized_start=214
-  _globals['_SUMMARYROWS_ROWSENTRY']._serialized_end=297
-  _globals['_SUMMARYFILE']._serialized_start=299
-  _globals['_SUMMARYFILE']._serialized_end=358
+  _globals['_SUMMARYROWS']._serialized_end=348
+  _globals['_SUMMARYROWS_ROWSENTRY']._serialized_start=265
+  _globals['_SUMMARYROWS_ROWSENTRY']._serialized_end=348
+  _globals['_SUMMARYFILE']._serialized_start=350
+  _globals['_SUMMARYFILE']._serialized_end=409
+  _globals['_COLUMNNAMING']._serialized_start=412
+  _globals['_COLUMNNAMING']._serialized_end=683
+  _globals['_COLUMNNAMING_STATISTICSENTRY']._serialized_start=622
+  _globals['_COLUMNNAMING_STATISTICSENTRY']._serialized_end=683
 # @@protoc_insertion_point(module_scope)
@@ -29,12 +29,35 @@ class SummaryRows(_message.Message):
         def __init__(self, key: _Optional[str] = ..., value: _Optional[_Union[SummaryValues, _Mapping]] = ...) -> None: ...
     HEADER_FIELD_NUMBER: _ClassVar[int]
     ROWS_FIELD_NUMBER: _ClassVar[int]
+    NAMING_FIELD_NUMBER: _ClassVar[int]
     header: _containers.RepeatedScalarFieldContainer[str]
     rows: _containers.MessageMap[str, SummaryValues]
-    def __init__(self, header: _Optional[_Iterable[str]] = ..., rows: _Optional[_Mapping[str, SummaryValues]] = ...) -> None: ...
+    naming: ColumnNaming
+    def __init__(self, header: _Optional[_Iterable[str]] = ..., rows: _Optional[_Mapping[str, SummaryValues]] = ..., naming: _Optional[_Union[ColumnNaming, _Mapping]] = ...) -> None: ...
 
 class SummaryFile(_message.Message):
     __slots__ = ()
     ROWS_FIELD_NUMBER: _ClassVar[int]
     rows: _containers.RepeatedCompositeFieldContainer[SummaryRows]
     def __init__(self, rows: _Optional[_Iterable[_Union[SummaryRows, _Mapping]]] = ...) -> None: ...
+
+class ColumnNaming(_message.Message):
+    __slots__ = ()
+    class StatisticsEntry(_message.Message):
+        __slots__ = ()
+        KEY_FIELD_NUMBER: _ClassVar[int]
+        VALUE_FIELD_NUMBER: _ClassVar[int]
+        key: str
+        value: str
+        def __init__(self, key: _Optional[str] = ..., value: _Optional[str] = ...) -> None: ...
+    PREFIX_FIELD_NUMBER: _ClassVar[int]
+    SUFFIX_FIELD_NUMBER: _ClassVar[int]
+    SEPARATOR_FIELD_NUMBER: _ClassVar[int]
+    STATISTIC_FIRST_FIELD_NUMBER: _ClassVar[int]
+    STATISTICS_FIELD_NUMBER: _ClassVar[int]
+    prefix: str
+    suffix: str
+    separator: str
+    statistic_first: bool
+    statistics: _containers.ScalarMap[str, str]
+    def __init__(self, prefix: _Optional[str] = ..., suffix: _Optional[str] = ..., separator: _Optional[str] = ..., statistic_first: bool = ..., statistics: _Optional[_Mapping[str, str]] = ...) -> None: ...
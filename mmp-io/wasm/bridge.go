@@ -9,8 +9,11 @@ import (
 )
 
 func jsToGo(v js.Value, targetT reflect.Type) (reflect.Value, error) {
-	// Handle pointer types by unwrapping once
+	// Handle pointer types by unwrapping once, null and undefined give nil
 	if targetT.Kind() == reflect.Ptr {
+		if v.IsNull() || v.IsUndefined() {
+			return reflect.Zero(targetT), nil
+		}
 		elem, err := jsToGo(v, targetT.Elem())
 		if err != nil {
 			return reflect.Value{}, err
@@ -196,6 +199,7 @@ func goToJS(v reflect.Value) (interface{}, error) {
 //   - After the call we drop references in the wrapper (in/out slices).
 //   - []byte parameters are copied from JS (Uint8Array/ArrayBuffer/Array).
 //   - []byte results are copied into a JS Uint8Array (so Go memory can be GC’d).
+//   - Trailing pointer parameters may be left out or null in JS and are passed as nil.
 //   - If you know some calls are huge, you can enable runtime.GC() at the end.
 func WrapToJS(fn interface{}, aggressiveGC bool) js.Func {
 	v := reflect.ValueOf(fn)
@@ -203,9 +207,14 @@ func WrapToJS(fn interface{}, aggressiveGC bool) js.Func {
 	if t.Kind() != reflect.Func {
 		panic("WrapToJS: fn must be a function")
 	}
+	// Trailing pointer parameters are optional and nil when left out
+	required := t.NumIn()
+	for required > 0 && t.In(required-1).Kind() == reflect.Ptr {
+		required--
+	}
 
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
-		if len(args) != t.NumIn() {
+		if len(args) < required || len(args) > t.NumIn() {
 			return js.ValueOf(map[string]interface{}{
 				"error": fmt.Sprintf("expected %d parameters, got %d", t.NumIn(), len(args)),
 			})
@@ -213,7 +222,11 @@ func WrapToJS(fn interface{}, aggressiveGC bool) js.Func {
 
 		in := make([]reflect.Value, t.NumIn())
 		for i := 0; i < t.NumIn(); i++ {
-			goVal, err := jsToGo(args[i], t.In(i))
+			arg := js.Undefined()
+			if i < len(args) {
+				arg = args[i]
+			}
+			goVal, err := jsToGo(arg, t.In(i))
 			if err != nil {
 				// Drop any partial inputs so GC can reclaim
 				for j := range in {
@@ -27,12 +27,40 @@ func main() {
 	registerCallbacks([]interface{}{
 		test,
 		lib.CreateFileColumnsIndex,
+		lib.DiagnoseColumns,
+		lib.CreateVCFColumnsIndex,
+		lib.CreateMergedColumnsIndex,
+		lib.DatasetFiles,
+		lib.CreateDatasetColumnsIndex,
 		lib.BufferVariants,
+		lib.BufferPhenotypes,
+		lib.BufferGenomicControl,
+		lib.GenomicControl,
+		lib.BufferQualityControl,
+		lib.QualityControl,
 		lib.BufferSummaryPasses,
 		lib.SummaryBytesString,
+		lib.SummaryPassesString,
 		lib.HeaderBytesString,
+		lib.SummaryBytesStringWithOptions,
+		lib.SummaryPassesStringWithOptions,
+		lib.HeaderBytesStringWithOptions,
 		lib.CreateHeader,
 		lib.CreateEmptyBlock,
+		lib.MergeSummaryBlocks,
+		lib.GWASSSFBytesString,
+		lib.ArrowBytes,
+		lib.ParquetBytes,
+		lib.SummaryJSONLines,
+		lib.TidyHeaderBytesString,
+		lib.TidySummaryBytesString,
+		lib.TidySummaryBytesStringWithOptions,
+		lib.PairwiseConcordance,
+		lib.ReplicationThresholds,
+		lib.ReplicationSummary,
+		lib.AlleleFrequencyCheck,
+		lib.BufferReferencePanel,
+		lib.DropAlleleFrequencyOutliers,
 	})
 	// Keep the program running indefinitely to serve WASM function calls
 	select {}
@@ -63,9 +63,37 @@ async function readBuffer(
   }
   return { value: merged, done: false } as const;}
 
+// "##" metadata lines, lines starting with a comment prefix and blank lines come before the header
+const isPreambleLine = (line: Uint8Array, commentPrefixes: string[]): boolean => {
+  const text = new TextDecoder().decode(line);
+  return text.startsWith('##') || commentPrefixes.some((prefix) => text.startsWith(prefix)) || text.trim() === '';
+};
+
+// Returns the end (past the newline) of the first line that is not part of the preamble, the header,
+// or -1 if the buffer ends before it
+export const findHeaderEnd = (bytes: Uint8Array, commentPrefixes: string[] = []): number => {
+  let start = 0;
+  while (start < bytes.length) {
+    const lineEnd = bytes.indexOf(0x0A, start);
+    if (lineEnd === -1) return -1;
+    if (!isPreambleLine(bytes.subarray(start, lineEnd), commentPrefixes)) return lineEnd + 1;
+    start = lineEnd + 1;
+  }
+  return -1;
+};
+
+const concatBytes = (a: Uint8Array, b: Uint8Array): Uint8Array => {
+  if (a.length === 0) return b;
+  const merged = new Uint8Array(a.length + b.length);
+  merged.set(a, 0);
+  merged.set(b, a.length);
+  return merged;
+};
+
 export async function* readFileInBlocks(
   file : File ,
-  blockSize : number= 8 * 1024 * 1024
+  blockSize : number= 8 * 1024 * 1024,
+  commentPrefixes : string[] = []
 ) {
   const decompressionStream = gunzipTransformStream();//new DecompressionStream('gzip');
   const compressed = await isCompressed(file);
@@ -76,27 +104,29 @@ export async function* readFileInBlocks(
   let leftover: Uint8Array = new Uint8Array(0);
   let offset = 0;
 
-  // Yield first line
+  // Yield the metadata and comment lines with the header as the first chunk,
+  // reading on until the header line is complete
   {
-    const { value, done } = await readBuffer(reader, blockSize);
-    if (!done && value) {
-      const bytes = value instanceof Uint8Array ? value : new Uint8Array(value);
-      const firstLineEnd = firstNL(bytes);
-      
-      if (firstLineEnd !== -1) {
+    let preamble: Uint8Array = new Uint8Array(0);
+    while (true) {
+      const { value, done } = await readBuffer(reader, blockSize);
+      if (done || !value) {
+        // No complete header line, keep as leftover
+        leftover = preamble;
+        break;
+      }
+      preamble = concatBytes(preamble, value instanceof Uint8Array ? value : new Uint8Array(value));
+      const headerEnd = findHeaderEnd(preamble, commentPrefixes);
+      if (headerEnd !== -1) {
         yield {
           offset: 0,
-          chunk: bytes.subarray(0, firstLineEnd + 1),
+          chunk: preamble.subarray(0, headerEnd),
         };
-        offset = firstLineEnd + 1;
-        
+        offset = headerEnd;
+
         // Keep remainder as leftover
-        if (firstLineEnd + 1 < bytes.length) {
-          leftover = bytes.subarray(firstLineEnd + 1);
-        }
-      } else {
-        // No newline found, keep as leftover
-        leftover = bytes;
+        leftover = preamble.subarray(headerEnd);
+        break;
       }
     }
   }
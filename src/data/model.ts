@@ -31,6 +31,37 @@ export type FileColumnsDefinition = FileColumns<string>;
 export type FileConfiguration = FileColumnsDefinition & {
     tag: string
     pval_threshold: number
+    // when set the file has no header row and columns are 0-based positions
+    headerless?: boolean
+    // prefixes of lines to skip besides "##" metadata lines
+    comment_prefixes?: string[]
+    // names of the statistic columns in the output header, "<tag>_<statistic>" when omitted
+    column_naming?: ColumnNaming
+    // long format files: the column naming the phenotype of each row, one tag per
+    // phenotype, and the phenotypes to keep, every phenotype in the file when omitted
+    phenotype_column?: string
+    phenotypes?: string[]
+    // per variant sample size column, or the sample size of the whole file
+    sample_size_column?: string
+    sample_size?: number
+}
+
+// prefix + tag + separator + statistic + suffix, statistic before tag when statistic_first
+export type ColumnNaming = {
+    prefix?: string
+    suffix?: string
+    separator?: string
+    statistic_first?: boolean
+    // statistic (pval, beta, sebeta, af, status) to its name in the header
+    statistics?: Record<string, string>
+}
+
+// how the writers format statistics, legacy "%e" / "%f" when omitted
+export type NumberFormat = {
+    significant_digits?: number
+    shortest?: boolean
+    scientific_threshold?: number
+    preserve_original?: boolean
 }
 
 export type FileConfigurationDelimiter = { delimiter: string }; 
@@ -87,7 +118,77 @@ export interface FinngenRequest {
 export type DelimitedText = { header : string , data : string };
 
 
-export type BlockMetadata = FileConfiguration & {  delimiter: string; };
+// genomic_control maps tags to the lambda their p-values and standard errors are corrected by,
+// a lambda of at most 1 leaves them unchanged
+export type BlockMetadata = FileConfiguration & {  delimiter: string; header_offset?: number; genomic_control?: Record<string, number>; };
+
+// maf_bins are ascending upper bounds of the minor allele frequency, at most 0.5
+export type GenomicControlConfiguration = {
+    by_chromosome?: boolean;
+    maf_bins?: number[];
+};
+
+// p-value histograms of one tag over one buffer, merged by GenomicControl
+export type GenomicControlCounts = {
+    tag: string;
+    pvalues: number[];
+    chromosomes?: Record<string, number[]>;
+    maf_bins?: number[][];
+};
+
+// lambda is null for groups without variants
+export type GenomicControlLambda = { variants: number; lambda: number | null };
+
+export type GenomicControlReport = GenomicControlLambda & {
+    tag: string;
+    chromosomes?: (GenomicControlLambda & { chromosome: number })[];
+    maf_bins?: (GenomicControlLambda & { min_maf: number; max_maf: number })[];
+};
+
+// Options of the *WithOptions summary functions, empty markers are written as NA
+export type SummaryOptions = {
+    delimiter: string;
+    cpra: boolean;
+    not_in_file?: string;
+    not_requested?: string;
+    missing_value?: string;
+    status_column?: boolean;
+    naming?: ColumnNaming;
+    // fixed-effect meta-analysis appended as meta_ columns, over every tag when tags is empty
+    meta?: MetaAnalysis;
+    // METAL style direction of effect per tag as the meta_direction column
+    direction?: Direction;
+    // <tag>_replicated column per replication tag
+    replication?: Replication;
+    number_format?: NumberFormat;
+};
+
+// replication tags default to every tag but the discovery one, correction to bonferroni and alpha to 0.05
+export type Replication = {
+    discovery: string;
+    replication?: string[];
+    discovery_threshold?: number;
+    correction?: 'bonferroni' | 'fdr';
+    alpha?: number;
+    // number of tests, the number of tested variants when omitted
+    tests?: number;
+    // thresholds per replication tag from ReplicationThresholds over all partitions
+    thresholds?: Record<string, number>;
+};
+
+// "+", "-", "0" and "?" per tag, "P"/"N" and "p"/"n" when significance_threshold is set
+export type Direction = {
+    tags?: string[];
+    significance_threshold?: number;
+};
+
+export type MetaAnalysis = {
+    tags?: string[];
+    // random-effects estimate and heterogeneity columns, tau² by DerSimonian-Laird or REML
+    random_effects?: 'dl' | 'reml';
+    // sample size weighted z-score meta-analysis over the tags with a sample size
+    stouffer?: boolean;
+};
 
 
 // what is an intutive name for these types
@@ -100,3 +201,114 @@ export type SummaryPass = SummaryBlock[]
 export type SummmryPassAcumulator = SummaryPass[]
 
 export type VariantPartitions = string[][];
+
+export type ConcordanceConfiguration = {
+    // lead variants have a p-value below the threshold in either tag, every shared variant when omitted
+    pval_threshold?: number;
+    tags?: string[];
+};
+
+// statistics that cannot be computed are null
+export type TagConcordance = {
+    tag_a: string;
+    tag_b: string;
+    shared_variants: number;
+    concordant_signs: number;
+    sign_concordance: number | null;
+    sign_binomial_pval: number | null;
+    correlation: number | null;
+    slope: number | null;
+    slope_se: number | null;
+    intercept: number | null;
+    deming_slope: number | null;
+    deming_slope_se: number | null;
+};
+
+export type ConcordanceReport = { pairs: TagConcordance[] };
+
+// rate and threshold are null when no variant is tested or can replicate
+export type TagReplication = {
+    tag: string;
+    tested: number;
+    replicated: number;
+    rate: number | null;
+    threshold: number | null;
+};
+
+export type ReplicationReport = { discovery: string; tags: TagReplication[] };
+
+// statistics of one buffer of a file, merged in file order by QualityControl
+export type QualityControlCounts = Record<string, unknown>;
+
+export type ChromosomeCoverage = { chromosome: number; variants: number; min_position: number; max_position: number };
+
+// parse_failures reasons: insufficient_columns, malformed_line, invalid_chromosome, invalid_position,
+// invalid_pval, pval_out_of_range, invalid_beta, invalid_sebeta, sebeta_not_positive, invalid_af,
+// af_out_of_range and invalid_sample_size; missing counts the missing values per statistic
+export type QualityControlReport = {
+    rows: number;
+    failed_rows: number;
+    parse_failures: Record<string, number>;
+    missing: Record<string, number>;
+    pval: GenomicControlLambda & { min: number | null; genome_wide: number; suggestive: number; deciles: number[] };
+    // the first MAF bin holds the monomorphic variants
+    af: { variants: number; mean: number | null; maf_bins: { max_maf: number; variants: number }[] };
+    // ratio of the SE to 1/sqrt(2·N·MAF·(1-MAF)), outliers differ from the median by more than a factor of 2
+    se_consistency: { variants: number; median_ratio: number | null; outliers: number };
+    duplicates: number;
+    chromosomes: ChromosomeCoverage[];
+    missing_autosomes: number[];
+    sorted: boolean;
+    unsorted_rows: number;
+};
+
+// issue is one of pval_above_one, beta_odds_ratio, af_minor_allele and z_inconsistent; field and the
+// keys of fix are FileConfiguration column fields, fix holds the column of the file that fits better
+export type ColumnIssue = {
+    field: string;
+    issue: string;
+    message: string;
+    fix?: Record<string, string>;
+};
+
+// suggested is the configuration with every fix applied
+export type ColumnDiagnostics = {
+    rows: number;
+    issues: ColumnIssue[];
+    suggested?: FileConfiguration;
+};
+
+// reference allele frequency table, tab separated by default, with columns chromosome, position,
+// reference, alternative and af unless named; delimiter is that of the variant keys of the blocks
+export type ReferencePanelConfiguration = {
+    delimiter: string;
+    reference_delimiter?: string;
+    chromosomeColumn?: string;
+    positionColumn?: string;
+    referenceColumn?: string;
+    alternativeColumn?: string;
+    afColumn?: string;
+    max_difference?: number;
+    tags?: string[];
+};
+
+export type AlleleFrequencyOutlier = {
+    variant: { chromosome: number; position: number; ref: string; alt: string };
+    af: number;
+    reference_af: number;
+    flipped: boolean;
+};
+
+// flipped counts the matches of variants with swapped alleles in the panel and the outliers matching
+// the other allele, suspected_flip is set when they are more than half of the shared variants
+export type TagAlleleFrequency = {
+    tag: string;
+    shared: number;
+    correlation: number | null;
+    flipped: number;
+    suspected_flip: boolean;
+    outliers: AlleleFrequencyOutlier[];
+};
+
+// skipped_reference_rows counts the panel rows on chromosomes that cannot be read, such as unplaced contigs
+export type AlleleFrequencyReport = { tags: TagAlleleFrequency[]; skipped_reference_rows: number };
@@ -1,5 +1,5 @@
 import { readFileInBlocks } from "../fileReader";
-import type { BlockMetadata, LocalFileConfiguration, PipelineConfiguration, StepCallBack, SummaryPass, SummmryPassAcumulator, VariantPartitions } from "../model";
+import type { BlockMetadata, ColumnNaming, LocalFileConfiguration, PipelineConfiguration, StepCallBack, SummaryPass, SummmryPassAcumulator, VariantPartitions } from "../model";
 import { createFileColumnsIndex } from "./collectVariants";
 
 const bufferSummaryPass = (buffer: Uint8Array<ArrayBufferLike>, metadata: BlockMetadata, partitions: VariantPartitions) : SummaryPass => {
@@ -8,7 +8,37 @@ const bufferSummaryPass = (buffer: Uint8Array<ArrayBufferLike>, metadata: BlockM
 }
 
 
-const createHeader : (tag: string) => string [] = (tag : string) => (window as any).CreateHeader(tag);
+const bufferPhenotypes = (buffer: Uint8Array<ArrayBufferLike>, metadata: BlockMetadata) : string[] => {
+    const result = (window as any).BufferPhenotypes(buffer, JSON.stringify(metadata));
+    if (result && typeof result === 'object' && 'error' in result) {
+        throw new Error(`BufferPhenotypes error: ${result.error}`);
+    }
+    return result as string[];
+}
+
+// Long format files without a phenotypes list get one tag per phenotype in the file,
+// collected over the whole file so every block of it has the same header
+const collectPhenotypes = async (
+    localFile : LocalFileConfiguration,
+    metadata: BlockMetadata,
+    pipelineConfig: PipelineConfiguration
+): Promise<string[]> => {
+    const phenotypes = new Set<string>();
+    const generator = readFileInBlocks(localFile.file, pipelineConfig.buffersize, localFile.comment_prefixes);
+    let first = true;
+    for await (const { chunk } of generator) {
+        const rows = first ? chunk.subarray(metadata.header_offset ?? chunk.length) : chunk;
+        first = false;
+        if (rows.length > 0) {
+            for (const phenotype of bufferPhenotypes(rows, metadata)) {
+                phenotypes.add(phenotype);
+            }
+        }
+    }
+    return [...phenotypes].sort();
+}
+
+const createHeader : (tag: string, naming?: ColumnNaming) => string [] = (tag : string, naming? : ColumnNaming) => (window as any).CreateHeader(tag, naming ?? null);
 
 export const collectRows = async (
     localFile : LocalFileConfiguration,
@@ -18,7 +48,7 @@ export const collectRows = async (
     setCallBack: StepCallBack
 ): Promise<SummmryPassAcumulator> => {
         setCallBack.processing()
-        const generator = readFileInBlocks(localFile.file, pipelineConfig.buffersize);
+        const generator = readFileInBlocks(localFile.file, pipelineConfig.buffersize, localFile.comment_prefixes);
         const firstResult = await generator.next();
 
         if (firstResult.done) {
@@ -28,9 +58,20 @@ export const collectRows = async (
 
         const { chunk: header } = firstResult.value;
         const metadata : BlockMetadata = createFileColumnsIndex(localFile, header);
+        if (metadata.phenotype_column !== undefined && !metadata.phenotypes?.length) {
+            metadata.phenotypes = await collectPhenotypes(localFile, metadata, pipelineConfig);
+        }
 
         // Collect all blocks from this file into one pass
         const localPass: SummaryPass = [];
+
+        // Data sharing the first chunk with the header (headerless files start with data)
+        const firstRows = header.subarray(metadata.header_offset ?? header.length);
+        if (firstRows.length > 0) {
+            for (const item of bufferSummaryPass(firstRows, metadata, partitions)) {
+                localPass.push(item);
+            }
+        }
         
         // Now loop through the remaining rows
         for await (const { chunk: row } of generator) {
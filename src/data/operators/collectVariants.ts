@@ -26,7 +26,7 @@ export const collectVariants = async (
     setCallBack: StepCallBack
 ): Promise<string[]> => {
     setCallBack.processing()
-    const generator = readFileInBlocks(localFile.file, pipelineConfig.buffersize);
+    const generator = readFileInBlocks(localFile.file, pipelineConfig.buffersize, localFile.comment_prefixes);
     const firstResult = await generator.next();
     
     if (firstResult.done) { 
@@ -37,6 +37,14 @@ export const collectVariants = async (
     const { chunk: header } = firstResult.value;
     const metadata = createFileColumnsIndex(localFile, header);
 
+    // Data sharing the first chunk with the header (headerless files start with data)
+    const firstRows = header.subarray(metadata.header_offset ?? header.length);
+    if (firstRows.length > 0) {
+        for (const variant of bufferVariants(firstRows, metadata)) {
+            result.push(variant);
+        }
+    }
+
     // Now loop through the remaining rows
     for await (const { chunk: row } of generator) {
         const variants = bufferVariants(row, metadata);
@@ -22,6 +22,27 @@ export const summaryBytesWithIndex = (summaryPass : SummaryPass, delimiter : str
     return result;
 }
 
+const summaryPassesString = (acumulator: SummmryPassAcumulator, delimiter: string, cpra: boolean): string[] => {
+    const result = (window as any).SummaryPassesString(acumulator, delimiter, cpra);
+    
+    // Handle error response from Go WASM
+    if (result && typeof result === 'object' && 'error' in result) {
+        throw new Error(`SummaryPassesString error: ${result.error}`);
+    }
+    
+    // Handle undefined/null (empty result)
+    if (result === undefined || result === null) {
+        return [];
+    }
+    
+    // Ensure result is an array
+    if (!Array.isArray(result)) {
+        throw new Error(`SummaryPassesString returned non-array: ${typeof result}`);
+    }
+    
+    return result;
+}
+
 const headerBytesString = (summaryPass: SummaryPass, delimiter: string, cpra: boolean): string => {
     const result = (window as any).HeaderBytesString(summaryPass, delimiter, cpra);
     
@@ -81,7 +102,7 @@ export const summaryStatistics = async (
     }
     
     // Pad all passes to have the same number of blocks
-    // This ensures that SummaryBytesString always sees all sources (with NA for missing data)
+    // This ensures that SummaryPassesString always sees all sources (with NA for missing data)
     for (const pass of acumulator) {
         if (pass.length > 0) {
             // Use the first block as reference for creating empty blocks
@@ -92,21 +113,8 @@ export const summaryStatistics = async (
         }
     }
     
-    // Process each block index across all files
-    const allData: string[] = [];
-    for (let blockIndex = 0; blockIndex < maxBlocks; blockIndex++) {
-        // Collect the nth block from each file
-        const blocksAcrossFiles: SummaryPass = [];
-        for (const filePass of acumulator) {
-            blocksAcrossFiles.push(filePass[blockIndex]);
-        }
-        
-        // Process the blocks and get result (array of rows for this block across all files)
-        const blockData = summaryBytesWithIndex(blocksAcrossFiles, delimiter, cpra);
-        for (const row of blockData) {
-            allData.push(row);
-        }
-    }
+    // Merge the sorted rows of every block index into one genomically sorted result
+    const allData = summaryPassesString(acumulator, delimiter, cpra);
     
     // Build header line from first block of each pass (not all blocks)
     const headerPasses: SummaryPass = acumulator
@@ -24,6 +24,8 @@ export interface SummaryRows {
   header: string[];
   /** key is VariantKey: "chrom\tpos\tref\talt" */
   rows: Map<string, SummaryValues>;
+  /** naming of the statistic columns when the block is written out */
+  naming?: ColumnNaming | undefined;
 }
 
 export interface SummaryRows_RowsEntry {
@@ -36,6 +38,26 @@ export interface SummaryFile {
   rows: SummaryRows[];
 }
 
+/**
+ * ColumnNaming is the template of the statistic column names of a tag in the
+ * output header: prefix, tag, separator, statistic name and suffix, with the
+ * statistic name before the tag when statistic_first is set
+ */
+export interface ColumnNaming {
+  prefix: string;
+  suffix: string;
+  /** defaults to "_" */
+  separator: string;
+  statisticFirst: boolean;
+  /** statistic (pval, beta, sebeta, af, status) to its name in the header */
+  statistics: Map<string, string>;
+}
+
+export interface ColumnNaming_StatisticsEntry {
+  key: string;
+  value: string;
+}
+
 function createBaseSummaryHeader(): SummaryHeader {
   return { columns: [] };
 }
@@ -139,7 +161,7 @@ export const SummaryValues: MessageFns<SummaryValues> = {
 };
 
 function createBaseSummaryRows(): SummaryRows {
-  return { header: [], rows: new Map() };
+  return { header: [], rows: new Map(), naming: undefined };
 }
 
 export const SummaryRows: MessageFns<SummaryRows> = {
@@ -150,6 +172,9 @@ export const SummaryRows: MessageFns<SummaryRows> = {
     message.rows.forEach((value, key) => {
       SummaryRows_RowsEntry.encode({ key: key as any, value }, writer.uint32(18).fork()).join();
     });
+    if (message.naming !== undefined) {
+      ColumnNaming.encode(message.naming, writer.uint32(26).fork()).join();
+    }
     return writer;
   },
 
@@ -179,6 +204,14 @@ export const SummaryRows: MessageFns<SummaryRows> = {
           }
           continue;
         }
+        case 3: {
+          if (tag !== 26) {
+            break;
+          }
+
+          message.naming = ColumnNaming.decode(reader, reader.uint32());
+          continue;
+        }
       }
       if ((tag & 7) === 4 || tag === 0) {
         break;
@@ -197,6 +230,7 @@ export const SummaryRows: MessageFns<SummaryRows> = {
           return acc;
         }, new Map())
         : new Map(),
+      naming: isSet(object.naming) ? ColumnNaming.fromJSON(object.naming) : undefined,
     };
   },
 
@@ -211,6 +245,9 @@ export const SummaryRows: MessageFns<SummaryRows> = {
         obj.rows[k] = SummaryValues.toJSON(v);
       });
     }
+    if (message.naming !== undefined) {
+      obj.naming = ColumnNaming.toJSON(message.naming);
+    }
     return obj;
   },
 };
@@ -330,6 +367,194 @@ export const SummaryFile: MessageFns<SummaryFile> = {
   },
 };
 
+function createBaseColumnNaming(): ColumnNaming {
+  return { prefix: "", suffix: "", separator: "", statisticFirst: false, statistics: new Map() };
+}
+
+export const ColumnNaming: MessageFns<ColumnNaming> = {
+  encode(message: ColumnNaming, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.prefix !== "") {
+      writer.uint32(10).string(message.prefix);
+    }
+    if (message.suffix !== "") {
+      writer.uint32(18).string(message.suffix);
+    }
+    if (message.separator !== "") {
+      writer.uint32(26).string(message.separator);
+    }
+    if (message.statisticFirst !== false) {
+      writer.uint32(32).bool(message.statisticFirst);
+    }
+    message.statistics.forEach((value, key) => {
+      ColumnNaming_StatisticsEntry.encode({ key: key as any, value }, writer.uint32(42).fork()).join();
+    });
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ColumnNaming {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseColumnNaming();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.prefix = reader.string();
+          continue;
+        }
+        case 2: {
+          if (tag !== 18) {
+            break;
+          }
+
+          message.suffix = reader.string();
+          continue;
+        }
+        case 3: {
+          if (tag !== 26) {
+            break;
+          }
+
+          message.separator = reader.string();
+          continue;
+        }
+        case 4: {
+          if (tag !== 32) {
+            break;
+          }
+
+          message.statisticFirst = reader.bool();
+          continue;
+        }
+        case 5: {
+          if (tag !== 42) {
+            break;
+          }
+
+          const entry5 = ColumnNaming_StatisticsEntry.decode(reader, reader.uint32());
+          if (entry5.value !== undefined) {
+            message.statistics.set(entry5.key, entry5.value);
+          }
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ColumnNaming {
+    return {
+      prefix: isSet(object.prefix) ? globalThis.String(object.prefix) : "",
+      suffix: isSet(object.suffix) ? globalThis.String(object.suffix) : "",
+      separator: isSet(object.separator) ? globalThis.String(object.separator) : "",
+      statisticFirst: isSet(object.statisticFirst) ? globalThis.Boolean(object.statisticFirst) : false,
+      statistics: isObject(object.statistics)
+        ? Object.entries(object.statistics).reduce<Map<string, string>>((acc, [key, value]) => {
+          acc.set(key, globalThis.String(value));
+          return acc;
+        }, new Map())
+        : new Map(),
+    };
+  },
+
+  toJSON(message: ColumnNaming): unknown {
+    const obj: any = {};
+    if (message.prefix !== "") {
+      obj.prefix = message.prefix;
+    }
+    if (message.suffix !== "") {
+      obj.suffix = message.suffix;
+    }
+    if (message.separator !== "") {
+      obj.separator = message.separator;
+    }
+    if (message.statisticFirst !== false) {
+      obj.statisticFirst = message.statisticFirst;
+    }
+    if (message.statistics?.size) {
+      obj.statistics = {};
+      message.statistics.forEach((v, k) => {
+        obj.statistics[k] = v;
+      });
+    }
+    return obj;
+  },
+};
+
+function createBaseColumnNaming_StatisticsEntry(): ColumnNaming_StatisticsEntry {
+  return { key: "", value: "" };
+}
+
+export const ColumnNaming_StatisticsEntry: MessageFns<ColumnNaming_StatisticsEntry> = {
+  encode(message: ColumnNaming_StatisticsEntry, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.key !== "") {
+      writer.uint32(10).string(message.key);
+    }
+    if (message.value !== "") {
+      writer.uint32(18).string(message.value);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ColumnNaming_StatisticsEntry {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseColumnNaming_StatisticsEntry();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.key = reader.string();
+          continue;
+        }
+        case 2: {
+          if (tag !== 18) {
+            break;
+          }
+
+          message.value = reader.string();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ColumnNaming_StatisticsEntry {
+    return {
+      key: isSet(object.key) ? globalThis.String(object.key) : "",
+      value: isSet(object.value) ? globalThis.String(object.value) : "",
+    };
+  },
+
+  toJSON(message: ColumnNaming_StatisticsEntry): unknown {
+    const obj: any = {};
+    if (message.key !== "") {
+      obj.key = message.key;
+    }
+    if (message.value !== "") {
+      obj.value = message.value;
+    }
+    return obj;
+  },
+};
+
 function isObject(value: any): boolean {
   return typeof value === "object" && value !== null;
 }
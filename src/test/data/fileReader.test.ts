@@ -1,5 +1,5 @@
 import { describe, it, expect } from 'vitest';
-import { isCompressed, readFileInBlocks, firstNL, findLastNewline } from '../../data/fileReader';
+import { isCompressed, readFileInBlocks, firstNL, findLastNewline, findHeaderEnd } from '../../data/fileReader';
 
 async function sha256(data: Uint8Array): Promise<string> {
   const hashBuffer = await crypto.subtle.digest('SHA-256', data as BufferSource);
@@ -40,6 +40,19 @@ describe('fileReader', () => {
     });
   });
 
+  describe('findHeaderEnd', () => {
+    it('should return the end of the first line without a header line prefix', () => {
+      const bytes = new TextEncoder().encode('##a=1\n# comment\nCHR\tPOS\n1\t100\n');
+      expect(findHeaderEnd(bytes)).toBe(16);
+      expect(findHeaderEnd(bytes, ['#'])).toBe(24);
+    });
+
+    it('should return -1 when the header line is not complete', () => {
+      expect(findHeaderEnd(new TextEncoder().encode('##a=1\nCHR\tP'))).toBe(-1);
+      expect(findHeaderEnd(new TextEncoder().encode('##a=1\n'))).toBe(-1);
+    });
+  });
+
   describe('findLastNewline', () => {
     it('should find last newline in buffer', () => {
       const bytes = new TextEncoder().encode('hello\nworld\n');
@@ -123,6 +136,42 @@ describe('fileReader', () => {
       expect(firstLine).toBe('header line\n');
     });
 
+    it('should yield metadata and comment lines with the header as the first chunk', async () => {
+      const preamble = '##genome_build=GRCh38\n##sample_size=1000\n// exported\n\nCHR\tPOS\n';
+      const data = '1\t100\n1\t200\n1\t300\n';
+      const file = new File([preamble + data], 'test.tsv', { type: 'text/plain' });
+
+      const chunks: string[] = [];
+      const decoder = new TextDecoder();
+
+      // Block size smaller than the preamble, as files are read in the pipeline
+      for await (const { chunk } of readFileInBlocks(file, 10, ['//'])) {
+        chunks.push(decoder.decode(chunk));
+      }
+
+      expect(chunks[0]).toBe(preamble);
+      expect(chunks.slice(1).join('')).toBe(data);
+      for (const chunk of chunks.slice(1)) {
+        expect(chunk).not.toContain('CHR');
+      }
+    });
+
+    it('should yield the VCF meta-information lines with the #CHROM line', async () => {
+      const preamble = '##fileformat=VCFv4.2\n##genome_build=GRCh37\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tS1\n';
+      const data = '1\t100\trs1\tA\tT\t.\tPASS\t.\tES:SE:LP\t0.1:0.1:2\n';
+      const file = new File([preamble + data], 'test.vcf', { type: 'text/plain' });
+
+      const chunks: string[] = [];
+      const decoder = new TextDecoder();
+
+      for await (const { chunk } of readFileInBlocks(file, 16)) {
+        chunks.push(decoder.decode(chunk));
+      }
+
+      expect(chunks[0]).toBe(preamble);
+      expect(chunks.slice(1).join('')).toBe(data);
+    });
+
     it('should yield all chunks from multi-line file', async () => {
       const content = 'line1\nline2\nline3\n';
       const file = new File([content], 'test.txt', { type: 'text/plain' });
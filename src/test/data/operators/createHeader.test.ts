@@ -18,4 +18,10 @@ describe('CreateHeader WASM binding', () => {
         expect(res[2]).toBe('file1_sebeta');
         expect(res[3]).toBe('file1_af');
     });
+
+    it('applies a naming template', () => {
+        const fn = (window as any).CreateHeader;
+        const res = fn('file1', { separator: '.', statistic_first: true, statistics: { pval: 'P' } });
+        expect(res).toEqual(['P.file1', 'beta.file1', 'sebeta.file1', 'af.file1']);
+    });
 });
@@ -14,6 +14,10 @@ describe('summaryStatistics error handling', () => {
             (window as any).SummaryBytesString = (window as any).__originalSummaryBytesString;
             delete (window as any).__originalSummaryBytesString;
         }
+        if ((window as any).__originalSummaryPassesString) {
+            (window as any).SummaryPassesString = (window as any).__originalSummaryPassesString;
+            delete (window as any).__originalSummaryPassesString;
+        }
         if ((window as any).__originalHeaderBytesString) {
             (window as any).HeaderBytesString = (window as any).__originalHeaderBytesString;
             delete (window as any).__originalHeaderBytesString;
@@ -101,9 +105,9 @@ describe('summaryStatistics error handling', () => {
         it('should handle error from HeaderBytesString WASM function', async () => {
             const { summaryStatistics } = await import('../../../data/operators/summaryStatistics');
             
-            // Mock both WASM functions - need to mock SummaryBytesString too to avoid protobuf errors
-            (window as any).__originalSummaryBytesString = (window as any).SummaryBytesString;
-            (window as any).SummaryBytesString = () => ['data row'];
+            // Mock both WASM functions - need to mock SummaryPassesString too to avoid protobuf errors
+            (window as any).__originalSummaryPassesString = (window as any).SummaryPassesString;
+            (window as any).SummaryPassesString = () => ['data row'];
             
             // Mock the HeaderBytesString function to return an error
             (window as any).__originalHeaderBytesString = (window as any).HeaderBytesString;
@@ -124,9 +128,9 @@ describe('summaryStatistics error handling', () => {
         it('should handle undefined from HeaderBytesString', async () => {
             const { summaryStatistics } = await import('../../../data/operators/summaryStatistics');
             
-            // Mock both functions - SummaryBytesString and HeaderBytesString
-            (window as any).__originalSummaryBytesString = (window as any).SummaryBytesString;
-            (window as any).SummaryBytesString = () => ['data row'];
+            // Mock both functions - SummaryPassesString and HeaderBytesString
+            (window as any).__originalSummaryPassesString = (window as any).SummaryPassesString;
+            (window as any).SummaryPassesString = () => ['data row'];
             
             (window as any).__originalHeaderBytesString = (window as any).HeaderBytesString;
             (window as any).HeaderBytesString = () => undefined;
@@ -145,9 +149,9 @@ describe('summaryStatistics error handling', () => {
         it('should handle non-string from HeaderBytesString', async () => {
             const { summaryStatistics } = await import('../../../data/operators/summaryStatistics');
             
-            // Mock SummaryBytesString to avoid protobuf errors
-            (window as any).__originalSummaryBytesString = (window as any).SummaryBytesString;
-            (window as any).SummaryBytesString = () => ['data row'];
+            // Mock SummaryPassesString to avoid protobuf errors
+            (window as any).__originalSummaryPassesString = (window as any).SummaryPassesString;
+            (window as any).SummaryPassesString = () => ['data row'];
             
             // Mock the HeaderBytesString function to return a non-string
             (window as any).__originalHeaderBytesString = (window as any).HeaderBytesString;
@@ -171,8 +175,8 @@ describe('summaryStatistics error handling', () => {
             const { summaryStatistics } = await import('../../../data/operators/summaryStatistics');
             
             // Mock all WASM functions to avoid protobuf errors
-            (window as any).__originalSummaryBytesString = (window as any).SummaryBytesString;
-            (window as any).SummaryBytesString = () => ['data row'];
+            (window as any).__originalSummaryPassesString = (window as any).SummaryPassesString;
+            (window as any).SummaryPassesString = () => ['data row'];
             
             (window as any).__originalHeaderBytesString = (window as any).HeaderBytesString;
             (window as any).HeaderBytesString = () => 'header';
@@ -200,8 +204,8 @@ describe('summaryStatistics error handling', () => {
             const { summaryStatistics } = await import('../../../data/operators/summaryStatistics');
             
             // Mock all WASM functions
-            (window as any).__originalSummaryBytesString = (window as any).SummaryBytesString;
-            (window as any).SummaryBytesString = () => ['data row'];
+            (window as any).__originalSummaryPassesString = (window as any).SummaryPassesString;
+            (window as any).SummaryPassesString = () => ['data row'];
             
             (window as any).__originalHeaderBytesString = (window as any).HeaderBytesString;
             (window as any).HeaderBytesString = () => 'header';